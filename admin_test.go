@@ -0,0 +1,253 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbcore"
+	"github.com/vikebot/vbrest/vbapi"
+	"go.uber.org/zap"
+)
+
+// auditDownStore is a `Store` whose audit trail can't be written. It
+// records the tokens blacklisted through it.
+type auditDownStore struct {
+	vbapi.Store
+	blacklisted []string
+}
+
+func (auditDownStore) AuditAdd(entry vbapi.AuditEntry, ctx *zap.Logger) bool {
+	return false
+}
+
+func (s *auditDownStore) JwtBlacklist(userID int, jti string, ctx *zap.Logger) (bool, bool) {
+	s.blacklisted = append(s.blacklisted, jti)
+	return s.Store.JwtBlacklist(userID, jti, ctx)
+}
+
+// tokenRequest returns the body of a token minting request for `userID`
+func tokenRequest(userID int, expiresAt time.Time, allowedIPs string) string {
+	return `{"user_id":` + strconv.Itoa(userID) +
+		`,"expires_at":"` + expiresAt.UTC().Format(time.RFC3339) +
+		`","allowed_ips":` + allowedIPs +
+		`,"reason":"support ticket"}`
+}
+
+func TestAdminTokenCreate(t *testing.T) {
+	server := newTestServer(t, nil)
+	alice := login(t, server, "alice")
+	ada := login(t, server, "ada")
+
+	expiresAt := time.Now().Add(time.Hour)
+	resp := do(server, testRequest{
+		method: "POST",
+		path:   "/v1/admin/tokens",
+		body:   tokenRequest(alice.UserID, expiresAt, `["`+testClientIP+`"]`),
+		header: bearer(ada.Token),
+	})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("minting failed with %d: %s", resp.StatusCode(), resp.Body())
+	}
+	var minted vbapi.AdminTokenResponse
+	decodeBody(t, resp, &minted)
+	if len(minted.Token) == 0 || len(minted.JTI) == 0 {
+		t.Fatalf("unexpected response %+v", minted)
+	}
+
+	// The token authenticates as alice, but only from the allowed IP
+	resp = do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(minted.Token)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("minted token rejected with %d: %s", resp.StatusCode(), resp.Body())
+	}
+	var user vbcore.User
+	decodeBody(t, resp, &user)
+	if user.ID == nil || *user.ID != alice.UserID {
+		t.Fatalf("want user %d but got %v", alice.UserID, user.ID)
+	}
+	resp = do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(minted.Token), ip: "198.51.100.1"})
+	if code := errorCode(t, resp); code != 10011 {
+		t.Fatalf("want code 10011 from another IP but got %d", code)
+	}
+
+	resp = do(server, testRequest{method: "GET", path: "/v1/admin/audit", header: bearer(ada.Token)})
+	var entries []vbapi.AuditEntry
+	decodeBody(t, resp, &entries)
+	if len(entries) != 1 {
+		t.Fatalf("want 1 audit entry but got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Action != vbapi.AuditActionTokenCreate || e.ActorID != ada.UserID || e.TargetUserID != alice.UserID || e.Reason != "support ticket" {
+		t.Fatalf("unexpected audit entry %+v", e)
+	}
+	if e.Details["jti"] != minted.JTI || e.Details["allowed_ips"] != testClientIP ||
+		e.Details["expires_at"] != expiresAt.UTC().Format(time.RFC3339) {
+		t.Fatalf("unexpected audit details %v", e.Details)
+	}
+}
+
+func TestAdminTokenCreateRejectsInvalidRequests(t *testing.T) {
+	server := newTestServer(t, nil)
+	alice := login(t, server, "alice")
+	tom := login(t, server, "tom")
+	ada := login(t, server, "ada")
+
+	expiresAt := time.Now().Add(time.Hour)
+	tests := []struct {
+		name  string
+		token string
+		body  string
+		code  int
+	}{
+		{"team member", tom.Token, tokenRequest(alice.UserID, expiresAt, `["`+testClientIP+`"]`), 9003},
+		{"no reason", ada.Token, `{"user_id":` + strconv.Itoa(alice.UserID) + `,"expires_at":"` + expiresAt.UTC().Format(time.RFC3339) + `","allowed_ips":["` + testClientIP + `"]}`, 11036},
+		{"expired", ada.Token, tokenRequest(alice.UserID, time.Now().Add(-time.Minute), `["`+testClientIP+`"]`), 11037},
+		{"no allowed ips", ada.Token, tokenRequest(alice.UserID, expiresAt, `[]`), 11038},
+		{"invalid allowed ip", ada.Token, tokenRequest(alice.UserID, expiresAt, `["not-an-ip"]`), 11038},
+		{"wildcard allowed ip", ada.Token, tokenRequest(alice.UserID, expiresAt, `["*"]`), 11038},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(server, testRequest{method: "POST", path: "/v1/admin/tokens", body: tt.body, header: bearer(tt.token)})
+			if code := errorCode(t, resp); code != tt.code {
+				t.Fatalf("want code %d but got %d: %s", tt.code, code, resp.Body())
+			}
+		})
+	}
+}
+
+func TestAdminTokenBlacklistedWithoutAudit(t *testing.T) {
+	config := testConfig(t)
+	server := newTestServer(t, config)
+	seeded, err := newStore(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &auditDownStore{Store: seeded}
+	replaceAPI(t, config, store, vbapi.Config{})
+
+	alice := login(t, server, "alice")
+	ada := login(t, server, "ada")
+	resp := do(server, testRequest{
+		method: "POST",
+		path:   "/v1/admin/tokens",
+		body:   tokenRequest(alice.UserID, time.Now().Add(time.Hour), `["`+testClientIP+`"]`),
+		header: bearer(ada.Token),
+	})
+	if resp.StatusCode() != fasthttp.StatusInternalServerError {
+		t.Fatalf("want 500 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+
+	if len(store.blacklisted) != 1 {
+		t.Fatalf("want the unaudited token blacklisted but got %v", store.blacklisted)
+	}
+	blacklisted, success := store.JwtIsBlacklisted(store.blacklisted[0], zap.NewNop())
+	if !success || !blacklisted {
+		t.Fatalf("token %s isn't blacklisted", store.blacklisted[0])
+	}
+}
+
+func TestPermissionSetIsAudited(t *testing.T) {
+	server := newTestServer(t, nil)
+	alice := login(t, server, "alice")
+	ada := login(t, server, "ada")
+
+	resp := do(server, testRequest{
+		method: "PUT",
+		path:   "/v1/admin/users/" + strconv.Itoa(alice.UserID) + "/permission",
+		body:   `{"permission":"` + vbcore.PermissionBannedString + `","reason":"spam"}`,
+		header: bearer(ada.Token),
+	})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("ban failed with %d: %s", resp.StatusCode(), resp.Body())
+	}
+
+	resp = do(server, testRequest{method: "GET", path: "/v1/admin/audit", header: bearer(ada.Token)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+	var entries []vbapi.AuditEntry
+	decodeBody(t, resp, &entries)
+	if len(entries) != 1 {
+		t.Fatalf("want 1 audit entry but got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Action != vbapi.AuditActionPermissionSet || e.ActorID != ada.UserID || e.TargetUserID != alice.UserID || e.Reason != "spam" {
+		t.Fatalf("unexpected audit entry %+v", e)
+	}
+	if e.Details["old"] != vbcore.PermissionDefaultString || e.Details["new"] != vbcore.PermissionBannedString {
+		t.Fatalf("unexpected audit details %v", e.Details)
+	}
+
+	// The ban revoked alice's refresh token as well
+	resp = do(server, testRequest{
+		method: "POST",
+		path:   "/v1/auth/refresh",
+		body:   `{"refresh_token":"` + alice.RefreshToken + `"}`,
+	})
+	if resp.StatusCode() == fasthttp.StatusOK {
+		t.Fatalf("refresh token of a banned user still works: %s", resp.Body())
+	}
+}
+
+func TestPermissionSetEscalation(t *testing.T) {
+	tests := []struct {
+		name       string
+		actor      string
+		target     string
+		permission string
+		code       int
+	}{
+		{"team verifies user", "tom", "alice", vbcore.PermissionVerifiedString, 0},
+		{"team bans user", "tom", "alice", vbcore.PermissionBannedString, 0},
+		{"team grants team", "tom", "alice", vbcore.PermissionTeamString, 11042},
+		{"team grants admin", "tom", "alice", vbcore.PermissionAdminString, 11042},
+		{"team demotes admin", "tom", "ada", vbcore.PermissionDefaultString, 11042},
+		{"team changes own permission", "tom", "tom", vbcore.PermissionAdminString, 11041},
+		{"admin grants team", "ada", "alice", vbcore.PermissionTeamString, 0},
+		{"admin demotes team", "ada", "tom", vbcore.PermissionDefaultString, 0},
+		{"admin changes own permission", "ada", "ada", vbcore.PermissionDefaultString, 11041},
+		{"user grants admin", "alice", "alice", vbcore.PermissionAdminString, 9003},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(t)
+			server := newTestServer(t, config)
+			store, err := newStore(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			replaceAPI(t, config, store, vbapi.Config{})
+
+			actor := login(t, server, tt.actor)
+			target := actor
+			if tt.target != tt.actor {
+				target = login(t, server, tt.target)
+			}
+
+			resp := do(server, testRequest{
+				method: "PUT",
+				path:   "/v1/admin/users/" + strconv.Itoa(target.UserID) + "/permission",
+				body:   `{"permission":"` + tt.permission + `","reason":"test"}`,
+				header: bearer(actor.Token),
+			})
+			if tt.code == 0 {
+				if resp.StatusCode() != fasthttp.StatusOK {
+					t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+				}
+			} else if code := errorCode(t, resp); code != tt.code {
+				t.Fatalf("want code %d but got %d: %s", tt.code, code, resp.Body())
+			}
+
+			permission, success := store.UserPermission(target.UserID, zap.NewNop())
+			if !success {
+				t.Fatal("unable to read the permission")
+			}
+			changed := vbcore.PermissionItoA(permission) == tt.permission
+			if changed != (tt.code == 0) {
+				t.Fatalf("permission of %s is %s after the request", tt.target, vbcore.PermissionItoA(permission))
+			}
+		})
+	}
+}
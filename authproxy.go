@@ -2,52 +2,163 @@ package main
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/valyala/fasthttp"
 	"github.com/vikebot/vbcore"
-	"github.com/vikebot/vbjwt"
 	"github.com/vikebot/vbnet"
 	"go.uber.org/zap"
 )
 
-func authproxy(req *fasthttp.RequestCtx, minPermission int, ctx *zap.Logger) (userID int, err error) {
-	var token string
+// authenticate enforces the auth requirement `a` and fills the request's
+// `UserID`, `Permission` and `TokenID` before calling the next handler
+func authenticate(a auth) middleware {
+	return func(next handler) handler {
+		if a.public {
+			return next
+		}
 
-	// Authentication via bearer header
-	if header := string(req.Request.Header.Peek("authorization")); len(header) > 0 {
-		if !strings.HasPrefix(header, "bearer ") {
-			if split := strings.Split(header, " "); len(split) == 2 {
-				token = split[1]
+		return func(req *request) (interface{}, error) {
+			userID, permission, jti, err := authproxy(req.RequestCtx, a.permission, req.Log)
+			if err != nil {
+				return nil, err
 			}
+
+			req.UserID = userID
+			req.Permission = permission
+			req.TokenID = jti
+			req.Log = req.Log.With(zap.Int("user_id", userID))
+			return next(req)
+		}
+	}
+}
+
+var (
+	// b64token is the token68 syntax from RFC 6750 section 2.1
+	b64token = regexp.MustCompile(`^[A-Za-z0-9\-._~+/]+=*$`)
+)
+
+const (
+	authScheme = "bearer"
+	authRealm  = "vikebot"
+)
+
+// authHeaderResult classifies the content of an Authorization header
+type authHeaderResult int
+
+const (
+	// authHeaderMissing means the header is absent or empty
+	authHeaderMissing authHeaderResult = iota
+	// authHeaderBearer means the header carries a well-formed bearer token
+	authHeaderBearer
+	// authHeaderMalformed means the header uses the bearer scheme but the
+	// credentials don't follow RFC 6750
+	authHeaderMalformed
+	// authHeaderOtherScheme means the header uses an authentication scheme
+	// vbrest doesn't know
+	authHeaderOtherScheme
+)
+
+// parseAuthHeader extracts the bearer token from an Authorization header
+// value as defined in RFC 6750 section 2.1. The scheme is matched case
+// insensitive.
+func parseAuthHeader(header string) (token string, result authHeaderResult) {
+	header = strings.TrimSpace(header)
+	if len(header) == 0 {
+		return "", authHeaderMissing
+	}
+
+	// Split into scheme and credentials. The scheme is separated by at least
+	// one space.
+	idx := strings.IndexByte(header, ' ')
+	if idx < 0 {
+		if strings.EqualFold(header, authScheme) {
+			return "", authHeaderMalformed
 		}
-		// Authentication via vbauth cookie
-	} else if t := string(req.Request.Header.Cookie("vbauth")); len(t) > 0 {
-		token = t
-	} else {
-		return 0, vbnet.NewHTTPError("No auth provided. Access forbidden",
-			fasthttp.StatusUnauthorized,
-			codeNoAuthProvided, nil)
+		return "", authHeaderOtherScheme
+	}
+	if !strings.EqualFold(header[:idx], authScheme) {
+		return "", authHeaderOtherScheme
 	}
 
-	userID, permission, err := vbjwt.VerifyCtx(token, realipFromFasthttp(req), ctx)
+	token = strings.TrimLeft(header[idx:], " ")
+	if !b64token.MatchString(token) {
+		return "", authHeaderMalformed
+	}
+	return token, authHeaderBearer
+}
+
+// unauthorized sets the `WWW-Authenticate` challenge from RFC 6750 section 3
+// and returns `err`. An empty `code` results in a challenge without error
+// attributes (e.g. no credentials were sent at all).
+func unauthorized(req *fasthttp.RequestCtx, code, description string, err error) error {
+	challenge := fmt.Sprintf(`Bearer realm="%s"`, authRealm)
+	if len(code) > 0 {
+		challenge += fmt.Sprintf(`, error="%s", error_description="%s"`, code, description)
+	}
+	req.Response.Header.Set("WWW-Authenticate", challenge)
+	return err
+}
+
+// authToken extracts the JWT from the request. A bearer Authorization header
+// always takes precedence over the `vbauth` cookie. Headers using another
+// scheme are ignored in favour of the cookie, while a malformed bearer header
+// is rejected without looking at the cookie, because the client clearly
+// intended to use it.
+func authToken(req *fasthttp.RequestCtx) (token string, err error) {
+	token, result := parseAuthHeader(string(req.Request.Header.Peek("Authorization")))
+	switch result {
+	case authHeaderBearer:
+		return token, nil
+	case authHeaderMalformed:
+		return "", unauthorized(req, "invalid_request", "Malformed bearer credentials", errMalformedAuthHeader)
+	}
+
+	// Authentication via vbauth cookie
+	if t := string(req.Request.Header.Cookie(authCookieName)); len(t) > 0 {
+		return t, nil
+	}
+
+	if result == authHeaderOtherScheme {
+		return "", unauthorized(req, "invalid_request", "Unsupported authorization scheme", errUnsupportedAuthScheme)
+	}
+	return "", unauthorized(req, "", "", errNoAuthProvided)
+}
+
+func authproxy(req *fasthttp.RequestCtx, minPermission int, ctx *zap.Logger) (userID int, permission int, jti string, err error) {
+	token, err := authToken(req)
+	if err != nil {
+		return 0, 0, "", err
+	}
+
+	userID, permission, jti, err = api.VerifyToken(token, realipFromFasthttp(req), ctx)
 	if err != nil {
-		return 0, err
+		return 0, 0, "", err
 	}
 	ctx.Info("authorized",
 		zap.Int("user_id", userID),
 		zap.Int("permission", permission))
 
+	// Banned users are rejected on every route, unless their ban expired
+	// just now. Routes open to banned users (e.g. logout) skip the check.
+	if permission <= vbcore.PermissionBanned && minPermission > vbcore.PermissionBanned {
+		permission, err = api.BanCheck(userID, ctx)
+		if err != nil {
+			return 0, 0, "", err
+		}
+	}
+
 	// Assert user permission
 	if permission < minPermission {
 		ctx.Warn("insufficient permission", zap.Int("permission_want", minPermission))
 
-		return 0, vbnet.NewHTTPError(
-			fmt.Sprintf("Insufficient permission. Needed %v, has %v", vbcore.PermissionItoA(minPermission), vbcore.PermissionItoA(permission)),
-			fasthttp.StatusForbidden,
-			codeInsufficientPermission,
+		return 0, 0, "", vbnet.NewHTTPError(
+			fmt.Sprintf("%s. Needed %v, has %v", errInsufficientPermission.Message(), vbcore.PermissionItoA(minPermission), vbcore.PermissionItoA(permission)),
+			errInsufficientPermission.HTTPCode(),
+			errInsufficientPermission.Code(),
 			nil)
 	}
 
-	return userID, nil
+	return userID, permission, jti, nil
 }
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbcore"
+	"github.com/vikebot/vbrest/vbapi"
+	"go.uber.org/zap"
+	jwt "gopkg.in/dgrijalva/jwt-go.v3"
+)
+
+func TestLoginIssuesUsableToken(t *testing.T) {
+	server := newTestServer(t, nil)
+	tokens := login(t, server, "alice")
+
+	resp := do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(tokens.Token)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+	var user vbcore.SafeUser
+	decodeBody(t, resp, &user)
+	if user.Username != "alice" || user.ID != tokens.UserID {
+		t.Fatalf("want alice (%d) but got %s (%d)", tokens.UserID, user.Username, user.ID)
+	}
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	server := newTestServer(t, nil)
+	tokens := login(t, server, "alice")
+
+	resp := do(server, testRequest{method: "POST", path: "/v1/auth/logout", header: bearer(tokens.Token)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("logout failed with %d: %s", resp.StatusCode(), resp.Body())
+	}
+
+	resp = do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(tokens.Token)})
+	if code := errorCode(t, resp); code != 10009 {
+		t.Fatalf("want blacklisted token (10009) but got %d: %s", code, resp.Body())
+	}
+
+	resp = do(server, testRequest{
+		method: "POST",
+		path:   "/v1/auth/refresh",
+		body:   `{"refresh_token":"` + tokens.RefreshToken + `"}`,
+	})
+	if resp.StatusCode() == fasthttp.StatusOK {
+		t.Fatalf("refresh token of the revoked session still works: %s", resp.Body())
+	}
+}
+
+func TestSessionsListsIssuedTokens(t *testing.T) {
+	server := newTestServer(t, nil)
+	first := login(t, server, "alice")
+	second := login(t, server, "alice")
+
+	resp := do(server, testRequest{method: "GET", path: "/v1/auth/sessions", header: bearer(second.Token)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+	var sessions []vbapi.Session
+	decodeBody(t, resp, &sessions)
+	if len(sessions) != 2 {
+		t.Fatalf("want 2 sessions but got %d", len(sessions))
+	}
+
+	resp = do(server, testRequest{method: "DELETE", path: "/v1/auth/sessions/" + sessionJTI(t, sessions, false), header: bearer(second.Token)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("revoking the other session failed with %d: %s", resp.StatusCode(), resp.Body())
+	}
+	resp = do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(first.Token)})
+	if code := errorCode(t, resp); code != 10009 {
+		t.Fatalf("want blacklisted token (10009) but got %d", code)
+	}
+
+	// The revoked session can't mint new tokens either
+	resp = refresh(server, first.RefreshToken)
+	if code := errorCode(t, resp); code != 11030 {
+		t.Fatalf("want revoked refresh token (11030) but got %d: %s", code, resp.Body())
+	}
+	resp = refresh(server, second.RefreshToken)
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("refresh of the remaining session failed with %d: %s", resp.StatusCode(), resp.Body())
+	}
+}
+
+// refresh exchanges `refreshToken` at `/v1/auth/refresh`
+func refresh(server *fasthttp.Server, refreshToken string) *fasthttp.Response {
+	return do(server, testRequest{
+		method: "POST",
+		path:   "/v1/auth/refresh",
+		body:   `{"refresh_token":"` + refreshToken + `"}`,
+	})
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	server := newTestServer(t, nil)
+	tokens := login(t, server, "alice")
+
+	resp := refresh(server, tokens.RefreshToken)
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+	var rotated vbapi.TokenResponse
+	decodeBody(t, resp, &rotated)
+	if rotated.RefreshToken == tokens.RefreshToken || rotated.Token == tokens.Token {
+		t.Fatal("refresh didn't issue new tokens")
+	}
+	resp = do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(rotated.Token)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("rotated token rejected with %d: %s", resp.StatusCode(), resp.Body())
+	}
+
+	resp = refresh(server, rotated.RefreshToken)
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("rotated refresh token rejected with %d: %s", resp.StatusCode(), resp.Body())
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	server := newTestServer(t, nil)
+	tokens := login(t, server, "alice")
+	other := login(t, server, "alice")
+
+	resp := refresh(server, tokens.RefreshToken)
+	var rotated vbapi.TokenResponse
+	decodeBody(t, resp, &rotated)
+
+	// Replaying the used token revokes everything derived from it
+	resp = refresh(server, tokens.RefreshToken)
+	if code := errorCode(t, resp); code != 11031 {
+		t.Fatalf("want reused refresh token (11031) but got %d: %s", code, resp.Body())
+	}
+	resp = refresh(server, rotated.RefreshToken)
+	if code := errorCode(t, resp); code != 11030 {
+		t.Fatalf("want revoked refresh token (11030) but got %d: %s", code, resp.Body())
+	}
+	for _, token := range []string{tokens.Token, rotated.Token} {
+		resp = do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(token)})
+		if code := errorCode(t, resp); code != 10009 {
+			t.Fatalf("want blacklisted token (10009) but got %d", code)
+		}
+	}
+
+	// Other families stay usable
+	resp = refresh(server, other.RefreshToken)
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("other session's refresh failed with %d: %s", resp.StatusCode(), resp.Body())
+	}
+}
+
+// sessionJTI returns the jti of the first session whose `Current` equals
+// `current`
+func sessionJTI(t *testing.T, sessions []vbapi.Session, current bool) string {
+	t.Helper()
+	for _, s := range sessions {
+		if s.Current == current {
+			return s.JTI
+		}
+	}
+	t.Fatalf("no session with current=%v", current)
+	return ""
+}
+
+func TestTokenVerification(t *testing.T) {
+	server := newTestServer(t, nil)
+	tokens := login(t, server, "alice")
+
+	// Same claims, but signed with a key vbrest doesn't know
+	parsed, _, err := new(jwt.Parser).ParseUnverified(tokens.Token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := parsed.SignedString([]byte("not the signing key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ip    string
+		code  int
+	}{
+		{"malformed", "not.a.jwt", "", 10002},
+		{"wrong signature", forged, "", 10004},
+		{"other ip", tokens.Token, "198.51.100.7", 10011},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(tt.token), ip: tt.ip})
+			if code := errorCode(t, resp); code != tt.code {
+				t.Fatalf("want %d but got %d: %s", tt.code, code, resp.Body())
+			}
+		})
+	}
+}
+
+func TestPermissionIsReadFromStore(t *testing.T) {
+	server := newTestServer(t, nil)
+	alice := login(t, server, "alice")
+	tom := login(t, server, "tom")
+	ada := login(t, server, "ada")
+
+	resp := do(server, testRequest{method: "GET", path: "/v1/admin/audit", header: bearer(alice.Token)})
+	if resp.StatusCode() != fasthttp.StatusForbidden {
+		t.Fatalf("default user accessed admin endpoint: %d %s", resp.StatusCode(), resp.Body())
+	}
+	resp = do(server, testRequest{method: "GET", path: "/v1/admin/audit", header: bearer(ada.Token)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("admin couldn't access admin endpoint: %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	// Banning alice locks out their existing token
+	resp = do(server, testRequest{
+		method: "PUT",
+		path:   "/v1/admin/users/" + strconv.Itoa(alice.UserID) + "/permission",
+		body:   `{"permission":"` + vbcore.PermissionBannedString + `","reason":"spam"}`,
+		header: bearer(tom.Token),
+	})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("ban failed with %d: %s", resp.StatusCode(), resp.Body())
+	}
+	resp = do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(alice.Token)})
+	if resp.StatusCode() == fasthttp.StatusOK {
+		t.Fatalf("banned user's token still works: %s", resp.Body())
+	}
+}
+
+func TestParseAuthHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		result authHeaderResult
+	}{
+		{"", "", authHeaderMissing},
+		{"   ", "", authHeaderMissing},
+		{"Bearer abc.def-ghi_jkl~mno+pqr/stu==", "abc.def-ghi_jkl~mno+pqr/stu==", authHeaderBearer},
+		{"bearer abc", "abc", authHeaderBearer},
+		{"BEARER abc", "abc", authHeaderBearer},
+		{"Bearer    abc", "abc", authHeaderBearer},
+		{"  Bearer abc  ", "abc", authHeaderBearer},
+		{"Bearer", "", authHeaderMalformed},
+		{"bearer", "", authHeaderMalformed},
+		{"Bearer abc def", "", authHeaderMalformed},
+		{"Bearer abc=def", "", authHeaderMalformed},
+		{"Bearer =abc", "", authHeaderMalformed},
+		{"Bearer a\"bc", "", authHeaderMalformed},
+		{"Bearer\tabc", "", authHeaderOtherScheme},
+		{"Basic dXNlcjpwYXNz", "", authHeaderOtherScheme},
+		{"Token abc", "", authHeaderOtherScheme},
+		{"abc", "", authHeaderOtherScheme},
+		{"Bearerabc", "", authHeaderOtherScheme},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			token, result := parseAuthHeader(tt.header)
+			if token != tt.token || result != tt.result {
+				t.Fatalf("want (%q, %v) but got (%q, %v)", tt.token, tt.result, token, result)
+			}
+		})
+	}
+}
+
+func TestAuthToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		cookie    string
+		token     string
+		err       error
+		challenge string
+	}{
+		{"nothing", "", "", "", errNoAuthProvided, `Bearer realm="vikebot"`},
+		{"header", "Bearer fromheader", "", "fromheader", nil, ""},
+		{"cookie", "", "fromcookie", "fromcookie", nil, ""},
+		{"header wins over cookie", "Bearer fromheader", "fromcookie", "fromheader", nil, ""},
+		{"malformed header", "Bearer a b", "", "", errMalformedAuthHeader,
+			`Bearer realm="vikebot", error="invalid_request", error_description="Malformed bearer credentials"`},
+		{"malformed header ignores cookie", "Bearer a b", "fromcookie", "", errMalformedAuthHeader,
+			`Bearer realm="vikebot", error="invalid_request", error_description="Malformed bearer credentials"`},
+		{"other scheme", "Basic dXNlcjpwYXNz", "", "", errUnsupportedAuthScheme,
+			`Bearer realm="vikebot", error="invalid_request", error_description="Unsupported authorization scheme"`},
+		{"other scheme falls back to cookie", "Basic dXNlcjpwYXNz", "fromcookie", "fromcookie", nil, ""},
+		{"empty header uses cookie", "  ", "fromcookie", "fromcookie", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c fasthttp.RequestCtx
+			if len(tt.header) > 0 {
+				c.Request.Header.Set("Authorization", tt.header)
+			}
+			if len(tt.cookie) > 0 {
+				c.Request.Header.SetCookie(authCookieName, tt.cookie)
+			}
+
+			token, err := authToken(&c)
+			if token != tt.token || err != tt.err {
+				t.Fatalf("want (%q, %v) but got (%q, %v)", tt.token, tt.err, token, err)
+			}
+			if challenge := string(c.Response.Header.Peek("WWW-Authenticate")); challenge != tt.challenge {
+				t.Fatalf("want challenge %q but got %q", tt.challenge, challenge)
+			}
+		})
+	}
+}
+
+func TestPasswordSet(t *testing.T) {
+	server := newTestServer(t, nil)
+	tokens := login(t, server, "alice")
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"too short", `{"current_password":"` + testPassword + `","password":"short"}`, 11047},
+		{"missing current", `{"password":"a new password"}`, 11048},
+		{"wrong current", `{"current_password":"wrong","password":"a new password"}`, 11048},
+		{"changed", `{"current_password":"` + testPassword + `","password":"a new password"}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(server, testRequest{method: "PUT", path: "/v1/auth/password", body: tt.body, header: bearer(tokens.Token)})
+			if tt.code == 0 {
+				if resp.StatusCode() != fasthttp.StatusOK {
+					t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+				}
+				return
+			}
+			if code := errorCode(t, resp); code != tt.code {
+				t.Fatalf("want %d but got %d: %s", tt.code, code, resp.Body())
+			}
+		})
+	}
+
+	resp := do(server, testRequest{method: "POST", path: "/v1/auth/login", body: `{"username":"alice","password":"a new password"}`})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("login with the new password failed with %d: %s", resp.StatusCode(), resp.Body())
+	}
+}
+
+func TestForwardedForIgnoredFromUntrustedPeers(t *testing.T) {
+	server := newTestServer(t, nil)
+	tokens := login(t, server, "alice")
+
+	// The token is bound to the connection's address, so a spoofed header
+	// neither helps nor hurts
+	resp := do(server, testRequest{
+		method: "GET",
+		path:   "/v1/user/get",
+		header: map[string]string{"Authorization": "Bearer " + tokens.Token, "X-Forwarded-For": "198.51.100.7"},
+	})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+}
+
+func TestBannedUserCanManageSessions(t *testing.T) {
+	server := newTestServer(t, nil)
+	tokens := login(t, server, "mallory")
+
+	resp := do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(tokens.Token)})
+	if code := errorCode(t, resp); code != 11043 {
+		t.Fatalf("want banned (11043) but got %d: %s", code, resp.Body())
+	}
+
+	resp = do(server, testRequest{method: "GET", path: "/v1/auth/sessions", header: bearer(tokens.Token)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+	resp = do(server, testRequest{method: "POST", path: "/v1/auth/logout", header: bearer(tokens.Token)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+}
+
+func TestBannedUserRejectedOnEveryRoute(t *testing.T) {
+	config := testConfig(t)
+	server := newTestServer(t, config)
+	tokens := login(t, server, "mallory")
+
+	versions, err := allVersions(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ep := range allEndpoints(versions, false) {
+		if ep.Auth.public || ep.Auth.permission <= vbcore.PermissionBanned {
+			continue
+		}
+		segs := strings.Split(ep.Name, "/")
+		for i, seg := range segs {
+			if strings.HasPrefix(seg, ":") {
+				segs[i] = "1"
+			}
+		}
+		path := strings.Join(segs, "/")
+
+		resp := do(server, testRequest{method: ep.Methods[0], path: path, body: "{}", header: bearer(tokens.Token)})
+		if code := errorCode(t, resp); code != 11043 {
+			t.Errorf("%s %s: want banned (11043) but got %d: %s", ep.Methods[0], path, code, resp.Body())
+		}
+	}
+}
+
+func TestBanExpiresAndIsLifted(t *testing.T) {
+	config := testConfig(t)
+	server := newTestServer(t, config)
+	store, err := newStore(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaceAPI(t, config, store, vbapi.Config{})
+	alice := login(t, server, "alice")
+	ada := login(t, server, "ada")
+
+	expiresAt := time.Now().Add(500 * time.Millisecond)
+	resp := do(server, testRequest{
+		method: "PUT",
+		path:   "/v1/admin/users/" + strconv.Itoa(alice.UserID) + "/permission",
+		body:   `{"permission":"` + vbcore.PermissionBannedString + `","reason":"spam","expires_at":"` + expiresAt.UTC().Format(time.RFC3339Nano) + `"}`,
+		header: bearer(ada.Token),
+	})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("ban failed with %d: %s", resp.StatusCode(), resp.Body())
+	}
+
+	alice = login(t, server, "alice")
+	resp = do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(alice.Token)})
+	if code := errorCode(t, resp); code != 11043 {
+		t.Fatalf("want banned (11043) but got %d: %s", code, resp.Body())
+	}
+
+	time.Sleep(time.Until(expiresAt))
+	resp = do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(alice.Token)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want the expired ban lifted but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+	permission, success := store.UserPermission(alice.UserID, zap.NewNop())
+	if !success || permission != vbcore.PermissionDefault {
+		t.Fatalf("want default permission restored but got %s", vbcore.PermissionItoA(permission))
+	}
+
+	// A later ban set without details (e.g. directly in the database) must
+	// not be lifted because of the expired one
+	success = store.UserPermissionSet(alice.UserID, vbcore.PermissionBanned, "manual", nil, zap.NewNop())
+	if !success {
+		t.Fatal("unable to ban alice")
+	}
+	resp = do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(alice.Token)})
+	if code := errorCode(t, resp); code != 11043 {
+		t.Fatalf("want banned (11043) but got %d: %s", code, resp.Body())
+	}
+}
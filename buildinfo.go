@@ -0,0 +1,51 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Build information injected at build time with
+//
+//	go build -ldflags "-X main.buildVersion=<version> -X main.buildCommit=<commit>"
+var (
+	buildVersion = "dev"
+	buildCommit  = ""
+)
+
+// startTime is when the process started serving
+var startTime time.Time
+
+// buildInfo is the response of `/v1/meta/version`
+type buildInfo struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	GoVersion string    `json:"go_version"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// commit returns the injected git commit. If none was injected the commit
+// recorded by the go toolchain is used.
+func commit() string {
+	if len(buildCommit) > 0 {
+		return buildCommit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+func newBuildInfo() *buildInfo {
+	return &buildInfo{
+		Version:   buildVersion,
+		Commit:    commit(),
+		GoVersion: runtime.Version(),
+		StartTime: startTime,
+	}
+}
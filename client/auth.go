@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+)
+
+// Login logs in with username and password. On success the client uses
+// the new access token for all following requests.
+func (c *Client) Login(ctx context.Context, username, password string) (*TokenResponse, error) {
+	resp, err := c.postV1AuthLogin(ctx, &LoginRequest{
+		Username: &username,
+		Password: &password,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.SetToken(resp.Token)
+	return resp, nil
+}
+
+// Refresh exchanges the refresh token for new tokens. On success the client
+// uses the new access token for all following requests.
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	resp, err := c.postV1AuthRefresh(ctx, &RefreshRequest{
+		RefreshToken: &refreshToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.SetToken(resp.Token)
+	return resp, nil
+}
+
+// Logout revokes the tokens of the current session and makes the client
+// anonymous
+func (c *Client) Logout(ctx context.Context) error {
+	err := c.postV1AuthLogout(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.SetToken("")
+	return nil
+}
+
+// Sessions lists the authenticated user's sessions
+func (c *Client) Sessions(ctx context.Context) ([]Session, error) {
+	return c.getV1AuthSessions(ctx)
+}
+
+// SessionRevoke revokes the session with the token id `jti`
+func (c *Client) SessionRevoke(ctx context.Context, jti string) error {
+	return c.deleteV1AuthSessionsJti(ctx, jti)
+}
@@ -0,0 +1,225 @@
+// Package client is a typed Go client for vbrest. Failed requests are
+// returned as `*Error`, which implements `vbnet.HTTPError` with the error
+// code sent by vbrest (see docs/errors.md).
+//
+// The DTOs and request methods in openapi.go are generated from vbrest's
+// OpenAPI document, the exported methods wrap them.
+package client
+
+//go:generate go run ./gen -spec ../docs/openapi.json -out openapi.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vikebot/vbnet"
+)
+
+const (
+	// authCookieName is the cookie vbrest reads the JWT from for browser
+	// clients
+	authCookieName = "vbauth"
+	// statusPolicyHeader opts `/v1` requests in to the current HTTP status
+	// policy, so all errors have the same status regardless of the version
+	statusPolicyHeader  = "Vikebot-Status-Policy"
+	statusPolicyCurrent = "2"
+
+	defaultRetryWait = 200 * time.Millisecond
+)
+
+// Config configures a `Client`
+type Config struct {
+	// BaseURL is the address of vbrest, e.g. `https://api.vikebot.com`
+	BaseURL string
+	// Token is the JWT used to authenticate requests. Anonymous clients
+	// leave it empty. Can be changed later with `Client.SetToken`.
+	Token string
+	// Cookie sends the token as `vbauth` cookie like browsers do, instead of
+	// a bearer Authorization header
+	Cookie bool
+	// HTTPClient is used for all requests. Defaults to `http.DefaultClient`.
+	HTTPClient *http.Client
+	// MaxRetries is how often idempotent requests (GET, PUT, DELETE) are
+	// retried if they fail with a network error or a temporary status (429,
+	// 502, 503 and 504). Zero disables retries.
+	MaxRetries int
+	// RetryWait is the wait before the first retry. It doubles with every
+	// further retry. Defaults to 200ms.
+	RetryWait time.Duration
+}
+
+// Client calls vbrest. It's safe for concurrent use.
+type Client struct {
+	config Config
+
+	tokenMu sync.RWMutex
+	token   string
+}
+
+// New creates a new client
+func New(config Config) (*Client, error) {
+	if len(config.BaseURL) == 0 {
+		return nil, fmt.Errorf("client: BaseURL mustn't be empty")
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	if config.MaxRetries < 0 {
+		return nil, fmt.Errorf("client: MaxRetries mustn't be negative")
+	}
+	if config.RetryWait <= 0 {
+		config.RetryWait = defaultRetryWait
+	}
+
+	return &Client{
+		config: config,
+		token:  config.Token,
+	}, nil
+}
+
+// SetToken changes the JWT used to authenticate all following requests. An
+// empty token makes the client anonymous.
+func (c *Client) SetToken(token string) {
+	c.tokenMu.Lock()
+	c.token = token
+	c.tokenMu.Unlock()
+}
+
+func (c *Client) currentToken() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.token
+}
+
+// Error is a failed request as reported by vbrest's error envelope
+type Error struct {
+	vbnet.HTTPError
+	// RequestID identifies the request in vbrest's logs
+	RequestID string
+	// Details are the raw error specific details. Nil if there are none.
+	Details json.RawMessage
+}
+
+// decodeError converts the error envelope of a failed response. Responses
+// without a valid envelope (e.g. from a proxy) get the code 0.
+func decodeError(status int, body []byte) *Error {
+	var env ErrorResponse
+	if err := json.Unmarshal(body, &env); err != nil || env.Code == 0 {
+		return &Error{
+			HTTPError: vbnet.NewHTTPError(fmt.Sprintf("unexpected response: %s", http.StatusText(status)), status, 0, nil),
+		}
+	}
+	return &Error{
+		HTTPError: vbnet.NewHTTPError(env.Message, status, env.Code, nil),
+		RequestID: env.RequestID,
+		Details:   env.Details,
+	}
+}
+
+// idempotent reports whether requests with `method` can safely be retried
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// temporary reports whether a response with `status` is worth a retry
+func temporary(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// do sends the request and decodes the response into `out` (if not nil).
+// `in` is marshaled as JSON body if not nil.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+
+	retries := 0
+	if idempotent(method) {
+		retries = c.config.MaxRetries
+	}
+
+	wait := c.config.RetryWait
+	for attempt := 0; ; attempt++ {
+		status, respBody, err := c.send(ctx, method, path, body)
+		retry := attempt < retries && (err != nil || temporary(status))
+		if !retry {
+			if err != nil {
+				return err
+			}
+			if status < 200 || status > 299 {
+				return decodeError(status, respBody)
+			}
+			if out == nil {
+				return nil
+			}
+			return json.Unmarshal(respBody, out)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// send executes a single attempt of a request
+func (c *Client) send(ctx context.Context, method, path string, body []byte) (status int, respBody []byte, err error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	r, err := http.NewRequest(method, c.config.BaseURL+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	r = r.WithContext(ctx)
+
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set(statusPolicyHeader, statusPolicyCurrent)
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token := c.currentToken(); len(token) > 0 {
+		if c.config.Cookie {
+			r.AddCookie(&http.Cookie{Name: authCookieName, Value: token})
+		} else {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	resp, err := c.config.HTTPClient.Do(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, respBody, nil
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client of `handler` which retries twice
+func newTestClient(t *testing.T, handler http.HandlerFunc, cookie bool) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	c, err := New(Config{
+		BaseURL:    srv.URL,
+		Token:      "token",
+		Cookie:     cookie,
+		MaxRetries: 2,
+		RetryWait:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRetriesIdempotentRequests(t *testing.T) {
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[{"code":9000,"http_status":500,"message":"Internal server error"}]`))
+	}, false)
+
+	errs, err := c.Errors(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].Code != 9000 || calls != 3 {
+		t.Fatalf("want one error after 3 calls but got %v after %d", errs, calls)
+	}
+}
+
+func TestDoesntRetryJoin(t *testing.T) {
+	var calls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		// A proxy in front of vbrest without vbrest's error envelope
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, false)
+
+	_, err := c.RoundJoin(context.Background(), 1)
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("want *Error but got %v", err)
+	}
+	if e.Code() != 0 || e.HTTPCode() != http.StatusServiceUnavailable || calls != 1 {
+		t.Fatalf("want code 0 (503) after 1 call but got %d (%d) after %d", e.Code(), e.HTTPCode(), calls)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	for _, cookie := range []bool{false, true} {
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			vbauth, _ := r.Cookie(authCookieName)
+			if cookie && (len(auth) > 0 || vbauth == nil || vbauth.Value != "token") ||
+				!cookie && (auth != "Bearer token" || vbauth != nil) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"id":1,"username":"alice"}`))
+		}, cookie)
+
+		user, err := c.UserGet(context.Background())
+		if err != nil {
+			t.Fatalf("cookie %v: %v", cookie, err)
+		}
+		if user.Username != "alice" {
+			t.Fatalf("cookie %v: want alice but got %q", cookie, user.Username)
+		}
+	}
+}
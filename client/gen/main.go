@@ -0,0 +1,351 @@
+// Command gen generates the DTOs and request methods of package client from
+// vbrest's OpenAPI document. Run `go generate ./client` after regenerating
+// docs/openapi.json.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// spec is the part of an OpenAPI 3 document the generator understands
+type spec struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Tags        []string    `json:"tags"`
+	Deprecated  bool        `json:"deprecated"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *content    `json:"requestBody"`
+	Responses   map[string]*content
+}
+
+type parameter struct {
+	Name string `json:"name"`
+	In   string `json:"in"`
+}
+
+type content struct {
+	Content map[string]struct {
+		Schema *schema `json:"schema"`
+	} `json:"content"`
+}
+
+// schema returns the schema of the JSON content or nil
+func (c *content) schema() *schema {
+	if c == nil {
+		return nil
+	}
+	return c.Content["application/json"].Schema
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+}
+
+// skippedTags are operations which aren't part of the API, like health
+// checks and metrics for load balancers and monitoring
+var skippedTags = map[string]bool{"health": true}
+
+// plainResponse is the schema of responses without content besides "ok"
+const plainResponse = "SimpleResponse"
+
+// initialisms are spelled in upper case in Go identifiers
+var initialisms = map[string]string{
+	"aes":  "AES",
+	"id":   "ID",
+	"ip":   "IP",
+	"ipv4": "IPv4",
+	"ipv6": "IPv6",
+	"jti":  "JTI",
+	"url":  "URL",
+	"ips":  "IPs",
+	"http": "HTTP",
+}
+
+// exported converts a JSON name like `refresh_token` to `RefreshToken`
+func exported(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if i, ok := initialisms[strings.ToLower(part)]; ok && strings.ToLower(part) == part {
+			b.WriteString(i)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// unexported converts an operation id like `getV1MetaOpenapi.json` to
+// `getV1MetaOpenapiJson`
+func unexported(name string) string {
+	s := exported(name)
+	for _, i := range initialisms {
+		if strings.HasPrefix(s, i) && strings.ToLower(name[:len(i)]) == name[:len(i)] {
+			return strings.ToLower(i) + s[len(i):]
+		}
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func refName(ref string) string {
+	return exported(ref[strings.LastIndex(ref, "/")+1:])
+}
+
+// generator collects the generated code and the imports it needs
+type generator struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// goType returns the Go type of values described by `s`
+func (g *generator) goType(s *schema) string {
+	if s == nil {
+		g.imports["encoding/json"] = true
+		return "json.RawMessage"
+	}
+	if len(s.Ref) > 0 {
+		return refName(s.Ref)
+	}
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return "time.Time"
+		case "byte":
+			return "[]byte"
+		}
+		return "string"
+	case "integer":
+		if s.Format == "int64" {
+			return "int64"
+		}
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "[]" + g.goType(s.Items)
+	case "object":
+		if s.AdditionalProperties != nil {
+			return "map[string]" + g.goType(s.AdditionalProperties)
+		}
+	}
+	g.imports["encoding/json"] = true
+	return "json.RawMessage"
+}
+
+// nilable reports whether the zero value of `t` is nil
+func nilable(t string) bool {
+	return strings.HasPrefix(t, "[]") || strings.HasPrefix(t, "map[") || t == "json.RawMessage"
+}
+
+func (g *generator) types(schemas map[string]*schema) {
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := schemas[name]
+		required := make(map[string]bool, len(s.Required))
+		for _, r := range s.Required {
+			required[r] = true
+		}
+		props := make([]string, 0, len(s.Properties))
+		for p := range s.Properties {
+			props = append(props, p)
+		}
+		sort.Strings(props)
+
+		g.printf("// %s is the `%s` schema of vbrest's API\n", exported(name), name)
+		g.printf("type %s struct {\n", exported(name))
+		for _, p := range props {
+			ps := s.Properties[p]
+			t := g.goType(ps)
+			tag := p
+			if !required[p] {
+				tag += ",omitempty"
+			}
+			if (ps.Nullable || !required[p]) && !nilable(t) {
+				t = "*" + t
+			}
+			g.printf("%s %s `json:\"%s\"`\n", exported(p), t, tag)
+		}
+		g.printf("}\n\n")
+	}
+}
+
+// pathExpr returns the Go expression building `path` from it's parameters,
+// e.g. `"/v2/users/" + url.PathEscape(id)`
+func (g *generator) pathExpr(path string) string {
+	var parts []string
+	static := ""
+	for _, seg := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		static += "/"
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			g.imports["net/url"] = true
+			parts = append(parts, fmt.Sprintf("%q", static), "url.PathEscape("+unexported(seg[1:len(seg)-1])+")")
+			static = ""
+			continue
+		}
+		static += seg
+	}
+	if len(static) > 0 {
+		parts = append(parts, fmt.Sprintf("%q", static))
+	}
+	return strings.Join(parts, " + ")
+}
+
+func (g *generator) operations(paths map[string]map[string]*operation) {
+	type op struct {
+		method, path string
+		*operation
+	}
+	var ops []op
+	for path, methods := range paths {
+		for method, o := range methods {
+			skip := false
+			for _, tag := range o.Tags {
+				skip = skip || skippedTags[tag]
+			}
+			if !skip {
+				ops = append(ops, op{strings.ToUpper(method), path, o})
+			}
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].OperationID < ops[j].OperationID
+	})
+
+	for _, o := range ops {
+		args := []string{"ctx context.Context"}
+		hasQuery := false
+		for _, p := range o.Parameters {
+			switch p.In {
+			case "path":
+				args = append(args, unexported(p.Name)+" string")
+			case "query":
+				hasQuery = true
+			}
+		}
+		if hasQuery {
+			g.imports["net/url"] = true
+			args = append(args, "query url.Values")
+		}
+		in := "nil"
+		if s := o.RequestBody.schema(); s != nil {
+			args = append(args, "in *"+g.goType(s))
+			in = "in"
+		}
+
+		var out *schema
+		for status, resp := range o.Responses {
+			if strings.HasPrefix(status, "2") {
+				out = resp.schema()
+			}
+		}
+
+		name := unexported(o.OperationID)
+		g.printf("// %s calls `%s %s`. %s\n", name, o.method, o.path, strings.TrimSuffix(o.Summary, "."))
+		if o.Deprecated {
+			g.printf("//\n// Deprecated: The route's API version is deprecated.\n")
+		}
+
+		path := "path := " + g.pathExpr(o.path) + "\n"
+		if hasQuery {
+			path += "if len(query) > 0 {\npath += \"?\" + query.Encode()\n}\n"
+		}
+
+		switch {
+		case out == nil || refName(out.Ref) == plainResponse:
+			g.printf("func (c *Client) %s(%s) error {\n", name, strings.Join(args, ", "))
+			g.printf("%sreturn c.do(ctx, %q, path, %s, nil)\n}\n\n", path, o.method, in)
+		case len(out.Ref) > 0:
+			t := g.goType(out)
+			g.printf("func (c *Client) %s(%s) (*%s, error) {\n", name, strings.Join(args, ", "), t)
+			g.printf("%svar out %s\nerr := c.do(ctx, %q, path, %s, &out)\n", path, t, o.method, in)
+			g.printf("if err != nil {\nreturn nil, err\n}\nreturn &out, nil\n}\n\n")
+		default:
+			t := g.goType(out)
+			g.printf("func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), t)
+			g.printf("%svar out %s\nerr := c.do(ctx, %q, path, %s, &out)\n", path, t, o.method, in)
+			g.printf("return out, err\n}\n\n")
+		}
+	}
+}
+
+// generate returns the formatted source of the client's `openapi.go`
+func generate(doc []byte) ([]byte, error) {
+	var s spec
+	err := json.Unmarshal(doc, &s)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{imports: map[string]bool{"context": true}}
+	g.types(s.Components.Schemas)
+	g.operations(s.Paths)
+
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, fmt.Sprintf("%q", imp))
+	}
+	sort.Strings(imports)
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by client/gen from docs/openapi.json. DO NOT EDIT.\n\n")
+	src.WriteString("package client\n\n")
+	src.WriteString("import (\n" + strings.Join(imports, "\n") + "\n)\n\n")
+	src.Write(g.buf.Bytes())
+	return format.Source(src.Bytes())
+}
+
+func main() {
+	specPath := flag.String("spec", "docs/openapi.json", "path of the OpenAPI document")
+	outPath := flag.String("out", "client/openapi.go", "path of the generated file")
+	flag.Parse()
+
+	doc, err := ioutil.ReadFile(*specPath)
+	if err == nil {
+		var src []byte
+		src, err = generate(doc)
+		if err == nil {
+			err = ioutil.WriteFile(*outPath, src, 0644)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gen:", err)
+		os.Exit(1)
+	}
+}
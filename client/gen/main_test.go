@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestGeneratedClientIsUpToDate(t *testing.T) {
+	doc, err := ioutil.ReadFile("../../docs/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := generate(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile("../openapi.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("client/openapi.go is out of date, run `go generate ./client`")
+	}
+}
+
+func TestExported(t *testing.T) {
+	tests := map[string]string{
+		"refresh_token":    "RefreshToken",
+		"ipv4":             "IPv4",
+		"aes_key":          "AESKey",
+		"PermissionString": "PermissionString",
+		"getV1UserGetIdId": "GetV1UserGetIdId",
+		"target_user_id":   "TargetUserID",
+	}
+	for in, want := range tests {
+		if got := exported(in); got != want {
+			t.Errorf("exported(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
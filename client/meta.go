@@ -0,0 +1,15 @@
+package client
+
+import (
+	"context"
+)
+
+// Errors loads the catalogue of all error codes
+func (c *Client) Errors(ctx context.Context) ([]ErrorInfo, error) {
+	return c.getV2MetaErrors(ctx)
+}
+
+// Version loads vbrest's build version, commit and start time
+func (c *Client) Version(ctx context.Context) (*BuildInfo, error) {
+	return c.getV2MetaVersion(ctx)
+}
@@ -0,0 +1,522 @@
+// Code generated by client/gen from docs/openapi.json. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+// AdminTokenRequest is the `AdminTokenRequest` schema of vbrest's API
+type AdminTokenRequest struct {
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Reason     *string    `json:"reason,omitempty"`
+	UserID     *int       `json:"user_id,omitempty"`
+}
+
+// AdminTokenResponse is the `AdminTokenResponse` schema of vbrest's API
+type AdminTokenResponse struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	JTI       string    `json:"jti"`
+	Token     string    `json:"token"`
+}
+
+// AuditEntry is the `AuditEntry` schema of vbrest's API
+type AuditEntry struct {
+	Action       string            `json:"action"`
+	ActorID      int               `json:"actor_id"`
+	Details      map[string]string `json:"details"`
+	ID           int               `json:"id"`
+	IP           string            `json:"ip"`
+	Reason       string            `json:"reason"`
+	TargetUserID int               `json:"target_user_id"`
+	Time         time.Time         `json:"time"`
+}
+
+// BuildInfo is the `BuildInfo` schema of vbrest's API
+type BuildInfo struct {
+	Commit    string    `json:"commit"`
+	GoVersion string    `json:"go_version"`
+	StartTime time.Time `json:"start_time"`
+	Version   string    `json:"version"`
+}
+
+// Email is the `Email` schema of vbrest's API
+type Email struct {
+	Email  string `json:"Email"`
+	Public bool   `json:"Public"`
+	Status int    `json:"Status"`
+}
+
+// ErrorInfo is the `ErrorInfo` schema of vbrest's API
+type ErrorInfo struct {
+	Code             int    `json:"code"`
+	HTTPStatus       int    `json:"http_status"`
+	LegacyHTTPStatus *int   `json:"legacy_http_status,omitempty"`
+	Message          string `json:"message"`
+}
+
+// ErrorResponse is the `ErrorResponse` schema of vbrest's API
+type ErrorResponse struct {
+	Code       int             `json:"code"`
+	Details    json.RawMessage `json:"details,omitempty"`
+	HTTPStatus int             `json:"http_status"`
+	Message    string          `json:"message"`
+	RequestID  string          `json:"request_id"`
+}
+
+// HealthCheck is the `HealthCheck` schema of vbrest's API
+type HealthCheck struct {
+	Error  *string `json:"error,omitempty"`
+	Status string  `json:"status"`
+}
+
+// HealthResponse is the `HealthResponse` schema of vbrest's API
+type HealthResponse struct {
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+	Status string                 `json:"status"`
+}
+
+// LoginRequest is the `LoginRequest` schema of vbrest's API
+type LoginRequest struct {
+	Password *string `json:"password,omitempty"`
+	Username *string `json:"username,omitempty"`
+}
+
+// OAuthResponse is the `OAuthResponse` schema of vbrest's API
+type OAuthResponse struct {
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at,omitempty"`
+	RefreshToken     *string    `json:"refresh_token,omitempty"`
+	RegisterCode     *string    `json:"register_code,omitempty"`
+	Status           string     `json:"status"`
+	Token            *string    `json:"token,omitempty"`
+	UserID           *int       `json:"user_id,omitempty"`
+}
+
+// OAuthStartResponse is the `OAuthStartResponse` schema of vbrest's API
+type OAuthStartResponse struct {
+	RedirectURL string `json:"redirect_url"`
+	State       string `json:"state"`
+}
+
+// PasswordSetRequest is the `PasswordSetRequest` schema of vbrest's API
+type PasswordSetRequest struct {
+	CurrentPassword *string `json:"current_password,omitempty"`
+	Password        *string `json:"password,omitempty"`
+}
+
+// PermissionSetRequest is the `PermissionSetRequest` schema of vbrest's API
+type PermissionSetRequest struct {
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Permission *string    `json:"permission,omitempty"`
+	Reason     *string    `json:"reason,omitempty"`
+}
+
+// RefreshRequest is the `RefreshRequest` schema of vbrest's API
+type RefreshRequest struct {
+	RefreshToken *string `json:"refresh_token,omitempty"`
+}
+
+// RegisterConfirmRequest is the `RegisterConfirmRequest` schema of vbrest's API
+type RegisterConfirmRequest struct {
+	Code         *string `json:"code,omitempty"`
+	Password     *string `json:"password,omitempty"`
+	Recaptcha    *string `json:"recaptcha,omitempty"`
+	User         *User   `json:"user,omitempty"`
+	Verification *string `json:"verification,omitempty"`
+}
+
+// Round is the `Round` schema of vbrest's API
+type Round struct {
+	ID        int       `json:"id"`
+	Joined    int       `json:"joined"`
+	Max       int       `json:"max"`
+	Min       int       `json:"min"`
+	Name      string    `json:"name"`
+	Starttime time.Time `json:"starttime"`
+	Status    int       `json:"status"`
+	Wallpaper string    `json:"wallpaper"`
+}
+
+// Roundentry is the `Roundentry` schema of vbrest's API
+type Roundentry struct {
+	Authtoken  string    `json:"authtoken"`
+	ID         int       `json:"id"`
+	Joined     int       `json:"joined"`
+	Max        int       `json:"max"`
+	Min        int       `json:"min"`
+	Name       string    `json:"name"`
+	Starttime  time.Time `json:"starttime"`
+	Status     int       `json:"status"`
+	Wallpaper  string    `json:"wallpaper"`
+	Watchtoken string    `json:"watchtoken"`
+}
+
+// RoundentryConnectinfo is the `RoundentryConnectinfo` schema of vbrest's API
+type RoundentryConnectinfo struct {
+	AESKey string `json:"aes_key"`
+	IPv4   string `json:"ipv4"`
+	IPv6   string `json:"ipv6"`
+	Port   int    `json:"port"`
+	Ticket string `json:"ticket"`
+}
+
+// SafeUser is the `SafeUser` schema of vbrest's API
+type SafeUser struct {
+	Bio              string            `json:"bio"`
+	Company          string            `json:"company"`
+	Emails           []Email           `json:"emails"`
+	ID               int               `json:"id"`
+	Location         string            `json:"location"`
+	Name             string            `json:"name"`
+	Permission       int               `json:"permission"`
+	PermissionString string            `json:"permission_string"`
+	Social           map[string]string `json:"social"`
+	Username         string            `json:"username"`
+	Web              []string          `json:"web"`
+}
+
+// Session is the `Session` schema of vbrest's API
+type Session struct {
+	Current   bool      `json:"current"`
+	ExpiresAt time.Time `json:"expires_at"`
+	IP        string    `json:"ip"`
+	IssuedAt  time.Time `json:"issued_at"`
+	JTI       string    `json:"jti"`
+}
+
+// SimpleResponse is the `SimpleResponse` schema of vbrest's API
+type SimpleResponse struct {
+	Response string `json:"response"`
+}
+
+// TokenResponse is the `TokenResponse` schema of vbrest's API
+type TokenResponse struct {
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	Token            string    `json:"token"`
+	UserID           int       `json:"user_id"`
+}
+
+// User is the `User` schema of vbrest's API
+type User struct {
+	Bio              *string           `json:"Bio,omitempty"`
+	Company          *string           `json:"Company,omitempty"`
+	Emails           []Email           `json:"Emails"`
+	ID               *int              `json:"ID,omitempty"`
+	Location         *string           `json:"Location,omitempty"`
+	Name             *string           `json:"Name,omitempty"`
+	OAuth            map[string]string `json:"OAuth"`
+	Permission       *int              `json:"Permission,omitempty"`
+	PermissionString *string           `json:"PermissionString,omitempty"`
+	Social           map[string]string `json:"Social"`
+	Username         *string           `json:"Username,omitempty"`
+	Web              []string          `json:"Web"`
+}
+
+// deleteV1AuthSessionsJti calls `DELETE /v1/auth/sessions/{jti}`. Revoke a session
+func (c *Client) deleteV1AuthSessionsJti(ctx context.Context, jti string) error {
+	path := "/v1/auth/sessions/" + url.PathEscape(jti)
+	return c.do(ctx, "DELETE", path, nil, nil)
+}
+
+// getV1AdminAudit calls `GET /v1/admin/audit`. List the latest audit entries
+func (c *Client) getV1AdminAudit(ctx context.Context, query url.Values) ([]AuditEntry, error) {
+	path := "/v1/admin/audit"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var out []AuditEntry
+	err := c.do(ctx, "GET", path, nil, &out)
+	return out, err
+}
+
+// getV1AuthSessions calls `GET /v1/auth/sessions`. List the authenticated user's sessions
+func (c *Client) getV1AuthSessions(ctx context.Context) ([]Session, error) {
+	path := "/v1/auth/sessions"
+	var out []Session
+	err := c.do(ctx, "GET", path, nil, &out)
+	return out, err
+}
+
+// getV1MetaErrors calls `GET /v1/meta/errors`. List all error codes
+func (c *Client) getV1MetaErrors(ctx context.Context) ([]ErrorInfo, error) {
+	path := "/v1/meta/errors"
+	var out []ErrorInfo
+	err := c.do(ctx, "GET", path, nil, &out)
+	return out, err
+}
+
+// getV1MetaOpenapiJson calls `GET /v1/meta/openapi.json`. Get this OpenAPI document
+func (c *Client) getV1MetaOpenapiJson(ctx context.Context) (map[string]json.RawMessage, error) {
+	path := "/v1/meta/openapi.json"
+	var out map[string]json.RawMessage
+	err := c.do(ctx, "GET", path, nil, &out)
+	return out, err
+}
+
+// getV1MetaVersion calls `GET /v1/meta/version`. Get the build version, commit and start time
+func (c *Client) getV1MetaVersion(ctx context.Context) (*BuildInfo, error) {
+	path := "/v1/meta/version"
+	var out BuildInfo
+	err := c.do(ctx, "GET", path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getV1OauthProviderCallback calls `GET /v1/oauth/{provider}/callback`. Finish an OAuth login
+func (c *Client) getV1OauthProviderCallback(ctx context.Context, provider string, query url.Values) (*OAuthResponse, error) {
+	path := "/v1/oauth/" + url.PathEscape(provider) + "/callback"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var out OAuthResponse
+	err := c.do(ctx, "GET", path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getV1OauthProviderStart calls `GET /v1/oauth/{provider}/start`. Start an OAuth login
+func (c *Client) getV1OauthProviderStart(ctx context.Context, provider string) (*OAuthStartResponse, error) {
+	path := "/v1/oauth/" + url.PathEscape(provider) + "/start"
+	var out OAuthStartResponse
+	err := c.do(ctx, "GET", path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getV1RoundActive calls `GET /v1/round/active`. List all active rounds
+func (c *Client) getV1RoundActive(ctx context.Context) ([]Round, error) {
+	path := "/v1/round/active"
+	var out []Round
+	err := c.do(ctx, "GET", path, nil, &out)
+	return out, err
+}
+
+// getV1RoundentryActive calls `GET /v1/roundentry/active`. List the authenticated user's entries of active rounds
+func (c *Client) getV1RoundentryActive(ctx context.Context) ([]Roundentry, error) {
+	path := "/v1/roundentry/active"
+	var out []Roundentry
+	err := c.do(ctx, "GET", path, nil, &out)
+	return out, err
+}
+
+// getV1RoundentryConnectinfoAuthtoken calls `GET /v1/roundentry/connectinfo/{authtoken}`. Resolve an authtoken to the game server's connect info
+func (c *Client) getV1RoundentryConnectinfoAuthtoken(ctx context.Context, authtoken string) (*RoundentryConnectinfo, error) {
+	path := "/v1/roundentry/connectinfo/" + url.PathEscape(authtoken)
+	var out RoundentryConnectinfo
+	err := c.do(ctx, "GET", path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getV1RoundentryWatchresolveWatchtoken calls `GET /v1/roundentry/watchresolve/{watchtoken}`. Resolve a watchtoken to the game server's websocket
+func (c *Client) getV1RoundentryWatchresolveWatchtoken(ctx context.Context, watchtoken string) (string, error) {
+	path := "/v1/roundentry/watchresolve/" + url.PathEscape(watchtoken)
+	var out string
+	err := c.do(ctx, "GET", path, nil, &out)
+	return out, err
+}
+
+// getV1Test calls `GET /v1/test`. Check if the service is reachable
+func (c *Client) getV1Test(ctx context.Context) error {
+	path := "/v1/test"
+	return c.do(ctx, "GET", path, nil, nil)
+}
+
+// getV1UserGet calls `GET /v1/user/get`. Get the authenticated user
+func (c *Client) getV1UserGet(ctx context.Context) (*SafeUser, error) {
+	path := "/v1/user/get"
+	var out SafeUser
+	err := c.do(ctx, "GET", path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getV1UserGetIdId calls `GET /v1/user/get/id/{id}`. Get the public profile of a user by id
+func (c *Client) getV1UserGetIdId(ctx context.Context, id string) (*SafeUser, error) {
+	path := "/v1/user/get/id/" + url.PathEscape(id)
+	var out SafeUser
+	err := c.do(ctx, "GET", path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getV1UserGetUsernameUsername calls `GET /v1/user/get/username/{username}`. Get the public profile of a user by username
+func (c *Client) getV1UserGetUsernameUsername(ctx context.Context, username string) (*SafeUser, error) {
+	path := "/v1/user/get/username/" + url.PathEscape(username)
+	var out SafeUser
+	err := c.do(ctx, "GET", path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getV2MetaErrors calls `GET /v2/meta/errors`. List all error codes
+func (c *Client) getV2MetaErrors(ctx context.Context) ([]ErrorInfo, error) {
+	path := "/v2/meta/errors"
+	var out []ErrorInfo
+	err := c.do(ctx, "GET", path, nil, &out)
+	return out, err
+}
+
+// getV2MetaOpenapiJson calls `GET /v2/meta/openapi.json`. Get this OpenAPI document
+func (c *Client) getV2MetaOpenapiJson(ctx context.Context) (map[string]json.RawMessage, error) {
+	path := "/v2/meta/openapi.json"
+	var out map[string]json.RawMessage
+	err := c.do(ctx, "GET", path, nil, &out)
+	return out, err
+}
+
+// getV2MetaVersion calls `GET /v2/meta/version`. Get the build version, commit and start time
+func (c *Client) getV2MetaVersion(ctx context.Context) (*BuildInfo, error) {
+	path := "/v2/meta/version"
+	var out BuildInfo
+	err := c.do(ctx, "GET", path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getV2RoundentriesActive calls `GET /v2/roundentries/active`. List the authenticated user's entries of active rounds
+func (c *Client) getV2RoundentriesActive(ctx context.Context) ([]Roundentry, error) {
+	path := "/v2/roundentries/active"
+	var out []Roundentry
+	err := c.do(ctx, "GET", path, nil, &out)
+	return out, err
+}
+
+// getV2RoundsActive calls `GET /v2/rounds/active`. List all active rounds
+func (c *Client) getV2RoundsActive(ctx context.Context) ([]Round, error) {
+	path := "/v2/rounds/active"
+	var out []Round
+	err := c.do(ctx, "GET", path, nil, &out)
+	return out, err
+}
+
+// getV2UsersId calls `GET /v2/users/{id}`. Get the public profile of a user
+func (c *Client) getV2UsersId(ctx context.Context, id string) (*SafeUser, error) {
+	path := "/v2/users/" + url.PathEscape(id)
+	var out SafeUser
+	err := c.do(ctx, "GET", path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getV2UsersMe calls `GET /v2/users/me`. Get the authenticated user
+func (c *Client) getV2UsersMe(ctx context.Context) (*SafeUser, error) {
+	path := "/v2/users/me"
+	var out SafeUser
+	err := c.do(ctx, "GET", path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// postV1AdminTokens calls `POST /v1/admin/tokens`. Issue a token for a user
+func (c *Client) postV1AdminTokens(ctx context.Context, in *AdminTokenRequest) (*AdminTokenResponse, error) {
+	path := "/v1/admin/tokens"
+	var out AdminTokenResponse
+	err := c.do(ctx, "POST", path, in, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// postV1AuthLogin calls `POST /v1/auth/login`. Log in with username and password
+func (c *Client) postV1AuthLogin(ctx context.Context, in *LoginRequest) (*TokenResponse, error) {
+	path := "/v1/auth/login"
+	var out TokenResponse
+	err := c.do(ctx, "POST", path, in, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// postV1AuthLogout calls `POST /v1/auth/logout`. Revoke the tokens of the current session
+func (c *Client) postV1AuthLogout(ctx context.Context) error {
+	path := "/v1/auth/logout"
+	return c.do(ctx, "POST", path, nil, nil)
+}
+
+// postV1AuthRefresh calls `POST /v1/auth/refresh`. Exchange a refresh token for new tokens. Browsers can send the vbrefresh cookie instead of a body
+func (c *Client) postV1AuthRefresh(ctx context.Context, in *RefreshRequest) (*TokenResponse, error) {
+	path := "/v1/auth/refresh"
+	var out TokenResponse
+	err := c.do(ctx, "POST", path, in, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// postV1RegisterConfirm calls `POST /v1/register/confirm`. Finish a pending registration
+func (c *Client) postV1RegisterConfirm(ctx context.Context, in *RegisterConfirmRequest) error {
+	path := "/v1/register/confirm"
+	return c.do(ctx, "POST", path, in, nil)
+}
+
+// postV1RoundJoinId calls `POST /v1/round/join/{id}`. Join a round
+func (c *Client) postV1RoundJoinId(ctx context.Context, id string) error {
+	path := "/v1/round/join/" + url.PathEscape(id)
+	return c.do(ctx, "POST", path, nil, nil)
+}
+
+// postV1UserUpdate calls `POST /v1/user/update`. Update the authenticated user's profile
+func (c *Client) postV1UserUpdate(ctx context.Context, in *User) error {
+	path := "/v1/user/update"
+	return c.do(ctx, "POST", path, in, nil)
+}
+
+// postV2RoundsIdEntries calls `POST /v2/rounds/{id}/entries`. Join a round
+func (c *Client) postV2RoundsIdEntries(ctx context.Context, id string) (*Roundentry, error) {
+	path := "/v2/rounds/" + url.PathEscape(id) + "/entries"
+	var out Roundentry
+	err := c.do(ctx, "POST", path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// putV1AdminUsersIdPermission calls `PUT /v1/admin/users/{id}/permission`. Change the permission of a user or ban them
+func (c *Client) putV1AdminUsersIdPermission(ctx context.Context, id string, in *PermissionSetRequest) error {
+	path := "/v1/admin/users/" + url.PathEscape(id) + "/permission"
+	return c.do(ctx, "PUT", path, in, nil)
+}
+
+// putV1AuthPassword calls `PUT /v1/auth/password`. Set or change the authenticated user's password
+func (c *Client) putV1AuthPassword(ctx context.Context, in *PasswordSetRequest) error {
+	path := "/v1/auth/password"
+	return c.do(ctx, "PUT", path, in, nil)
+}
+
+// putV2UsersMe calls `PUT /v2/users/me`. Update the authenticated user's profile
+func (c *Client) putV2UsersMe(ctx context.Context, in *User) error {
+	path := "/v2/users/me"
+	return c.do(ctx, "PUT", path, in, nil)
+}
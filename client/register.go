@@ -0,0 +1,10 @@
+package client
+
+import (
+	"context"
+)
+
+// RegisterConfirm finishes a pending registration
+func (c *Client) RegisterConfirm(ctx context.Context, data RegisterConfirmRequest) error {
+	return c.postV1RegisterConfirm(ctx, &data)
+}
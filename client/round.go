@@ -0,0 +1,17 @@
+package client
+
+import (
+	"context"
+	"strconv"
+)
+
+// RoundActive loads all active rounds
+func (c *Client) RoundActive(ctx context.Context) ([]Round, error) {
+	return c.getV2RoundsActive(ctx)
+}
+
+// RoundJoin adds the authenticated user to the round `roundID` and returns
+// the created roundentry. Joining isn't idempotent, so it's never retried.
+func (c *Client) RoundJoin(ctx context.Context, roundID int) (*Roundentry, error) {
+	return c.postV2RoundsIdEntries(ctx, strconv.Itoa(roundID))
+}
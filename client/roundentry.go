@@ -0,0 +1,22 @@
+package client
+
+import (
+	"context"
+)
+
+// RoundentryActive loads the authenticated user's entries of active rounds
+func (c *Client) RoundentryActive(ctx context.Context) ([]Roundentry, error) {
+	return c.getV2RoundentriesActive(ctx)
+}
+
+// RoundentryConnectinfo resolves an authtoken to the connect info of the
+// game server
+func (c *Client) RoundentryConnectinfo(ctx context.Context, authtoken string) (*RoundentryConnectinfo, error) {
+	return c.getV1RoundentryConnectinfoAuthtoken(ctx, authtoken)
+}
+
+// RoundentryWatchresolve resolves a watchtoken to the websocket address of
+// the game server
+func (c *Client) RoundentryWatchresolve(ctx context.Context, watchtoken string) (websocket string, err error) {
+	return c.getV1RoundentryWatchresolveWatchtoken(ctx, watchtoken)
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+	"strconv"
+)
+
+// UserGet loads the authenticated user
+func (c *Client) UserGet(ctx context.Context) (*SafeUser, error) {
+	return c.getV2UsersMe(ctx)
+}
+
+// UserGetPublicByID loads the public profile of the user `userID`
+func (c *Client) UserGetPublicByID(ctx context.Context, userID int) (*SafeUser, error) {
+	return c.getV2UsersId(ctx, strconv.Itoa(userID))
+}
+
+// UserGetPublicByUsername loads the public profile of the user `username`
+func (c *Client) UserGetPublicByUsername(ctx context.Context, username string) (*SafeUser, error) {
+	return c.getV1UserGetUsernameUsername(ctx, username)
+}
+
+// UserUpdate updates the authenticated user's profile. Only the set fields
+// of `user` are changed.
+func (c *Client) UserUpdate(ctx context.Context, user *User) error {
+	return c.putV2UsersMe(ctx, user)
+}
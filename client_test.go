@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/vikebot/vbrest/client"
+	"github.com/vikebot/vbrest/vbapi"
+)
+
+// newTestClient serves vbrest on a loopback port and returns a client of it
+func newTestClient(t *testing.T, config client.Config) *client.Client {
+	t.Helper()
+
+	server := newTestServer(t, nil)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(ln)
+
+	// Idle keep-alive connections would block the shutdown until they time
+	// out, so they are closed first
+	transport := &http.Transport{}
+	t.Cleanup(func() {
+		transport.CloseIdleConnections()
+		server.Shutdown()
+	})
+
+	config.BaseURL = "http://" + ln.Addr().String()
+	config.HTTPClient = &http.Client{Transport: transport}
+	c, err := client.New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// wantCode fails unless `err` is a vbrest error with `code`
+func wantCode(t *testing.T, err error, code int) {
+	t.Helper()
+	e, ok := err.(*client.Error)
+	if !ok {
+		t.Fatalf("want error %d but got %v", code, err)
+	}
+	if e.Code() != code || len(e.RequestID) == 0 {
+		t.Fatalf("want error %d with request id but got %d (%q)", code, e.Code(), e.RequestID)
+	}
+}
+
+func TestClientUser(t *testing.T) {
+	c := newTestClient(t, client.Config{})
+	ctx := context.Background()
+
+	_, err := c.UserGet(ctx)
+	wantCode(t, err, errNoAuthProvided.Code())
+
+	tokens, err := c.Login(ctx, "alice", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := c.UserGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != tokens.UserID || user.Username != "alice" {
+		t.Fatalf("want alice (%d) but got %q (%d)", tokens.UserID, user.Username, user.ID)
+	}
+
+	name := "Alice Liddell"
+	err = c.UserUpdate(ctx, &client.User{Name: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := c.UserGetPublicByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if public.Name != name {
+		t.Fatalf("want %q but got %q", name, public.Name)
+	}
+
+	tooLong := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	err = c.UserUpdate(ctx, &client.User{Name: &tooLong})
+	wantCode(t, err, 11012)
+
+	err = c.Logout(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.UserGet(ctx)
+	wantCode(t, err, errNoAuthProvided.Code())
+}
+
+func TestClientRound(t *testing.T) {
+	c := newTestClient(t, client.Config{Cookie: true})
+	ctx := context.Background()
+
+	_, err := c.Login(ctx, "alice", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rounds, err := c.RoundActive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rounds) != 1 || rounds[0].Name != "Arena" {
+		t.Fatalf("want the seeded round but got %v", rounds)
+	}
+
+	entry, err := c.RoundJoin(ctx, rounds[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.RoundJoin(ctx, rounds[0].ID)
+	wantCode(t, err, 11008)
+
+	entries, err := c.RoundentryActive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Authtoken != entry.Authtoken {
+		t.Fatalf("want the joined entry but got %v", entries)
+	}
+
+	info, err := c.RoundentryConnectinfo(ctx, entry.Authtoken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.IPv4 != "198.51.100.7" || info.Port != 2400 || len(info.Ticket) == 0 {
+		t.Fatalf("want the seeded gameserver but got %+v", info)
+	}
+
+	websocket, err := c.RoundentryWatchresolve(ctx, entry.Watchtoken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if websocket != "198.51.100.7:2401" {
+		t.Fatalf("want the seeded websocket but got %q", websocket)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	c := newTestClient(t, client.Config{})
+
+	errs, err := c.Errors(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != len(vbapi.RegisteredErrors()) {
+		t.Fatalf("want %d errors but got %d", len(vbapi.RegisteredErrors()), len(errs))
+	}
+}
@@ -2,31 +2,130 @@ package main
 
 type conf struct {
 	Addr string `json:"addr"`
-	TLS  struct {
+	// Server holds durations as accepted by `time.ParseDuration`. Empty
+	// values use the defaults.
+	Server struct {
+		// ReadTimeout also closes idle keep-alive connections, so it bounds
+		// how long draining can be blocked by them
+		ReadTimeout string `json:"read_timeout"`
+		// DrainTimeout is how long in-flight requests can take to finish
+		// after a SIGTERM or SIGINT
+		DrainTimeout string `json:"drain_timeout"`
+		// ReadyDelay is how long vbrest reports unready before it starts
+		// draining
+		ReadyDelay string `json:"ready_delay"`
+		// TrustedProxies are the IP addresses or CIDR networks of the load
+		// balancers in front of vbrest. `X-Forwarded-For` is ignored for all
+		// other connections.
+		TrustedProxies []string `json:"trusted_proxies"`
+	} `json:"server"`
+	Log struct {
+		// Format is "console" (default) or "json"
+		Format string `json:"format"`
+		// Level is the minimum level logged: "debug", "info" (default),
+		// "warn" or "error"
+		Level string `json:"level"`
+		// File.Path writes logs to this file instead of stdout. It's rotated
+		// once it reaches File.MaxSizeMB (default 100). Zero MaxBackups and
+		// MaxAgeDays keep all rotated files.
+		File struct {
+			Path       string `json:"path"`
+			MaxSizeMB  int    `json:"max_size_mb"`
+			MaxBackups int    `json:"max_backups"`
+			MaxAgeDays int    `json:"max_age_days"`
+			Compress   bool   `json:"compress"`
+		} `json:"file"`
+	} `json:"log"`
+	TLS struct {
 		Active bool   `json:"active"`
 		Cert   string `json:"cert"`
 		Key    string `json:"key"`
 	} `json:"tls"`
 	DB struct {
-		Addr string `json:"addr"`
-		User string `json:"user"`
-		Pass string `json:"pass"`
-		Name string `json:"name"`
+		Driver string `json:"driver"`
+		Seed   string `json:"seed"`
+		Addr   string `json:"addr"`
+		User   string `json:"user"`
+		Pass   string `json:"pass"`
+		Name   string `json:"name"`
 	} `json:"db"`
 	CORS struct {
-		Enabled        bool     `json:"enabled"`
-		Wildcard       bool     `json:"wildcard"`
+		Enabled  bool `json:"enabled"`
+		Wildcard bool `json:"wildcard"`
+		// AllowedDomains are exact origins (`https://app.vikebot.com`) or
+		// subdomain patterns (`https://*.vikebot.com`)
 		AllowedDomains []string `json:"allowed_domains"`
+		// AllowCredentials lets browsers send cookies. Defaults to true and
+		// must be disabled for wildcard.
+		AllowCredentials *bool `json:"allow_credentials"`
+		// ExposedHeaders are the response headers scripts can read
+		ExposedHeaders []string `json:"exposed_headers"`
+		// MaxAge is how long browsers cache preflight responses as accepted
+		// by `time.ParseDuration`. Defaults to 24h.
+		MaxAge string `json:"max_age"`
 	} `json:"cors"`
 	JWT struct {
 		ProductionIsssuer   bool              `json:"production_issuer"`
 		DefaultSigningKeyID string            `json:"default_signing_key_id"`
 		SigningKeys         map[string]string `json:"signing_keys"`
+		// AccessLifetime and RefreshLifetime are durations as accepted by
+		// `time.ParseDuration`
+		AccessLifetime  string `json:"access_lifetime"`
+		RefreshLifetime string `json:"refresh_lifetime"`
 	} `json:"jwt"`
+	// OAuth maps provider names ("github", "google") to their config.
+	// Providers without client_id are disabled.
+	OAuth     map[string]oauthConf `json:"oauth"`
 	Recaptcha struct {
 		Secret string `json:"secret"`
 	} `json:"recaptcha"`
 	Sendgrid struct {
 		Secret string `json:"secret"`
 	} `json:"sendgrid"`
+	Metrics struct {
+		// Statsd.Addr is the statsd server as host:port. Empty disables
+		// statsd.
+		Statsd struct {
+			Addr   string `json:"addr"`
+			Prefix string `json:"prefix"`
+		} `json:"statsd"`
+		// Prometheus.Enabled exposes all metrics at `/metrics`
+		Prometheus struct {
+			Enabled bool `json:"enabled"`
+		} `json:"prometheus"`
+	} `json:"metrics"`
+	// Tracing configures the export of spans. Incoming `traceparent` headers
+	// are honoured even if no exporter is set.
+	Tracing struct {
+		// Exporter is "stderr", "otlp" or empty to disable the export
+		Exporter string `json:"exporter"`
+		// Endpoint is the OTLP/HTTP traces URL, e.g.
+		// `http://localhost:4318/v1/traces`
+		Endpoint string            `json:"endpoint"`
+		Headers  map[string]string `json:"headers"`
+		// SampleRatio is the fraction of new traces which are recorded.
+		// Defaults to 1.
+		SampleRatio *float64 `json:"sample_ratio"`
+	} `json:"tracing"`
+	// Versions maps API versions ("v1", "v2") to their deprecation schedule
+	Versions map[string]versionConf `json:"versions"`
+}
+
+// versionConf holds the deprecation schedule of an API version. Both times
+// are optional and formatted as RFC 3339.
+type versionConf struct {
+	Deprecation string `json:"deprecation"`
+	Sunset      string `json:"sunset"`
+}
+
+// oauthConf configures a single OAuth provider. The *_url endpoints are
+// optional and only needed to point vbrest to a fake provider.
+type oauthConf struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	AuthURL      string   `json:"auth_url"`
+	TokenURL     string   `json:"token_url"`
+	UserURL      string   `json:"user_url"`
+	Scopes       []string `json:"scopes"`
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbrest/vbapi"
+)
+
+const (
+	authCookieName    = "vbauth"
+	refreshCookieName = "vbrefresh"
+	// refreshCookiePath limits the refresh cookie to the only endpoint
+	// consuming it
+	refreshCookiePath = "/v1/auth/refresh"
+	oauthCookieName   = "vboauth"
+	oauthCookiePath   = "/v1/oauth"
+	// oauthCookieMaxAge equals the time users have to authorize vikebot at
+	// the provider
+	oauthCookieMaxAge = 10 * time.Minute
+)
+
+// setAuthCookie stores the JWT in the `vbauth` cookie. A `maxAge` of zero or
+// less deletes the cookie.
+func setAuthCookie(req *request, token string, maxAge time.Duration) {
+	setCookie(req, authCookieName, token, "/", "Strict", maxAge)
+}
+
+// setRefreshCookie stores the refresh token in the `vbrefresh` cookie. The
+// cookie is only sent to `/v1/auth/refresh`. A `maxAge` of zero or less
+// deletes the cookie.
+func setRefreshCookie(req *request, token string, maxAge time.Duration) {
+	setCookie(req, refreshCookieName, token, refreshCookiePath, "Strict", maxAge)
+}
+
+// setOAuthCookie binds a started OAuth flow to the client by storing it's
+// state. It uses SameSite=Lax, because the provider redirects the user back
+// cross-site. A `maxAge` of zero or less deletes the cookie.
+func setOAuthCookie(req *request, state string, maxAge time.Duration) {
+	setCookie(req, oauthCookieName, state, oauthCookiePath, "Lax", maxAge)
+}
+
+// setTokenCookies stores both tokens of a login or refresh response
+func setTokenCookies(req *request, resp *vbapi.TokenResponse) {
+	setAuthCookie(req, resp.Token, time.Until(resp.ExpiresAt))
+	setRefreshCookie(req, resp.RefreshToken, time.Until(resp.RefreshExpiresAt))
+}
+
+// clearTokenCookies deletes both token cookies
+func clearTokenCookies(req *request) {
+	setAuthCookie(req, "", 0)
+	setRefreshCookie(req, "", 0)
+}
+
+// setCookie sets a cookie that is never readable by scripts and only sent
+// over TLS. `sameSite` restricts when it is attached to cross-site requests.
+func setCookie(req *request, name, value, path, sameSite string, maxAge time.Duration) {
+	c := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(c)
+
+	c.SetKey(name)
+	c.SetValue(value)
+	c.SetPath(path)
+	if maxAge > 0 {
+		c.SetMaxAge(int(maxAge.Seconds()))
+	} else {
+		c.SetExpire(fasthttp.CookieExpireDelete)
+	}
+	c.SetSecure(true)
+	c.SetHTTPOnly(true)
+
+	// fasthttp doesn't support the SameSite attribute yet, so append it
+	// ourself
+	req.Response.Header.Add("Set-Cookie", string(c.Cookie())+"; SameSite="+sameSite)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultCORSMaxAge = 24 * time.Hour
+
+var (
+	// defaultCORSExposedHeaders are the response headers browsers let
+	// scripts read if the config doesn't list any
+	defaultCORSExposedHeaders = []string{requestIDHeader, "Deprecation", "Sunset", "Allow"}
+	// corsAllowedHeaders are the request headers clients may send
+	corsAllowedHeaders = []string{"Authorization", "Content-Type", requestIDHeader, statusPolicyHeader, "traceparent", "X-PINGOTHER"}
+)
+
+// originPattern matches all subdomains of `suffix`, e.g. the pattern
+// `https://*.vikebot.com` matches `https://app.vikebot.com` but neither
+// `https://vikebot.com` nor `http://app.vikebot.com`
+type originPattern struct {
+	scheme string
+	// suffix starts with a dot and includes the port if there is one
+	suffix string
+}
+
+func (p originPattern) match(origin string) bool {
+	prefix := p.scheme + "://"
+	if !strings.HasPrefix(origin, prefix) {
+		return false
+	}
+	host := origin[len(prefix):]
+	return len(host) > len(p.suffix) && strings.HasSuffix(host, p.suffix) && !strings.ContainsAny(host, "/?#@")
+}
+
+// corsPolicy decides which browser origins may access vbrest and with which
+// headers
+type corsPolicy struct {
+	enabled     bool
+	wildcard    bool
+	credentials bool
+	origins     map[string]bool
+	patterns    []originPattern
+
+	exposedHeaders string
+	allowedHeaders string
+	maxAge         string
+}
+
+// newCORSPolicy validates the CORS config. It refuses to combine wildcard
+// origins with credentials, because any site could then act with the
+// cookies of vbrest's users.
+func newCORSPolicy(config *conf) (*corsPolicy, error) {
+	c := config.CORS
+	p := &corsPolicy{
+		enabled:     c.Enabled,
+		wildcard:    c.Wildcard,
+		credentials: c.AllowCredentials == nil || *c.AllowCredentials,
+		origins:     make(map[string]bool),
+	}
+	if !p.enabled {
+		return p, nil
+	}
+	if p.wildcard && p.credentials {
+		return nil, fmt.Errorf("cors wildcard can't be combined with credentials. set allow_credentials to false or list the allowed domains")
+	}
+
+	for _, origin := range c.AllowedDomains {
+		origin = strings.ToLower(origin)
+		if !strings.Contains(origin, "*") {
+			if err := validateOrigin(origin); err != nil {
+				return nil, err
+			}
+			p.origins[origin] = true
+			continue
+		}
+
+		pattern, err := parseOriginPattern(origin)
+		if err != nil {
+			return nil, err
+		}
+		p.patterns = append(p.patterns, pattern)
+	}
+
+	exposed := c.ExposedHeaders
+	if len(exposed) == 0 {
+		exposed = defaultCORSExposedHeaders
+	}
+	p.exposedHeaders = strings.Join(exposed, ", ")
+	p.allowedHeaders = strings.Join(corsAllowedHeaders, ", ")
+
+	maxAge, err := parseDuration(c.MaxAge, defaultCORSMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cors max_age: %v", err)
+	}
+	p.maxAge = strconv.Itoa(int(maxAge.Seconds()))
+	return p, nil
+}
+
+// validateOrigin checks that `origin` has the form `scheme://host[:port]`
+func validateOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 ||
+		len(u.Path) > 0 || len(u.RawQuery) > 0 || len(u.Fragment) > 0 || u.User != nil {
+		return fmt.Errorf("cors origin '%s' must have the form scheme://host[:port]", origin)
+	}
+	return nil
+}
+
+// parseOriginPattern parses `scheme://*.domain[:port]`. The wildcard is only
+// allowed as the leftmost label.
+func parseOriginPattern(origin string) (originPattern, error) {
+	invalid := fmt.Errorf("cors origin pattern '%s' must have the form scheme://*.domain[:port]", origin)
+
+	i := strings.Index(origin, "://*.")
+	if i <= 0 || strings.Count(origin, "*") != 1 {
+		return originPattern{}, invalid
+	}
+	p := originPattern{
+		scheme: origin[:i],
+		suffix: origin[i+len("://*"):],
+	}
+	if err := validateOrigin(p.scheme + "://x" + p.suffix); err != nil {
+		return originPattern{}, invalid
+	}
+	return p, nil
+}
+
+// allowed reports whether `origin` may access vbrest
+func (p *corsPolicy) allowed(origin string) bool {
+	if len(origin) == 0 {
+		return false
+	}
+	if p.wildcard {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, pattern := range p.patterns {
+		if pattern.match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// setOrigin adds the headers granting the request's origin access. Returns
+// false if the origin isn't allowed.
+func (p *corsPolicy) setOrigin(req *request) bool {
+	// The response depends on the origin unless every origin gets `*`, so
+	// caches must not share it between origins
+	if !p.wildcard {
+		req.Response.Header.Add("Vary", "Origin")
+	}
+
+	origin := string(req.Request.Header.Peek("Origin"))
+	if !p.allowed(origin) {
+		return false
+	}
+
+	if p.wildcard {
+		req.Response.Header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		req.Response.Header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		req.Response.Header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// cors adds the CORS response headers if the request's origin is allowed by
+// the policy
+func cors(p *corsPolicy) middleware {
+	return func(next handler) handler {
+		if !p.enabled {
+			return next
+		}
+
+		return func(req *request) (interface{}, error) {
+			if string(req.Method()) != "OPTIONS" && p.setOrigin(req) {
+				req.Response.Header.Set("Access-Control-Expose-Headers", p.exposedHeaders)
+			}
+			return next(req)
+		}
+	}
+}
+
+// preflight answers OPTIONS requests for all known routes without invoking
+// their handlers. The allowed methods are the ones the route is registered
+// with.
+// https://developer.mozilla.org/en-US/docs/Glossary/Preflight_request
+func preflight(rt *router, p *corsPolicy) middleware {
+	return func(next handler) handler {
+		return func(req *request) (interface{}, error) {
+			if string(req.Method()) != "OPTIONS" {
+				return next(req)
+			}
+
+			_, route, _, allowed, found := rt.lookup("OPTIONS", string(req.Path()))
+			if !found {
+				return nil, errUnknownEndpoit
+			}
+			req.Route = route
+			methods := strings.Join(append(allowed, "OPTIONS"), ", ")
+			req.Response.Header.Set("Allow", methods)
+
+			if p.enabled && p.setOrigin(req) {
+				req.Response.Header.Set("Access-Control-Allow-Methods", methods)
+				req.Response.Header.Set("Access-Control-Allow-Headers", p.allowedHeaders)
+				req.Response.Header.Set("Access-Control-Max-Age", p.maxAge)
+			}
+			return nil, nil
+		}
+	}
+}
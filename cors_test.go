@@ -0,0 +1,186 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// corsConfig returns a test config allowing `origins` with credentials
+func corsConfig(t *testing.T, origins ...string) *conf {
+	t.Helper()
+	config := testConfig(t)
+	config.CORS.Enabled = true
+	config.CORS.AllowedDomains = origins
+	return config
+}
+
+func TestCORSOrigins(t *testing.T) {
+	server := newTestServer(t, corsConfig(t, "https://app.vikebot.com", "https://*.vikebot.dev"))
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.vikebot.com", true},
+		{"HTTPS://APP.VIKEBOT.COM", true},
+		{"https://www.vikebot.com", false},
+		{"http://app.vikebot.com", false},
+		{"https://app.vikebot.com:8443", false},
+		{"https://beta.vikebot.dev", true},
+		{"https://a.b.vikebot.dev", true},
+		{"https://vikebot.dev", false},
+		{"http://beta.vikebot.dev", false},
+		{"https://evilvikebot.dev", false},
+		{"https://beta.vikebot.dev.evil.com", false},
+		{"https://evil.com/.vikebot.dev", false},
+		{"https://evil.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			resp := do(server, testRequest{method: "GET", path: "/v1/meta/version", header: map[string]string{"Origin": tt.origin}})
+			if resp.StatusCode() != fasthttp.StatusOK {
+				t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+			}
+			if vary := string(resp.Header.Peek("Vary")); vary != "Origin" {
+				t.Errorf("want Vary: Origin but got %q", vary)
+			}
+
+			allowOrigin := string(resp.Header.Peek("Access-Control-Allow-Origin"))
+			credentials := string(resp.Header.Peek("Access-Control-Allow-Credentials"))
+			exposed := string(resp.Header.Peek("Access-Control-Expose-Headers"))
+			if !tt.allowed {
+				if len(allowOrigin) > 0 || len(credentials) > 0 || len(exposed) > 0 {
+					t.Fatalf("unlisted origin got CORS headers %q, %q, %q", allowOrigin, credentials, exposed)
+				}
+				return
+			}
+			if allowOrigin != tt.origin || credentials != "true" {
+				t.Fatalf("want origin %q with credentials but got %q, %q", tt.origin, allowOrigin, credentials)
+			}
+			if exposed != "X-Request-ID, Deprecation, Sunset, Allow" {
+				t.Fatalf("unexpected exposed headers %q", exposed)
+			}
+		})
+	}
+}
+
+func TestCORSVaryOnEveryResponse(t *testing.T) {
+	server := newTestServer(t, corsConfig(t, "https://app.vikebot.com"))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		origin string
+	}{
+		{"no origin", "GET", "/v1/meta/version", ""},
+		{"error", "GET", "/v1/user/get", "https://app.vikebot.com"},
+		{"unlisted origin error", "GET", "/v1/user/get", "https://evil.com"},
+		{"preflight", "OPTIONS", "/v1/user/get", "https://app.vikebot.com"},
+		{"unlisted origin preflight", "OPTIONS", "/v1/user/get", "https://evil.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(server, testRequest{method: tt.method, path: tt.path, header: map[string]string{"Origin": tt.origin}})
+			if vary := string(resp.Header.Peek("Vary")); vary != "Origin" {
+				t.Fatalf("want Vary: Origin but got %q", vary)
+			}
+		})
+	}
+}
+
+func TestCORSExposedHeaders(t *testing.T) {
+	config := corsConfig(t, "https://app.vikebot.com")
+	config.CORS.ExposedHeaders = []string{"X-Request-ID", "X-RateLimit-Remaining"}
+	server := newTestServer(t, config)
+
+	resp := do(server, testRequest{method: "GET", path: "/v1/meta/version", header: map[string]string{"Origin": "https://app.vikebot.com"}})
+	if exposed := string(resp.Header.Peek("Access-Control-Expose-Headers")); exposed != "X-Request-ID, X-RateLimit-Remaining" {
+		t.Fatalf("unexpected exposed headers %q", exposed)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	server := newTestServer(t, corsConfig(t, "https://app.vikebot.com"))
+
+	tests := []struct {
+		path    string
+		methods string
+	}{
+		{"/v1/user/get", "GET, HEAD, OPTIONS"},
+		{"/v1/user/update", "POST, OPTIONS"},
+		{"/v2/users/me", "GET, HEAD, PUT, OPTIONS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp := do(server, testRequest{method: "OPTIONS", path: tt.path, header: map[string]string{"Origin": "https://app.vikebot.com"}})
+			if resp.StatusCode() != fasthttp.StatusOK {
+				t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+			}
+			if methods := string(resp.Header.Peek("Access-Control-Allow-Methods")); methods != tt.methods {
+				t.Fatalf("want methods %q but got %q", tt.methods, methods)
+			}
+			if allow := string(resp.Header.Peek("Allow")); allow != tt.methods {
+				t.Fatalf("want Allow %q but got %q", tt.methods, allow)
+			}
+			if headers := string(resp.Header.Peek("Access-Control-Allow-Headers")); !strings.Contains(headers, "Authorization") {
+				t.Fatalf("Authorization isn't allowed: %q", headers)
+			}
+			if maxAge := string(resp.Header.Peek("Access-Control-Max-Age")); maxAge != "86400" {
+				t.Fatalf("want max age 86400 but got %q", maxAge)
+			}
+		})
+	}
+
+	// Unlisted origins only learn the allowed methods from `Allow`
+	resp := do(server, testRequest{method: "OPTIONS", path: "/v1/user/get", header: map[string]string{"Origin": "https://evil.com"}})
+	if methods := resp.Header.Peek("Access-Control-Allow-Methods"); methods != nil {
+		t.Fatalf("unlisted origin got methods %q", methods)
+	}
+
+	resp = do(server, testRequest{method: "OPTIONS", path: "/v1/unknown", header: map[string]string{"Origin": "https://app.vikebot.com"}})
+	if code := errorCode(t, resp); code != 9001 {
+		t.Fatalf("want unknown endpoint (9001) but got %d", code)
+	}
+}
+
+func TestCORSPolicyRejectsWildcardWithCredentials(t *testing.T) {
+	no := false
+	yes := true
+	tests := []struct {
+		name        string
+		credentials *bool
+		wantErr     bool
+	}{
+		{"default credentials", nil, true},
+		{"credentials", &yes, true},
+		{"without credentials", &no, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &conf{}
+			config.CORS.Enabled = true
+			config.CORS.Wildcard = true
+			config.CORS.AllowCredentials = tt.credentials
+
+			_, err := newCORSPolicy(config)
+			if tt.wantErr && err == nil {
+				t.Fatal("wildcard with credentials was accepted")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	// The server refuses to start with such a policy
+	config := testConfig(t)
+	config.CORS.Enabled = true
+	config.CORS.Wildcard = true
+	if _, err := newServer(config); err == nil {
+		t.Fatal("server started with wildcard and credentials")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/vikebot/vbnet"
+	"github.com/vikebot/vbrest/vbapi"
+)
+
+// maxBodySize is the maximum size of JSON request bodies in bytes. The
+// server doesn't read larger requests at all.
+const maxBodySize = 1 << 20
+
+// decodeJSON strictly decodes the request's JSON body into `v`. The request
+// must declare a JSON content type, mustn't exceed `maxBodySize` and may only
+// contain fields known to `v`. Failures are returned as `vbnet.HTTPError`s
+// listing the offending fields where possible.
+func decodeJSON(req *request, v interface{}) error {
+	ct := string(req.Request.Header.ContentType())
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil || mediaType != "application/json" {
+		return errUnsupportedMediaType
+	}
+	return decode(req, v, true)
+}
+
+// decodeLegacyJSON decodes the request's JSON body into `v` the way `/v1`
+// always did: the content type isn't checked and unknown fields are ignored.
+// Only routes which shipped before `decodeJSON` existed may use it.
+func decodeLegacyJSON(req *request, v interface{}) error {
+	return decode(req, v, false)
+}
+
+// decode decodes the body into `v`. If `strict` is set, fields unknown
+// to `v` are rejected.
+func decode(req *request, v interface{}, strict bool) error {
+	body := req.PostBody()
+	if len(body) > maxBodySize {
+		return errBodyTooLarge
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+
+	err := dec.Decode(v)
+	if err == nil {
+		// Only a single JSON value is allowed
+		if _, terr := dec.Token(); terr != io.EOF {
+			return malformedBody(dec.InputOffset())
+		}
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return malformedBody(syntaxErr.Offset)
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return malformedBody(int64(len(body)))
+	case errors.As(err, &typeErr):
+		return vbapi.NewValidationError(errInvalidBodyFields, []vbapi.FieldError{{
+			Field: typeErr.Field,
+			Rule:  vbapi.RuleType,
+			Param: typeErr.Type.String(),
+		}})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no dedicated type for unknown fields
+		field, uerr := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		if uerr != nil {
+			field = strings.TrimPrefix(err.Error(), "json: unknown field ")
+		}
+		return vbapi.NewValidationError(errInvalidBodyFields, []vbapi.FieldError{{
+			Field: field,
+			Rule:  vbapi.RuleUnknown,
+		}})
+	}
+	return malformedBody(dec.InputOffset())
+}
+
+// malformedBody returns `errMalformedBody` with the offset of the error
+func malformedBody(offset int64) error {
+	return vbnet.NewHTTPError(errMalformedBody.Message()+" at offset "+strconv.FormatInt(offset, 10),
+		errMalformedBody.HTTPCode(), errMalformedBody.Code(), nil)
+}
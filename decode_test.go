@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbcore"
+	"github.com/vikebot/vbrest/vbapi"
+)
+
+func TestV1UserUpdateIsLenient(t *testing.T) {
+	server := newTestServer(t, nil)
+	tokens := login(t, server, "alice")
+
+	// No content type, an unknown field and fields users can't change are
+	// all ignored like before
+	resp := do(server, testRequest{
+		method: "POST",
+		path:   "/v1/user/update",
+		header: map[string]string{"Authorization": "Bearer " + tokens.Token, "Content-Type": "text/plain"},
+		body:   `{"id":1,"permission":4,"name":"Alice Liddell","unknown":true}`,
+	})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+
+	resp = do(server, testRequest{method: "GET", path: "/v1/user/get", header: bearer(tokens.Token)})
+	var user vbcore.SafeUser
+	decodeBody(t, resp, &user)
+	if user.Name != "Alice Liddell" || user.Permission != vbcore.PermissionDefault {
+		t.Fatalf("want renamed default user but got %q (%d)", user.Name, user.Permission)
+	}
+}
+
+func TestV2UserUpdateIsStrict(t *testing.T) {
+	server := newTestServer(t, nil)
+	tokens := login(t, server, "alice")
+
+	long := `"` + strings.Repeat("a", 33) + `"`
+	tests := []struct {
+		name   string
+		ct     string
+		body   string
+		code   int
+		fields []vbapi.FieldError
+	}{
+		{"content type", "text/plain", `{"name":"Alice"}`, 9009, nil},
+		{"unknown field", "application/json", `{"unknown":true}`, 9012, []vbapi.FieldError{{Field: "unknown", Rule: vbapi.RuleUnknown}}},
+		{"wrong type", "application/json", `{"name":1}`, 9012, []vbapi.FieldError{{Field: "name", Rule: vbapi.RuleType, Param: "string"}}},
+		{"forbidden", "application/json", `{"id":1,"permission":4}`, 11012, []vbapi.FieldError{
+			{Field: "id", Rule: vbapi.RuleReadOnly},
+			{Field: "permission", Rule: vbapi.RuleReadOnly},
+		}},
+		{"too long", "application/json", `{"name":` + long + `}`, 11012, []vbapi.FieldError{{Field: "name", Rule: vbapi.RuleMax, Param: "32"}}},
+		{"email", "application/json", `{"emails":[{"Email":"` + strings.Repeat("a", 65) + `","Status":7}]}`, 11012, []vbapi.FieldError{
+			{Field: "emails[0].Email", Rule: vbapi.RuleMax, Param: "64"},
+			{Field: "emails[0].Status", Rule: vbapi.RuleOneOf, Param: "0 1 2"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(server, testRequest{
+				method: "PUT",
+				path:   "/v2/users/me",
+				header: map[string]string{"Authorization": "Bearer " + tokens.Token, "Content-Type": tt.ct},
+				body:   tt.body,
+			})
+			var e struct {
+				Code    int                     `json:"code"`
+				Details vbapi.ValidationDetails `json:"details"`
+			}
+			decodeBody(t, resp, &e)
+			if e.Code != tt.code {
+				t.Fatalf("want %d but got %d: %s", tt.code, e.Code, resp.Body())
+			}
+			if !reflect.DeepEqual(e.Details.Fields, tt.fields) {
+				t.Fatalf("want fields %v but got %v", tt.fields, e.Details.Fields)
+			}
+		})
+	}
+
+	resp := do(server, testRequest{
+		method: "PUT",
+		path:   "/v2/users/me",
+		header: map[string]string{"Authorization": "Bearer " + tokens.Token, "Content-Type": "application/json"},
+		body:   `{"name":"Alice Liddell"}`,
+	})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+}
+
+func TestServerRefusesOversizedBodies(t *testing.T) {
+	server := newTestServer(t, nil)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(ln)
+	defer server.Shutdown()
+
+	c := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	post := func(body string) int {
+		resp, err := c.Post("http://"+ln.Addr().String()+"/v1/auth/login", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// A body of exactly the limit still reaches the handler, which rejects
+	// the empty login
+	if status := post("{}" + strings.Repeat(" ", maxBodySize-2)); status != fasthttp.StatusUnprocessableEntity {
+		t.Fatalf("want 422 but got %d", status)
+	}
+	if status := post("{}" + strings.Repeat(" ", maxBodySize-1)); status != fasthttp.StatusBadRequest {
+		t.Fatalf("want 400 but got %d", status)
+	}
+}
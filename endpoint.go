@@ -1,48 +1,297 @@
 package main
 
 import (
-	"crypto/rand"
-	"encoding/base32"
-	"io"
-	"strings"
-
-	"go.uber.org/zap"
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbcore"
+	"github.com/vikebot/vbrest/vbapi"
 )
 
+// auth declares the authentication an endpoint requires. The zero value is
+// undeclared and refused when the endpoint is registered, so no endpoint can
+// accidentally be left unprotected.
+type auth struct {
+	declared   bool
+	public     bool
+	permission int
+}
+
+// public marks an endpoint as accessible without any authentication
+var public = auth{declared: true, public: true}
+
+// requires marks an endpoint as accessible only for authenticated users with
+// at least the passed `vbcore.Permission*` level. Banned users are rejected
+// with their ban unless `permission` is `vbcore.PermissionBanned`, which is
+// reserved for the session routes (logout and listing or revoking sessions).
+func requires(permission int) auth {
+	return auth{declared: true, permission: permission}
+}
+
 type endpoint struct {
-	Name       string
-	Handler    handler
-	ExactMatch bool
+	// Name is the route pattern. Segments starting with ':' are named path
+	// parameters passed to the handler.
+	Name    string
+	Methods []string
+	Handler handler
+	// Auth is enforced before any of the endpoint's middlewares run
+	Auth auth
+	// Middlewares wrap only this endpoint's handler and run after all
+	// global middlewares
+	Middlewares []middleware
+	// Version is the API version the endpoint belongs to. Set by
+	// `allEndpoints`.
+	Version *apiVersion
+	// Status is the HTTP status of successful responses. Defaults to 200.
+	Status int
+
+	// The following fields document the endpoint in the OpenAPI document.
+	// Summary is mandatory.
+	Summary string
+	// Query maps the names of query parameters to their description
+	Query map[string]string
+	// Request is a value of the JSON body's type or nil if the endpoint
+	// doesn't take a body
+	Request interface{}
+	// Response is a value of the type of successful responses. Endpoints
+	// without one answer with `simpleResponse`.
+	Response interface{}
 }
 
-func allEndpoints(log *zap.Logger) []endpoint {
-	buf := make([]byte, 128)
-	_, err := io.ReadFull(rand.Reader, buf)
-	if err != nil {
-		log.Fatal("unable to generate genjwtkey secrete key", zap.Error(err))
+var (
+	get  = []string{"GET"}
+	post = []string{"POST"}
+	put  = []string{"PUT"}
+	del  = []string{"DELETE"}
+)
+
+// allEndpoints returns the unversioned endpoints and the endpoints of all
+// versions with their full names
+func allEndpoints(versions []*apiVersion, metrics bool) []endpoint {
+	eps := rootEndpoints(metrics)
+	for _, v := range versions {
+		for _, ep := range v.Endpoints {
+			ep.Name = "/" + v.Name + ep.Name
+			ep.Version = v
+			eps = append(eps, ep)
+		}
 	}
+	return eps
+}
+
+// rootEndpoints aren't part of the API and therefore not versioned. They are
+// used by load balancers and monitoring. `/metrics` only exists if `metrics`
+// is set, i.e. the Prometheus backend is enabled.
+func rootEndpoints(metrics bool) []endpoint {
+	eps := []endpoint{
+		{
+			Name: "/healthz", Methods: get, Handler: healthz, Auth: public,
+			Summary:  "Check if the process is alive",
+			Response: &healthResponse{},
+		},
+		{
+			Name: "/readyz", Methods: get, Handler: readyz, Auth: public,
+			Summary:  "Check if all dependencies are available. Answers with 503 otherwise.",
+			Response: &healthResponse{},
+		},
+	}
+	if metrics {
+		eps = append(eps, endpoint{
+			Name: "/metrics", Methods: get, Handler: metricsHandler, Auth: public,
+			Summary:  "Get all metrics in the Prometheus text format",
+			Response: "",
+		})
+	}
+	return eps
+}
 
-	secret := strings.ToLower(base32.StdEncoding.EncodeToString(buf))
-	log.Warn("secret generated for genjwtkey",
-		zap.String("secret", secret),
-		zap.String("link", "/v0/admin/genjwtkey/" + secret + "/<DESIRED-USER-ID>"))
+// v1Endpoints is the original RPC style API. All errors are answered with
+// their legacy status unless clients opt in (see `statusPolicy`).
+func v1Endpoints() []endpoint {
+	return []endpoint{
+		{
+			Name: "/test", Methods: get, Handler: v1Test, Auth: public,
+			Summary: "Check if the service is reachable",
+		},
+		{
+			Name: "/meta/errors", Methods: get, Handler: v1MetaErrors, Auth: public,
+			Summary:  "List all error codes",
+			Response: []vbapi.ErrorInfo{},
+		},
+		{
+			Name: "/meta/openapi.json", Methods: get, Handler: v1MetaOpenAPI, Auth: public,
+			Summary:  "Get this OpenAPI document",
+			Response: map[string]interface{}{},
+		},
+		{
+			Name: "/meta/version", Methods: get, Handler: v1MetaVersion, Auth: public,
+			Summary:  "Get the build version, commit and start time",
+			Response: &buildInfo{},
+		},
+		{
+			Name: "/user/get", Methods: get, Handler: v1UserGet, Auth: requires(vbcore.PermissionDefault),
+			Summary:  "Get the authenticated user",
+			Response: &vbcore.SafeUser{},
+		},
+		{
+			Name: "/user/get/id/:id", Methods: get, Handler: v1UserGetPublicByID, Auth: public,
+			Summary:  "Get the public profile of a user by id",
+			Response: &vbcore.SafeUser{},
+		},
+		{
+			Name: "/user/get/username/:username", Methods: get, Handler: v1UserGetPublicByUsername, Auth: public,
+			Summary:  "Get the public profile of a user by username",
+			Response: &vbcore.SafeUser{},
+		},
+		{
+			Name: "/user/update", Methods: post, Handler: v1UserUpdate, Auth: requires(vbcore.PermissionDefault),
+			Summary: "Update the authenticated user's profile",
+			Request: &vbcore.User{},
+		},
+		{
+			Name: "/round/active", Methods: get, Handler: v1RoundActive, Auth: public,
+			Summary:  "List all active rounds",
+			Response: []vbcore.Round{},
+		},
+		{
+			Name: "/round/join/:id", Methods: post, Handler: v1RoundJoin, Auth: requires(vbcore.PermissionDefault),
+			Summary: "Join a round",
+		},
+		{
+			Name: "/roundentry/active", Methods: get, Handler: v1RoundentryActive, Auth: requires(vbcore.PermissionDefault),
+			Summary:  "List the authenticated user's entries of active rounds",
+			Response: []vbcore.Roundentry{},
+		},
+		{
+			Name: "/roundentry/connectinfo/:authtoken", Methods: get, Handler: v1RoundentryConnectinfo, Auth: public,
+			Summary:  "Resolve an authtoken to the game server's connect info",
+			Response: &vbcore.RoundentryConnectinfo{},
+		},
+		{
+			Name: "/roundentry/watchresolve/:watchtoken", Methods: get, Handler: v1RoundentryWatchresolve, Auth: public,
+			Summary:  "Resolve a watchtoken to the game server's websocket",
+			Response: "",
+		},
+		{
+			Name: "/register/confirm", Methods: post, Handler: v1RegisterConfirm, Auth: public,
+			Summary: "Finish a pending registration",
+			Request: &vbapi.RegisterConfirmRequest{},
+		},
+		{
+			Name: "/auth/login", Methods: post, Handler: v1AuthLogin, Auth: public,
+			Summary:  "Log in with username and password",
+			Request:  &vbapi.LoginRequest{},
+			Response: &vbapi.TokenResponse{},
+		},
+		{
+			Name: "/auth/refresh", Methods: post, Handler: v1AuthRefresh, Auth: public,
+			Summary:  "Exchange a refresh token for new tokens. Browsers can send the vbrefresh cookie instead of a body.",
+			Request:  &vbapi.RefreshRequest{},
+			Response: &vbapi.TokenResponse{},
+		},
+		{
+			Name: "/oauth/:provider/start", Methods: get, Handler: v1OAuthStart, Auth: public,
+			Summary:  "Start an OAuth login",
+			Response: &vbapi.OAuthStartResponse{},
+		},
+		{
+			Name: "/oauth/:provider/callback", Methods: get, Handler: v1OAuthCallback, Auth: public,
+			Summary: "Finish an OAuth login",
+			Query: map[string]string{
+				"code":  "Authorization code returned by the provider",
+				"state": "State returned by the provider",
+			},
+			Response: &vbapi.OAuthResponse{},
+		},
+		{
+			Name: "/auth/logout", Methods: post, Handler: v1AuthLogout, Auth: requires(vbcore.PermissionBanned),
+			Summary: "Revoke the tokens of the current session",
+		},
+		{
+			Name: "/auth/password", Methods: put, Handler: v1AuthPasswordSet, Auth: requires(vbcore.PermissionDefault),
+			Summary: "Set or change the authenticated user's password",
+			Request: &vbapi.PasswordSetRequest{},
+		},
+		{
+			Name: "/auth/sessions", Methods: get, Handler: v1AuthSessions, Auth: requires(vbcore.PermissionBanned),
+			Summary:  "List the authenticated user's sessions",
+			Response: []vbapi.Session{},
+		},
+		{
+			Name: "/auth/sessions/:jti", Methods: del, Handler: v1AuthSessionRevoke, Auth: requires(vbcore.PermissionBanned),
+			Summary: "Revoke a session",
+		},
 
-	// save it
-	genjwtsecret = secret
+		{
+			Name: "/admin/tokens", Methods: post, Handler: v1AdminTokenCreate, Auth: requires(vbcore.PermissionAdmin),
+			Summary:  "Issue a token for a user",
+			Request:  &vbapi.AdminTokenRequest{},
+			Response: &vbapi.AdminTokenResponse{},
+		},
+		{
+			Name: "/admin/users/:id/permission", Methods: put, Handler: v1AdminPermissionSet, Auth: requires(vbcore.PermissionTeam),
+			Summary: "Change the permission of a user or ban them",
+			Request: &vbapi.PermissionSetRequest{},
+		},
+		{
+			Name: "/admin/audit", Methods: get, Handler: v1AdminAudit, Auth: requires(vbcore.PermissionAdmin),
+			Summary: "List the latest audit entries",
+			Query: map[string]string{
+				"limit": "Maximum number of entries. Defaults to 50, at most 500.",
+			},
+			Response: []vbapi.AuditEntry{},
+		},
+	}
+}
 
+// v2Endpoints is the resource oriented API. Errors always use the current
+// status policy. Handlers are shared with v1 as long as the behaviour
+// doesn't differ.
+func v2Endpoints() []endpoint {
 	return []endpoint{
-		{"/v0/admin/genjwtkey/" + secret + "/", v0AdminGenjwtkey, false},
-
-		{"/v1/test", v1Test, true},
-		{"/v1/user/get", v1UserGet, true},
-		{"/v1/user/get/id/", v1UserGetPublicByID, false},
-		{"/v1/user/get/username/", v1UserGetPublicByUsername, false},
-		{"/v1/user/update", v1UserUpdate, true},
-		{"/v1/round/active", v1RoundActive, true},
-		{"/v1/round/join/", v1RoundJoin, false},
-		{"/v1/roundentry/active", v1RoundentryActive, true},
-		{"/v1/roundentry/connectinfo/", v1RoundentryConnectinfo, false},
-		{"/v1/roundentry/watchresolve/", v1RoundentryWatchresolve, false},
-		{"/v1/register/confirm", v1RegisterConfirm, false},
+		{
+			Name: "/meta/errors", Methods: get, Handler: v1MetaErrors, Auth: public,
+			Summary:  "List all error codes",
+			Response: []vbapi.ErrorInfo{},
+		},
+		{
+			Name: "/meta/openapi.json", Methods: get, Handler: v1MetaOpenAPI, Auth: public,
+			Summary:  "Get this OpenAPI document",
+			Response: map[string]interface{}{},
+		},
+		{
+			Name: "/meta/version", Methods: get, Handler: v1MetaVersion, Auth: public,
+			Summary:  "Get the build version, commit and start time",
+			Response: &buildInfo{},
+		},
+		{
+			Name: "/users/me", Methods: get, Handler: v1UserGet, Auth: requires(vbcore.PermissionDefault),
+			Summary:  "Get the authenticated user",
+			Response: &vbcore.SafeUser{},
+		},
+		{
+			Name: "/users/me", Methods: put, Handler: v2UserUpdate, Auth: requires(vbcore.PermissionDefault),
+			Summary: "Update the authenticated user's profile",
+			Request: &vbcore.User{},
+		},
+		{
+			Name: "/users/:id", Methods: get, Handler: v1UserGetPublicByID, Auth: public,
+			Summary:  "Get the public profile of a user",
+			Response: &vbcore.SafeUser{},
+		},
+		{
+			Name: "/rounds/active", Methods: get, Handler: v1RoundActive, Auth: public,
+			Summary:  "List all active rounds",
+			Response: []vbcore.Round{},
+		},
+		{
+			Name: "/rounds/:id/entries", Methods: post, Handler: v2RoundEntryCreate, Auth: requires(vbcore.PermissionDefault),
+			Status:   fasthttp.StatusCreated,
+			Summary:  "Join a round",
+			Response: &vbcore.Roundentry{},
+		},
+		{
+			Name: "/roundentries/active", Methods: get, Handler: v1RoundentryActive, Auth: requires(vbcore.PermissionDefault),
+			Summary:  "List the authenticated user's entries of active rounds",
+			Response: []vbcore.Roundentry{},
+		},
 	}
 }
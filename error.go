@@ -2,37 +2,20 @@ package main
 
 import (
 	"github.com/valyala/fasthttp"
-	"github.com/vikebot/vbnet"
-)
-
-const (
-	codeInternalServerError     = 9000
-	codeNotFound                = 9001
-	codeNotImplemented          = 9002
-	codeInsufficientPermission  = 9003
-	codeEndpointAssertionFailed = 9004
-	codeNoAuthProvided          = 9005
+	"github.com/vikebot/vbrest/vbapi"
 )
 
 var (
-	errInternalServerError = vbnet.NewHTTPError(
-		"Internal Server Error",
-		fasthttp.StatusInternalServerError,
-		codeInternalServerError,
-		nil)
-	errUnknownEndpoit = vbnet.NewHTTPError(
-		"No API endpoint matches your request",
-		fasthttp.StatusNotFound,
-		codeNotFound,
-		nil)
-	errNotImplemented = vbnet.NewHTTPError(
-		"Not implemented",
-		fasthttp.StatusNotImplemented,
-		codeNotImplemented,
-		nil)
-	errEndpointAssertionFailed = vbnet.NewHTTPError(
-		"Internal Server Error",
-		fasthttp.StatusInternalServerError,
-		codeEndpointAssertionFailed,
-		nil)
+	errInternalServerError    = vbapi.RegisterError(9000, fasthttp.StatusInternalServerError, "Internal Server Error")
+	errUnknownEndpoit         = vbapi.RegisterError(9001, fasthttp.StatusNotFound, "No API endpoint matches your request")
+	errNotImplemented         = vbapi.RegisterError(9002, fasthttp.StatusNotImplemented, "Not implemented")
+	errInsufficientPermission = vbapi.RegisterError(9003, fasthttp.StatusForbidden, "Insufficient permission")
+	errNoAuthProvided         = vbapi.RegisterError(9005, fasthttp.StatusUnauthorized, "No auth provided. Access forbidden")
+	errMethodNotAllowed       = vbapi.RegisterError(9006, fasthttp.StatusMethodNotAllowed, "Method not allowed for this API endpoint")
+	errMalformedAuthHeader    = vbapi.RegisterError(9007, fasthttp.StatusUnauthorized, "Malformed Authorization header. Expected 'Bearer <token>'")
+	errUnsupportedAuthScheme  = vbapi.RegisterError(9008, fasthttp.StatusUnauthorized, "Unsupported authorization scheme. Use 'Bearer <token>'")
+	errUnsupportedMediaType   = vbapi.RegisterError(9009, fasthttp.StatusUnsupportedMediaType, "Request body must be sent as 'application/json'")
+	errBodyTooLarge           = vbapi.RegisterError(9010, fasthttp.StatusRequestEntityTooLarge, "Request body too large")
+	errMalformedBody          = vbapi.RegisterError(9011, fasthttp.StatusBadRequest, "Malformed JSON body")
+	errInvalidBodyFields      = vbapi.RegisterError(9012, fasthttp.StatusUnprocessableEntity, "Request body contains invalid fields")
 )
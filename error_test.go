@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vikebot/vbrest/vbapi"
+)
+
+func TestRegisterErrorRejectsDuplicates(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering 9000 twice didn't panic")
+		}
+	}()
+	vbapi.RegisterError(errInternalServerError.Code(), 500, "duplicate")
+}
+
+// docsErrorRow is a row of a code table in docs/errors.md
+type docsErrorRow struct {
+	http int
+	v1   int
+}
+
+// docsErrors parses the code tables of docs/errors.md. It fails if a code is
+// listed twice.
+func docsErrors(t *testing.T) map[int]docsErrorRow {
+	t.Helper()
+
+	f, err := os.Open("docs/errors.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rows := make(map[int]docsErrorRow)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		cols := strings.Split(s.Text(), "|")
+		if len(cols) < 5 {
+			continue
+		}
+		code, err := strconv.Atoi(strings.TrimSpace(cols[1]))
+		if err != nil {
+			continue
+		}
+		var row docsErrorRow
+		row.http, err = strconv.Atoi(strings.TrimSpace(cols[2]))
+		if err == nil {
+			row.v1, err = strconv.Atoi(strings.TrimSpace(cols[3]))
+		}
+		if err != nil {
+			t.Fatalf("invalid status in row of %d: %v", code, err)
+		}
+		if _, ok := rows[code]; ok {
+			t.Errorf("code %d listed twice", code)
+		}
+		rows[code] = row
+	}
+	if err = s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestErrorCatalogueMatchesDocs(t *testing.T) {
+	docs := docsErrors(t)
+
+	for _, info := range vbapi.RegisteredErrors() {
+		row, ok := docs[info.Code]
+		if !ok {
+			t.Errorf("code %d isn't documented", info.Code)
+			continue
+		}
+		delete(docs, info.Code)
+
+		v1 := info.HTTPStatus
+		if info.LegacyHTTPStatus != 0 {
+			v1 = info.LegacyHTTPStatus
+		}
+		if row.http != info.HTTPStatus || row.v1 != v1 {
+			t.Errorf("code %d: documented as %d (v1 %d) but registered as %d (v1 %d)",
+				info.Code, row.http, row.v1, info.HTTPStatus, v1)
+		}
+	}
+	for code := range docs {
+		t.Errorf("code %d is documented but not registered", code)
+	}
+}
+
+func TestErrorsWithoutLegacyStatusOnV1(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	// Introduced after the status policy, so v1 answers with the policy's
+	// status without opting in
+	resp := do(server, testRequest{method: "POST", path: "/v1/auth/login", body: `{}`})
+	if code := errorCode(t, resp); code != 11025 {
+		t.Fatalf("want 11025 but got %d: %s", code, resp.Body())
+	}
+	if resp.StatusCode() != 422 {
+		t.Fatalf("want 422 but got %d", resp.StatusCode())
+	}
+}
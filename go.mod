@@ -3,17 +3,37 @@ module github.com/vikebot/vbrest
 require (
 	github.com/cactus/go-statsd-client v3.1.0+incompatible
 	github.com/dpapathanasiou/go-recaptcha v0.0.0-20180330231321-0e9736be20f9
-	github.com/hashicorp/go-immutable-radix v0.0.0-20170725221215-8aac27015308
-	github.com/hashicorp/golang-lru v0.0.0-20160813221303-0a025b7e63ad // indirect
-	github.com/hashicorp/uuid v0.0.0-20160311170451-ebb0a03e909c // indirect
-	github.com/klauspost/compress v1.4.1 // indirect
-	github.com/sendgrid/rest v2.4.0+incompatible // indirect
+	github.com/google/go-github v17.0.0+incompatible
+	github.com/prometheus/client_golang v0.9.4
+	github.com/prometheus/common v0.4.1
 	github.com/sendgrid/sendgrid-go v3.4.1+incompatible
 	github.com/valyala/fasthttp v1.0.0
 	github.com/vikebot/vbcore v1.0.1
 	github.com/vikebot/vbdb v0.1.5
-	github.com/vikebot/vbjwt v0.1.0
 	github.com/vikebot/vbnet v0.1.1
 	go.uber.org/zap v1.9.1
+	gopkg.in/dgrijalva/jwt-go.v3 v3.2.0
+	gopkg.in/natefinch/lumberjack.v2 v2.0.0
+)
+
+require (
+	github.com/BurntSushi/toml v0.3.1 // indirect
+	github.com/beorn7/perks v1.0.0 // indirect
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
+	github.com/go-sql-driver/mysql v1.4.0 // indirect
+	github.com/golang/protobuf v1.3.1 // indirect
+	github.com/google/go-querystring v0.0.0-20170111101155-53e6ce116135 // indirect
+	github.com/harwoeck/sqle v1.0.2 // indirect
+	github.com/klauspost/compress v1.4.1 // indirect
+	github.com/klauspost/cpuid v0.0.0-20180405133222-e7e905edc00e // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_model v0.0.0-20190129233127-fd36f4220a90 // indirect
+	github.com/prometheus/procfs v0.0.2 // indirect
+	github.com/sendgrid/rest v2.4.0+incompatible // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	go.uber.org/atomic v1.3.2 // indirect
+	go.uber.org/multierr v1.1.0 // indirect
+	golang.org/x/crypto v0.0.0-20180910181607-0e37d006457b // indirect
 	golang.org/x/sys v0.0.0-20181122145206-62eef0e2fa9b // indirect
+	google.golang.org/appengine v1.1.0 // indirect
 )
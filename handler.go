@@ -2,7 +2,46 @@ package main
 
 import (
 	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbrest/vbtrace"
 	"go.uber.org/zap"
 )
 
-type handler func(ctx *fasthttp.RequestCtx, p string, logCtx *zap.Logger) (r interface{}, err error)
+// request bundles everything a handler needs to process a single HTTP
+// request. The embedded `*fasthttp.RequestCtx` gives direct access to the
+// raw request and response.
+type request struct {
+	*fasthttp.RequestCtx
+	// Params contains the named path parameters of the matched route
+	Params params
+	// Log is the logging context of this request
+	Log *zap.Logger
+	// Rqid uniquely identifies this request in logs. Taken from the
+	// `X-Request-ID` header or the trace id.
+	Rqid string
+	// Span is the server span of this request. Inner operations are traced
+	// as it's children through `Log` (see `vbtrace.WithSpan`).
+	Span *vbtrace.Span
+	// UserID is the authenticated user. Only set for endpoints which aren't
+	// public.
+	UserID int
+	// Permission is the `vbcore.Permission*` level of the authenticated
+	// user. Only set for endpoints which aren't public.
+	Permission int
+	// TokenID is the `jti` of the JWT used to authenticate. Only set for
+	// endpoints which aren't public.
+	TokenID string
+	// LegacyStatus is true if errors must be answered with their legacy
+	// HTTP status (see `statusPolicy`)
+	LegacyStatus bool
+	// Route is the pattern of the matched route, even if the route doesn't
+	// support the request's method. Empty if no route matched.
+	Route string
+	// ErrorCode is the code of the error the request failed with. Zero if
+	// it succeeded.
+	ErrorCode int
+	// Status is the HTTP status of a successful response. Taken from the
+	// endpoint and defaults to 200.
+	Status int
+}
+
+type handler func(req *request) (r interface{}, err error)
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbrest/vbmail"
+)
+
+// readyzDBTimeout bounds the DB ping of `/readyz`, so a hanging connection
+// is reported as unavailable instead of blocking the load balancer
+const readyzDBTimeout = 2 * time.Second
+
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+)
+
+// healthCheck is the result of a single dependency check
+type healthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthResponse is the response of `/healthz` and `/readyz`. `Checks` is
+// only set for `/readyz`.
+type healthResponse struct {
+	Status string                  `json:"status"`
+	Checks map[string]*healthCheck `json:"checks,omitempty"`
+}
+
+func newHealthCheck(err string) *healthCheck {
+	if len(err) > 0 {
+		return &healthCheck{Status: healthUnavailable, Error: err}
+	}
+	return &healthCheck{Status: healthOK}
+}
+
+// healthz reports that the process is alive and able to handle requests
+func healthz(req *request) (r interface{}, err error) {
+	return &healthResponse{Status: healthOK}, nil
+}
+
+// metricsHandler exposes all metrics in the Prometheus text format. It's
+// only registered if the Prometheus backend is enabled.
+func metricsHandler(req *request) (r interface{}, err error) {
+	body, err := prom.expose()
+	if err != nil {
+		return nil, err
+	}
+	return &rawResponse{
+		ContentType: string(expfmt.FmtText),
+		Body:        body,
+	}, nil
+}
+
+// readyz reports whether vbrest can serve requests. Every dependency is
+// checked and listed. If any of them is unavailable the status is 503.
+func readyz(req *request) (r interface{}, err error) {
+	resp := &healthResponse{
+		Status: healthOK,
+		Checks: make(map[string]*healthCheck),
+	}
+
+	var shutdownErr string
+	if !isReady() {
+		shutdownErr = "shutting down"
+	}
+	resp.Checks["server"] = newHealthCheck(shutdownErr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), readyzDBTimeout)
+	defer cancel()
+	var dbErr string
+	if err := api.Ping(ctx); err != nil {
+		dbErr = err.Error()
+	}
+	resp.Checks["db"] = newHealthCheck(dbErr)
+
+	var jwtErr string
+	if !api.TokensReady() {
+		jwtErr = "signing keys not loaded"
+	}
+	resp.Checks["signing_keys"] = newHealthCheck(jwtErr)
+
+	var mailErr string
+	if !vbmail.Configured() {
+		mailErr = "sendgrid secret not configured"
+	}
+	resp.Checks["mail"] = newHealthCheck(mailErr)
+
+	for _, c := range resp.Checks {
+		if c.Status != healthOK {
+			resp.Status = healthUnavailable
+			req.Status = fasthttp.StatusServiceUnavailable
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vikebot/vbrest/vbapi"
+)
+
+// downStore is a `Store` whose DB is unreachable
+type downStore struct {
+	vbapi.Store
+}
+
+func (downStore) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestReadyzReportsDB(t *testing.T) {
+	config := testConfig(t)
+	server := newTestServer(t, config)
+
+	var health healthResponse
+	decodeBody(t, do(server, testRequest{method: "GET", path: "/readyz"}), &health)
+	if health.Checks["db"] == nil || health.Checks["db"].Status != healthOK {
+		t.Fatalf("want db ok but got %+v", health.Checks["db"])
+	}
+
+	var err error
+	api, err = vbapi.NewService(downStore{vbapi.NewMemoryStore()}, vbapi.Config{
+		SigningKeys:         config.JWT.SigningKeys,
+		DefaultSigningKeyID: config.JWT.DefaultSigningKeyID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := do(server, testRequest{method: "GET", path: "/readyz"})
+	if resp.StatusCode() != 503 {
+		t.Fatalf("want 503 but got %d", resp.StatusCode())
+	}
+	health = healthResponse{}
+	decodeBody(t, resp, &health)
+	if db := health.Checks["db"]; db == nil || db.Status != healthUnavailable || db.Error != "connection refused" {
+		t.Fatalf("want vbdb unavailable but got %+v", db)
+	}
+}
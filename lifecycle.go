@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbrest/vbtrace"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultReadTimeout  = 30 * time.Second
+	defaultDrainTimeout = 30 * time.Second
+)
+
+// ready is 1 as long as vbrest accepts new work. It's flipped to 0 as soon
+// as the shutdown starts, so load balancers stop sending new requests before
+// the server drains.
+var ready int32
+
+func setReady(r bool) {
+	var v int32
+	if r {
+		v = 1
+	}
+	atomic.StoreInt32(&ready, v)
+}
+
+func isReady() bool {
+	return atomic.LoadInt32(&ready) == 1
+}
+
+// draining closes keep-alive connections once the shutdown started, so
+// clients reconnect to another instance instead of reusing this one
+func draining(next handler) handler {
+	return func(req *request) (interface{}, error) {
+		if !isReady() {
+			req.SetConnectionClose()
+		}
+		return next(req)
+	}
+}
+
+var errDrainTimeout = errors.New("drain timeout exceeded")
+
+// shutdown gracefully stops `server`. It first reports unready for
+// `readyDelay`, then stops accepting connections and waits at most
+// `drainTimeout` for in-flight requests to finish. Once the server stopped,
+// the vbapi service (and it's DB pool) and the metrics backends are closed.
+// If requests are still in flight after `drainTimeout` they keep using both,
+// so they are left to the OS when the process exits. The span export and the
+// logger are flushed last in any case.
+func shutdown(server *fasthttp.Server, readyDelay, drainTimeout time.Duration) {
+	setReady(false)
+	if readyDelay > 0 {
+		log.Info("unready. waiting before draining", zap.Duration("ready_delay", readyDelay))
+		time.Sleep(readyDelay)
+	}
+
+	log.Info("draining in-flight requests", zap.Duration("drain_timeout", drainTimeout))
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Shutdown()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(drainTimeout):
+		err = errDrainTimeout
+	}
+	if err != nil {
+		log.Error("unable to drain all requests. vbapi and metrics stay open", zap.Error(err))
+	} else {
+		log.Info("all requests drained")
+
+		err = api.Close()
+		if err != nil {
+			log.Error("unable to close vbapi", zap.Error(err))
+		}
+		err = metric.Close()
+		if err != nil {
+			log.Error("unable to close metrics", zap.Error(err))
+		}
+	}
+	vbtrace.Close()
+	log.Info("shutdown complete")
+	log.Sync()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vikebot/vbrest/vbapi"
+)
+
+// closeRecorder wraps a `Store` and records whether it was closed
+type closeRecorder struct {
+	vbapi.Store
+	closed int32
+}
+
+func (s *closeRecorder) Close() error {
+	atomic.StoreInt32(&s.closed, 1)
+	return s.Store.Close()
+}
+
+// serveWithRecorder serves vbrest on a loopback port with a store recording
+// whether it's closed
+func serveWithRecorder(t *testing.T) (addr string, store *closeRecorder, shutdownServer func()) {
+	t.Helper()
+
+	config := testConfig(t)
+	config.Server.ReadTimeout = "2s"
+	server := newTestServer(t, config)
+
+	store = &closeRecorder{Store: vbapi.NewMemoryStore()}
+	var err error
+	api, err = vbapi.NewService(store, vbapi.Config{
+		SigningKeys:         config.JWT.SigningKeys,
+		DefaultSigningKeyID: config.JWT.DefaultSigningKeyID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(ln)
+	return ln.Addr().String(), store, func() {
+		shutdown(server, 0, 500*time.Millisecond)
+	}
+}
+
+func TestShutdownClosesStoreAfterDrain(t *testing.T) {
+	_, store, shutdownServer := serveWithRecorder(t)
+
+	shutdownServer()
+	if atomic.LoadInt32(&store.closed) != 1 {
+		t.Fatal("store wasn't closed after all requests drained")
+	}
+	if isReady() {
+		t.Fatal("still ready after shutdown")
+	}
+}
+
+func TestShutdownKeepsStoreOpenOnDrainTimeout(t *testing.T) {
+	addr, store, shutdownServer := serveWithRecorder(t)
+
+	// Serve one request, then start a second one which isn't finished before
+	// the drain timeout
+	conn, err := net.Dial("tcp4", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /v1/test HTTP/1.1\r\nHost: vbrest\r\n\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || status != "HTTP/1.1 200 OK\r\n" {
+		t.Fatalf("want 200 but got %q (%v)", status, err)
+	}
+	_, err = conn.Write([]byte("GET /v1/test HTTP/1.1\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shutdownServer()
+	if atomic.LoadInt32(&store.closed) != 0 {
+		t.Fatal("store was closed while a request was in flight")
+	}
+}
+
+func TestSupervisordWaitsForTheDrain(t *testing.T) {
+	buf, err := ioutil.ReadFile("config/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &conf{}
+	err = json.Unmarshal(buf, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drainTimeout, err := parseDuration(config.Server.DrainTimeout, defaultDrainTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readyDelay, err := parseDuration(config.Server.ReadyDelay, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err = ioutil.ReadFile("Docker/config/supervisord.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stopSignal string
+	var stopWait time.Duration
+	for _, line := range strings.Split(string(buf), "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "stopsignal":
+			stopSignal = kv[1]
+		case "stopwaitsecs":
+			secs, err := strconv.Atoi(kv[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			stopWait = time.Duration(secs) * time.Second
+		}
+	}
+
+	if stopSignal != "TERM" {
+		t.Errorf("want stopsignal TERM but got %q", stopSignal)
+	}
+	if stopWait <= readyDelay+drainTimeout {
+		t.Errorf("stopwaitsecs %v doesn't cover ready_delay %v and drain_timeout %v", stopWait, readyDelay, drainTimeout)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbrest/vbapi"
+)
+
+// tryLogin logs alice in with `password` and returns the error code (0 if
+// the login succeeded)
+func tryLogin(t *testing.T, server *fasthttp.Server, password string) int {
+	t.Helper()
+	resp := do(server, testRequest{
+		method: "POST",
+		path:   "/v1/auth/login",
+		body:   `{"username":"alice","password":"` + password + `"}`,
+	})
+	if resp.StatusCode() == fasthttp.StatusOK {
+		return 0
+	}
+	return errorCode(t, resp)
+}
+
+func TestLoginLockout(t *testing.T) {
+	const (
+		invalidCredentials = 11026
+		lockedOut          = 11027
+		maxFailures        = 5
+		lockoutDuration    = 300 * time.Millisecond
+	)
+
+	config := testConfig(t)
+	server := newTestServer(t, config)
+	replaceAPI(t, config, nil, vbapi.Config{LoginLockoutDuration: lockoutDuration})
+
+	// A successful login resets the failures counted so far
+	for i := 0; i < maxFailures-1; i++ {
+		if code := tryLogin(t, server, "wrong"); code != invalidCredentials {
+			t.Fatalf("failure %d: want %d but got %d", i+1, invalidCredentials, code)
+		}
+	}
+	if code := tryLogin(t, server, testPassword); code != 0 {
+		t.Fatalf("want login before the limit but got %d", code)
+	}
+
+	// Reaching the limit locks the account even for the right password
+	for i := 0; i < maxFailures; i++ {
+		if code := tryLogin(t, server, "wrong"); code != invalidCredentials {
+			t.Fatalf("failure %d: want %d but got %d", i+1, invalidCredentials, code)
+		}
+	}
+	if code := tryLogin(t, server, testPassword); code != lockedOut {
+		t.Fatalf("want locked out (%d) but got %d", lockedOut, code)
+	}
+
+	// The lockout expires
+	time.Sleep(lockoutDuration + 50*time.Millisecond)
+	if code := tryLogin(t, server, testPassword); code != 0 {
+		t.Fatalf("want login after the lockout expired but got %d", code)
+	}
+}
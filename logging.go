@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// redacted replaces the value of sensitive fields, path parameters and
+	// query arguments in logs
+	redacted = "[REDACTED]"
+
+	defaultLogMaxSizeMB = 100
+)
+
+// sensitiveKeys are the names of log fields, path parameters, query
+// arguments and JSON body keys whose values are never logged
+var sensitiveKeys = map[string]bool{
+	"access_token":  true,
+	"authtoken":     true,
+	"client_secret": true,
+	"code":          true,
+	"password":      true,
+	"recaptcha":     true,
+	"refresh_token": true,
+	"secret":        true,
+	"state":         true,
+	"token":         true,
+	"verification":  true,
+	"watchtoken":    true,
+}
+
+// newLogger creates the logger configured by `config.Log`
+func newLogger(config *conf) (*zap.Logger, error) {
+	var level zapcore.Level
+	if len(config.Log.Level) > 0 {
+		err := level.UnmarshalText([]byte(config.Log.Level))
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level '%s'", config.Log.Level)
+		}
+	}
+
+	var encoder zapcore.Encoder
+	switch config.Log.Format {
+	case "", "console":
+		encoder = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	case "json":
+		encoder = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	default:
+		return nil, fmt.Errorf("unknown log format '%s'", config.Log.Format)
+	}
+
+	var out io.Writer = os.Stdout
+	if f := config.Log.File; len(f.Path) > 0 {
+		if f.MaxSizeMB < 0 || f.MaxBackups < 0 || f.MaxAgeDays < 0 {
+			return nil, fmt.Errorf("log file rotation limits mustn't be negative")
+		}
+		maxSize := f.MaxSizeMB
+		if maxSize == 0 {
+			maxSize = defaultLogMaxSizeMB
+		}
+		out = &lumberjack.Logger{
+			Filename:   f.Path,
+			MaxSize:    maxSize,
+			MaxBackups: f.MaxBackups,
+			MaxAge:     f.MaxAgeDays,
+			Compress:   f.Compress,
+		}
+	}
+
+	core := zapcore.NewCore(encoder, zapcore.Lock(zapcore.AddSync(out)), level)
+	return zap.New(&redactCore{Core: core}), nil
+}
+
+// redactCore replaces the values of all fields named in `sensitiveKeys`
+// before they reach the encoder
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		if !sensitiveKeys[strings.ToLower(f.Key)] {
+			continue
+		}
+		// Only copy if there is something to redact
+		if out == nil {
+			out = make([]zapcore.Field, len(fields))
+			copy(out, fields)
+		}
+		out[i] = zap.String(f.Key, redacted)
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+// redactJSON returns `body` with the values of all sensitive keys replaced.
+// Bodies which aren't valid JSON are completely redacted.
+func redactJSON(body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return redacted
+	}
+	buf, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return redacted
+	}
+	return string(buf)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if sensitiveKeys[strings.ToLower(k)] {
+				v[k] = redacted
+			} else {
+				v[k] = redactValue(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = redactValue(e)
+		}
+	}
+	return v
+}
+
+// redactedPath returns the requested path and query with the values of
+// sensitive path parameters and query arguments replaced
+func redactedPath(req *request) string {
+	path := string(req.Path())
+	if len(req.Route) > 0 {
+		pattern := splitPath(req.Route)
+		segs := splitPath(path)
+		if len(pattern) == len(segs) {
+			for i, p := range pattern {
+				if strings.HasPrefix(p, ":") && sensitiveKeys[p[1:]] {
+					segs[i] = redacted
+				}
+			}
+			path = "/" + strings.Join(segs, "/")
+		}
+	}
+
+	args := req.QueryArgs()
+	if args.Len() == 0 {
+		return path
+	}
+	var query []string
+	args.VisitAll(func(key, value []byte) {
+		k := string(key)
+		if sensitiveKeys[strings.ToLower(k)] {
+			query = append(query, k+"="+redacted)
+		} else {
+			query = append(query, k+"="+string(value))
+		}
+	})
+	return path + "?" + strings.Join(query, "&")
+}
+
+// accessLog writes the single log line of a finished request. The logging
+// context already contains the request id.
+func accessLog(req *request, d time.Duration) {
+	fields := []zapcore.Field{
+		zap.String("method", string(req.Method())),
+		zap.String("path", redactedPath(req)),
+		zap.Int("status", req.Response.StatusCode()),
+		zap.Int("bytes", len(req.Response.Body())),
+		zap.Duration("duration", d),
+		zap.String("ip", realipFromFasthttp(req.RequestCtx)),
+	}
+	if req.UserID != 0 {
+		fields = append(fields, zap.Int("user_id", req.UserID))
+	}
+	if req.ErrorCode != 0 {
+		fields = append(fields, zap.Int("error_code", req.ErrorCode))
+	}
+	req.Log.Info("access", fields...)
+}
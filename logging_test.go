@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAccessLogRedactsRejectedRequests(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	var buf bytes.Buffer
+	log = zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&buf), zap.InfoLevel))
+
+	const authtoken = "d2f1c0ffee"
+	for _, method := range []string{"OPTIONS", "POST", "GET"} {
+		buf.Reset()
+		do(server, testRequest{method: method, path: "/v1/roundentry/connectinfo/" + authtoken})
+
+		line := buf.String()
+		if strings.Contains(line, authtoken) {
+			t.Fatalf("%s: authtoken logged in clear: %s", method, line)
+		}
+		if !strings.Contains(line, `"path":"/v1/roundentry/connectinfo/`+redacted+`"`) {
+			t.Fatalf("%s: want redacted path but got %s", method, line)
+		}
+	}
+}
@@ -6,33 +6,41 @@ import (
 	"fmt"
 	"io/ioutil"
 	logSimple "log"
-	"net/http"
+	"net"
 	"os"
-	"strconv"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/cactus/go-statsd-client/statsd"
-	iradix "github.com/hashicorp/go-immutable-radix"
 	"github.com/valyala/fasthttp"
-	"github.com/vikebot/vbcore"
-	"github.com/vikebot/vbjwt"
-	"github.com/vikebot/vbnet"
 	"github.com/vikebot/vbrest/vbapi"
 	"github.com/vikebot/vbrest/vbmail"
+	"github.com/vikebot/vbrest/vbtrace"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
 var (
-	log  *zap.Logger
-	stat statsd.Statter
+	log    *zap.Logger
+	metric metrics
+	// prom is the Prometheus backend served at `/metrics`. Nil if it's
+	// disabled.
+	prom *prometheusMetrics
+	api  *vbapi.Service
 )
 
 func main() {
 	configFlag := flag.String("config", "", "path to the config file")
+	openAPIFlag := flag.String("openapi", "", "write the OpenAPI document to this path and exit")
 	flag.Parse()
 
+	if len(*openAPIFlag) > 0 {
+		err := writeOpenAPI(*openAPIFlag)
+		if err != nil {
+			logSimple.Fatalln(err)
+		}
+		return
+	}
+
 	// get config
 	if configFlag == nil || len(*configFlag) == 0 {
 		logSimple.Fatalln("argument '-config' is mandatory and mustn't be empty")
@@ -48,36 +56,162 @@ func main() {
 	}
 
 	// Logging server
-	priority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= zapcore.InfoLevel
+	log, err = newLogger(config)
+	if err != nil {
+		logSimple.Fatalln(err)
+	}
+	log.Info("starting vbrest", zap.String("version", buildVersion), zap.String("commit", commit()))
+
+	// Export spans of all requests
+	sampleRatio := 1.0
+	if config.Tracing.SampleRatio != nil {
+		sampleRatio = *config.Tracing.SampleRatio
+	}
+	log.Info("init vbtrace", zap.String("exporter", config.Tracing.Exporter), zap.Float64("sample_ratio", sampleRatio))
+	err = vbtrace.Init(vbtrace.Config{
+		ServiceName:    "vbrest",
+		ServiceVersion: buildVersion,
+		Exporter:       config.Tracing.Exporter,
+		Endpoint:       config.Tracing.Endpoint,
+		Headers:        config.Tracing.Headers,
+		SampleRatio:    sampleRatio,
 	})
-	console := zapcore.Lock(os.Stdout)
-	consoleEncoder := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
-	logCore := zapcore.NewTee(
-		zapcore.NewCore(consoleEncoder, console, priority),
-	)
-	log = zap.New(logCore)
+	if err != nil {
+		log.Fatal("unable to init vbtrace", zap.Error(err))
+	}
+
+	drainTimeout, err := parseDuration(config.Server.DrainTimeout, defaultDrainTimeout)
+	if err != nil {
+		log.Fatal("invalid server drain_timeout", zap.Error(err))
+	}
+	readyDelay, err := parseDuration(config.Server.ReadyDelay, 0)
+	if err != nil {
+		log.Fatal("invalid server ready_delay", zap.Error(err))
+	}
+
+	server, err := newServer(config)
+	if err != nil {
+		log.Fatal("unable to init vbrest", zap.Error(err))
+	}
 
+	// Bind the listener before reporting ready, so load balancers only send
+	// requests once they can be accepted
+	if config.TLS.Active {
+		err = server.AppendCert(config.TLS.Cert, config.TLS.Key)
+		if err != nil {
+			log.Fatal("unable to load tls certificate", zap.Error(err))
+		}
+	}
+	ln, err := net.Listen("tcp4", config.Addr)
+	if err != nil {
+		log.Fatal("unable to listen", zap.String("addr", config.Addr), zap.Error(err))
+	}
+
+	startTime = time.Now()
+	serveErr := make(chan error, 1)
+	go func() {
+		if config.TLS.Active {
+			log.Info("rest service started with https ...",
+				zap.String("addr", config.Addr),
+				zap.String("cert", config.TLS.Cert),
+				zap.String("key", config.TLS.Key))
+			// The certificate is already loaded
+			serveErr <- server.ServeTLS(ln, "", "")
+		} else {
+			log.Info("rest service started with http ...", zap.String("addr", config.Addr))
+			serveErr <- server.Serve(ln)
+		}
+	}()
+	setReady(true)
+
+	// Wait until we are told to stop or the server fails
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case sig := <-signals:
+		log.Info("received signal. shutting down", zap.String("signal", sig.String()))
+		shutdown(server, readyDelay, drainTimeout)
+	case err = <-serveErr:
+		log.Error("serving failed", zap.Error(err))
+		shutdown(server, 0, drainTimeout)
+		os.Exit(1)
+	}
+}
+
+// newServer initializes the metrics, the store, the vbapi service and the
+// routes described by `config` and returns the server handling all requests.
+// The server isn't started yet. `log` must be set before.
+func newServer(config *conf) (*fasthttp.Server, error) {
 	// Statsd aggregation funcs
-	log.Info("init statsd")
-	stat, err = statsd.NewBufferedClient("", "", time.Second*1, 0)
+	log.Info("init metrics",
+		zap.String("statsd_addr", config.Metrics.Statsd.Addr),
+		zap.Bool("prometheus", config.Metrics.Prometheus.Enabled))
+	var err error
+	metric, prom, err = newMetrics(config)
 	if err != nil {
-		log.Error("unable to connect to statsd server. creating noop statter")
-		stat, _ = statsd.NewNoopClient()
+		return nil, fmt.Errorf("unable to init metrics: %v", err)
 	}
 
-	// Init our database connection
-	log.Info("init vbapi")
-	err = vbapi.Init(config.Recaptcha.Secret, config.DB.Addr, config.DB.User, config.DB.Pass, config.DB.Name, log)
+	// Init our store and the vbapi service using it
+	log.Info("init vbapi", zap.String("driver", config.DB.Driver))
+	store, err := newStore(config)
 	if err != nil {
-		log.Fatal("unable to init db connection", zap.Error(err))
+		return nil, fmt.Errorf("unable to init store: %v", err)
+	}
+	store = vbapi.NewInstrumentedStore(store, metric.DBCall)
+	accessLifetime, err := parseDuration(config.JWT.AccessLifetime, defaultAccessLifetime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt access_lifetime: %v", err)
+	}
+	refreshLifetime, err := parseDuration(config.JWT.RefreshLifetime, defaultRefreshLifetime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt refresh_lifetime: %v", err)
+	}
+	readTimeout, err := parseDuration(config.Server.ReadTimeout, defaultReadTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server read_timeout: %v", err)
+	}
+	trustedProxies, err = parseTrustedProxies(config.Server.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server trusted_proxies: %v", err)
+	}
+	oauth := make(map[string]vbapi.OAuthProviderConfig)
+	for name, c := range config.OAuth {
+		oauth[name] = vbapi.OAuthProviderConfig{
+			ClientID:     c.ClientID,
+			ClientSecret: c.ClientSecret,
+			RedirectURL:  c.RedirectURL,
+			AuthURL:      c.AuthURL,
+			TokenURL:     c.TokenURL,
+			UserURL:      c.UserURL,
+			Scopes:       c.Scopes,
+		}
+	}
+	api, err = vbapi.NewService(store, vbapi.Config{
+		RecaptchaSecret:      config.Recaptcha.Secret,
+		JwtIssuer:            jwtIssuer(config.JWT.ProductionIsssuer),
+		SigningKeys:          config.JWT.SigningKeys,
+		DefaultSigningKeyID:  config.JWT.DefaultSigningKeyID,
+		AccessTokenLifetime:  accessLifetime,
+		RefreshTokenLifetime: refreshLifetime,
+		OAuth:                oauth,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to init vbapi: %v", err)
+	}
+	if !api.TokensReady() {
+		log.Error("jwt default signing key not found", zap.String("default_signing_key_id", config.JWT.DefaultSigningKeyID))
 	}
 
 	// Init our sendgrid client
 	log.Info("init vbmail")
 	vbmail.Init(config.Sendgrid.Secret)
 
-	// Print CORS message
+	// Validate the CORS policy
+	corsPolicy, err := newCORSPolicy(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cors config: %v", err)
+	}
 	if config.CORS.Enabled {
 		if config.CORS.Wildcard {
 			log.Warn("cors enabled with wildcard")
@@ -86,190 +220,162 @@ func main() {
 		}
 	}
 
-	// Fill our rt (routes-tree) -> Radix tree has better lookup-times
-	// than iterating over each specified route in the hashmap (direct
-	// hashmap-lookups cannot be performed (we need prefix-matching!)
+	// Fill our rt (routes-tree). Each path segment is a node, so lookups
+	// only depend on the depth of the requested path and not on the amount
+	// of registered routes.
 	log.Info("init endpoints")
-	rt := iradix.New()
-	for _, ep := range allEndpoints(log) {
-		var failed bool
-		rt, _, failed = rt.Insert([]byte(ep.Name), ep)
-		if failed {
-			log.Fatal("unable to insert route into routes-tree", zap.String("route", ep.Name))
-		}
+	versions, err := allVersions(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid api versions: %v", err)
 	}
-
-	// Load all our signing keys used for validating the JWTs sent from
-	// clients to authenticate themselves.
-	log.Info("init vbjwt")
-	vbjwt.Init(config.JWT.ProductionIsssuer, config.JWT.DefaultSigningKeyID, config.JWT.SigningKeys, log)
-
-	respond := func(c *fasthttp.RequestCtx, r interface{}, ctx *zap.Logger) {
-		// If r == nil we where succesful so set response: ok
-		if r == nil {
-			r = &simpleResponse{Response: "ok"}
+	for _, v := range versions {
+		if v.deprecated() {
+			log.Warn("api version deprecated", zap.String("version", v.Name), zap.Time("deprecation", v.Deprecation), zap.Time("sunset", v.Sunset))
 		}
+	}
+	doc, err := newOpenAPI(versions, prom != nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate openapi document: %v", err)
+	}
+	openAPISpec, err = json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal openapi document: %v", err)
+	}
+	rt, err := newRoutes(versions, prom != nil)
+	if err != nil {
+		return nil, err
+	}
 
-		// Check for environment variable to enable local development
-		if config.CORS.Enabled {
-			origin := string(c.Request.Header.Peek("Origin"))
-
-			var allowed bool
-
-			// allow wildcard access
-			if config.CORS.Wildcard {
-				allowed = true
-			} else {
-				for _, domain := range config.CORS.AllowedDomains {
-					if origin == domain {
-						allowed = true
-					}
-				}
-			}
+	// Global middlewares run for every request before the route is looked
+	// up. Route specific middlewares are declared with the endpoints
+	// of each version.
+	h := chain(rt.handle,
+		requestID,
+		recovery,
+		draining,
+		statusPolicy,
+		jsonContentType,
+		cors(corsPolicy),
+		preflight(rt, corsPolicy),
+	)
 
-			if allowed {
-				c.Response.Header.Add("Access-Control-Allow-Credentials", "true")
-				c.Response.Header.Add("Access-Control-Allow-Origin", origin)
-			}
-		}
+	return &fasthttp.Server{
+		Handler:            dispatch(h),
+		Name:               "vbrest",
+		ReadTimeout:        readTimeout,
+		MaxRequestBodySize: maxBodySize,
+	}, nil
+}
 
-		switch v := r.(type) {
-		// Valid request - response only needs to be marshaled and sent
-		default:
-			body, err := json.Marshal(v)
-			if err != nil {
-				ctx.Error("marshaling response failed", zap.Error(err))
-				stat.Inc("vbrest.response_marshal_error", 1, 1)
-				c.SetStatusCode(fasthttp.StatusInternalServerError)
-				fmt.Fprint(c, `{"error":"Internal server error"}`)
-				return
-			}
-			resp := string(body)
-			ctx.Debug("req_response", zap.String("resp", resp))
-			stat.Inc("vbrest.req_ok", 1, 1)
-			c.SetStatusCode(fasthttp.StatusOK)
-			fmt.Fprint(c, resp)
-			return
-			// Valid request - but internal server error
-		case error:
-			if http, ok := v.(vbnet.HTTPError); ok {
-				ctx.Info("req_failed", zap.Error(http))
-				stat.Inc("vbrest.req_failed_http"+strconv.Itoa(http.HTTPCode()), 1, 1)
-				stat.Inc("vbrest.req_failed_code"+strconv.Itoa(http.Code()), 1, 1)
-				c.SetStatusCode(http.HTTPCode())
-				fmt.Fprintf(c, `{"error":"%s"}`, http.Message())
-				return
-			}
-			ctx.Error("internal_error", zap.Error(v))
-			stat.Inc("vbrest.internal_error", 1, 1)
-			c.SetStatusCode(fasthttp.StatusInternalServerError)
-			fmt.Fprint(c, `{"error":"Internal server error"}`)
-			return
+// newRoutes registers all endpoints of `versions` and the root endpoints in
+// a new router. `/metrics` is only registered if `metrics` is set.
+func newRoutes(versions []*apiVersion, metrics bool) (*router, error) {
+	rt := newRouter()
+	for _, ep := range allEndpoints(versions, metrics) {
+		err := rt.add(ep)
+		if err != nil {
+			return nil, fmt.Errorf("unable to insert route '%s' into routes-tree: %v", ep.Name, err)
 		}
 	}
+	return rt, nil
+}
 
-	dispatch := func(c *fasthttp.RequestCtx) {
-		rqid := vbcore.FastRandomString(32)
-		ctx := log.With(zap.String("rqid", rqid))
+// dispatch runs `h` for every request, writes it's result and records the
+// request's metrics, span and access log line
+func dispatch(h handler) fasthttp.RequestHandler {
+	return func(c *fasthttp.RequestCtx) {
+		start := time.Now()
+		metric.RequestStarted()
+
+		req := &request{
+			RequestCtx: c,
+			Log:        log,
+			Span:       startRequestSpan(c),
+		}
 
-		// Catch panics during execution
+		// Catch panics outside of the `recovery` middleware, e.g. while
+		// responding or logging
 		defer func() {
 			if rval := recover(); rval != nil {
-				ctx.Error("recover handler panic",
+				req.Log.Error("recover dispatch panic",
 					zap.Stack("rval_stack"),
 					zap.String("rval_string", fmt.Sprint(rval)))
+				metric.InternalError("dispatch_panic")
 
-				c.SetStatusCode(http.StatusInternalServerError)
+				c.Response.ResetBody()
+				c.SetStatusCode(fasthttp.StatusInternalServerError)
 			}
 		}()
 
-		// Convert request buffer to a readable URL-string
-		p := string(c.Path())
-
-		// Log basic request meta informations
-		ctx.Info("req",
-			zap.String("ip", realipFromFasthttp(c)),
-			zap.String("path", p))
-
-		// Log the request
-		stat.Inc("vbrest.req", 1, 1)
-
-		// Set the response type to json
-		c.SetContentType("application/json")
-
-		// Find route
-		k, f, ok := rt.Root().LongestPrefix(c.Path())
-
-		// No route matches the request
-		if !ok {
-			respond(c, errUnknownEndpoit, ctx)
-			return
-		}
-
-		// Assert reqHandler
-		ep, ok := f.(endpoint)
-		if !ok {
-			log.Error("endpoint assertion failed", zap.String("route", string(k)))
-			respond(c, errEndpointAssertionFailed, ctx)
-			return
+		r, err := h(req)
+		if err != nil {
+			respond(req, err)
+		} else {
+			respond(req, r)
 		}
 
-		// If the request handler wants an exact match fail if it would only
-		// be a prefix
-		if ep.ExactMatch && ep.Name != p {
-			respond(c, errUnknownEndpoit, ctx)
-			return
-		} else if ep.Handler == nil {
-			respond(c, errNotImplemented, ctx)
-			return
+		route := req.Route
+		if len(route) == 0 {
+			route = routeUnmatched
 		}
+		d := time.Since(start)
+		metric.RequestFinished(route, string(c.Method()), c.Response.StatusCode(), req.ErrorCode, d)
+		endRequestSpan(req, route)
+		accessLog(req, d)
+	}
+}
 
-		// https://stackoverflow.com/a/21783145/6123704
-		method := string(c.Method())
-		if method == "OPTIONS" {
-			c.Response.Header.Add("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-			c.Response.Header.Add("Access-Control-Allow-Headers", "X-PINGOTHER, Content-Type, Authorization")
-			c.Response.Header.Add("Access-Control-Max-Age", "86400")
-			respond(c, nil, ctx)
-			return
-		}
+const (
+	defaultAccessLifetime  = 15 * time.Minute
+	defaultRefreshLifetime = 31 * 24 * time.Hour
+)
 
-		// Execute request
-		r, err := ep.Handler(c, p, ctx)
-		if err != nil {
-			respond(c, err, ctx)
-			return
-		}
-		respond(c, r, ctx)
+// parseDuration parses a duration from the config and falls back to `def`
+// if it isn't set
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if len(s) == 0 {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive but is %s", d)
 	}
+	return d, nil
+}
 
-	if config.TLS.Active {
-		log.Info("rest service started with https ...",
-			zap.String("addr", config.Addr),
-			zap.String("cert", config.TLS.Cert),
-			zap.String("key", config.TLS.Key))
-		err = fasthttp.ListenAndServeTLS(config.Addr, config.TLS.Cert, config.TLS.Key, dispatch)
-		if err != nil {
-			log.Fatal("ListenAndServeTLS failed", zap.Error(err))
-		}
-	} else {
-		log.Info("rest service started with http ...", zap.String("addr", config.Addr))
-		err = fasthttp.ListenAndServe(config.Addr, dispatch)
-		if err != nil {
-			log.Fatal("ListenAndServe failed", zap.Error(err))
-		}
+// jwtIssuer returns the issuer of all tokens. The values are the ones vbjwt
+// used, so tokens issued before keep working.
+func jwtIssuer(production bool) string {
+	if production {
+		return "vikebot_production"
 	}
+	return "vikebot_debug"
 }
 
-func realipFromFasthttp(c *fasthttp.RequestCtx) string {
-	// Get ip from x-forwarded-for header
-	x := string(c.Request.Header.Peek("X-FORWARDED-FOR"))
+func newStore(config *conf) (vbapi.Store, error) {
+	switch config.DB.Driver {
+	case "", "mariadb":
+		return vbapi.NewMariaDBStore(config.DB.Addr, config.DB.User, config.DB.Pass, config.DB.Name, log)
+	case "memory":
+		log.Warn("using in-memory store. all data is lost on exit")
+		store := vbapi.NewMemoryStore()
+		if len(config.DB.Seed) > 0 {
+			f, err := os.Open(config.DB.Seed)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
 
-	// If we have multiple ips seperated by ',' only use the first one
-	if strings.Contains(x, ",") {
-		return x[0:strings.Index(x, ",")]
+			err = store.Seed(f)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown db driver '%s'", config.DB.Driver)
 	}
-
-	// Otherwise we only have one -> return it
-	return x
 }
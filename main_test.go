@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbcore"
+	"github.com/vikebot/vbrest/vbapi"
+	"go.uber.org/zap"
+)
+
+const (
+	testPassword  = "correct horse battery staple"
+	testSigningID = "k1"
+	testClientIP  = "192.0.2.10"
+)
+
+// testSeed contains a regular user, a team member, an admin and a banned
+// user which can all log in with `testPassword`, and an open round
+var testSeed = vbapi.MemorySeed{
+	Users: []vbapi.MemorySeedUser{
+		{SafeUser: vbcore.SafeUser{Username: "alice", Name: "Alice", Permission: vbcore.PermissionDefault, Emails: []vbcore.Email{
+			{Email: "alice@example.com", Status: vbcore.EmailPrimary},
+		}}, Password: testPassword},
+		{SafeUser: vbcore.SafeUser{Username: "tom", Name: "Tom", Permission: vbcore.PermissionTeam}, Password: testPassword},
+		{SafeUser: vbcore.SafeUser{Username: "ada", Name: "Ada", Permission: vbcore.PermissionAdmin}, Password: testPassword},
+		{SafeUser: vbcore.SafeUser{Username: "mallory", Name: "Mallory", Permission: vbcore.PermissionBanned}, Password: testPassword},
+	},
+	Rounds: []vbapi.MemoryRound{
+		{Round: vbcore.Round{ID: 1, Name: "Arena", Min: 2, Max: 8, RoundStatus: vbcore.RoundStatusOpen}, IPv4: "198.51.100.7", Port: 2400, WSProxy: 2401},
+	},
+}
+
+// testConfig returns the config of a vbrest instance backed by a
+// `MemoryStore` seeded with `testSeed`
+func testConfig(t *testing.T) *conf {
+	t.Helper()
+
+	seed, err := json.Marshal(testSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedPath := filepath.Join(t.TempDir(), "seed.json")
+	err = ioutil.WriteFile(seedPath, seed, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &conf{}
+	config.DB.Driver = "memory"
+	config.DB.Seed = seedPath
+	config.JWT.DefaultSigningKeyID = testSigningID
+	config.JWT.SigningKeys = map[string]string{
+		testSigningID: "00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff",
+	}
+	return config
+}
+
+// newTestServer initializes vbrest with `config` (`testConfig` if nil) the
+// same way `main` does, without listening on a port
+func newTestServer(t *testing.T, config *conf) *fasthttp.Server {
+	t.Helper()
+
+	if config == nil {
+		config = testConfig(t)
+	}
+	log = zap.NewNop()
+	server, err := newServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setReady(true)
+	t.Cleanup(func() {
+		api.Close()
+		metric.Close()
+	})
+	return server
+}
+
+// testRequest describes a request sent by `do`
+type testRequest struct {
+	method string
+	path   string
+	body   string
+	header map[string]string
+	ip     string
+}
+
+// do passes `r` to the handler of `server` and returns the response
+func do(server *fasthttp.Server, r testRequest) *fasthttp.Response {
+	var req fasthttp.Request
+	req.Header.SetMethod(r.method)
+	req.SetRequestURI(r.path)
+	if len(r.body) > 0 {
+		req.Header.SetContentType("application/json")
+		req.SetBodyString(r.body)
+	}
+	for k, v := range r.header {
+		req.Header.Set(k, v)
+	}
+	ip := r.ip
+	if len(ip) == 0 {
+		ip = testClientIP
+	}
+
+	var c fasthttp.RequestCtx
+	c.Init(&req, &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000}, nil)
+	server.Handler(&c)
+
+	resp := &fasthttp.Response{}
+	c.Response.CopyTo(resp)
+	return resp
+}
+
+// decodeBody unmarshals the JSON body of `resp` into `v`
+func decodeBody(t *testing.T, resp *fasthttp.Response, v interface{}) {
+	t.Helper()
+	err := json.Unmarshal(resp.Body(), v)
+	if err != nil {
+		t.Fatalf("unable to decode body %q: %v", resp.Body(), err)
+	}
+}
+
+// errorCode returns the vbrest error code of a failed response
+func errorCode(t *testing.T, resp *fasthttp.Response) int {
+	t.Helper()
+	var e errorResponse
+	decodeBody(t, resp, &e)
+	return e.Code
+}
+
+// login logs `username` in with `testPassword` and returns the issued tokens
+func login(t *testing.T, server *fasthttp.Server, username string) *vbapi.TokenResponse {
+	t.Helper()
+	resp := do(server, testRequest{
+		method: "POST",
+		path:   "/v1/auth/login",
+		body:   `{"username":"` + username + `","password":"` + testPassword + `"}`,
+	})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("login of %s failed with %d: %s", username, resp.StatusCode(), resp.Body())
+	}
+	var tokens vbapi.TokenResponse
+	decodeBody(t, resp, &tokens)
+	return &tokens
+}
+
+// bearer returns the Authorization header for `token`
+func bearer(token string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+// replaceAPI replaces the vbapi service `newTestServer` initialized with one
+// using `store` (the seeded store of `config` if nil) and `vc`. The signing
+// keys and token lifetimes of `config` are always used.
+func replaceAPI(t *testing.T, config *conf, store vbapi.Store, vc vbapi.Config) {
+	t.Helper()
+
+	if store == nil {
+		var err error
+		store, err = newStore(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	vc.SigningKeys = config.JWT.SigningKeys
+	vc.DefaultSigningKeyID = config.JWT.DefaultSigningKeyID
+	vc.AccessTokenLifetime = defaultAccessLifetime
+	vc.RefreshTokenLifetime = defaultRefreshLifetime
+
+	var err error
+	api, err = vbapi.NewService(store, vc)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"time"
+)
+
+// metrics records everything we measure about vbrest. The backends are
+// `statsdMetrics` and `prometheusMetrics`. Both can be enabled at the same
+// time through `multiMetrics`.
+type metrics interface {
+	// RequestStarted is called as soon as a request arrives
+	RequestStarted()
+	// RequestFinished is called after the response for `route` was written.
+	// `code` is the error code or zero if the request succeeded.
+	RequestFinished(route, method string, status, code int, d time.Duration)
+	// Deprecated counts a request to a route of a deprecated version
+	Deprecated(version, route string)
+	// InternalError counts errors that are hidden from clients
+	InternalError(kind string)
+	// DBCall records the duration of a store operation
+	DBCall(op string, d time.Duration, success bool)
+	// Close flushes all buffered metrics
+	Close() error
+}
+
+// routeUnmatched is the route of requests whose path didn't match any route.
+// Using the raw path would make the number of routes unbounded.
+const routeUnmatched = "unmatched"
+
+// multiMetrics reports to all of it's backends
+type multiMetrics []metrics
+
+func (m multiMetrics) RequestStarted() {
+	for _, b := range m {
+		b.RequestStarted()
+	}
+}
+
+func (m multiMetrics) RequestFinished(route, method string, status, code int, d time.Duration) {
+	for _, b := range m {
+		b.RequestFinished(route, method, status, code, d)
+	}
+}
+
+func (m multiMetrics) Deprecated(version, route string) {
+	for _, b := range m {
+		b.Deprecated(version, route)
+	}
+}
+
+func (m multiMetrics) InternalError(kind string) {
+	for _, b := range m {
+		b.InternalError(kind)
+	}
+}
+
+func (m multiMetrics) DBCall(op string, d time.Duration, success bool) {
+	for _, b := range m {
+		b.DBCall(op, d, success)
+	}
+}
+
+func (m multiMetrics) Close() error {
+	var err error
+	for _, b := range m {
+		if cerr := b.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// newMetrics creates the backends enabled in the config. If none are enabled
+// metrics are discarded. `prom` is only set if the Prometheus backend is
+// enabled.
+func newMetrics(config *conf) (m multiMetrics, prom *prometheusMetrics, err error) {
+	if len(config.Metrics.Statsd.Addr) > 0 {
+		s, err := newStatsdMetrics(config.Metrics.Statsd.Addr, config.Metrics.Statsd.Prefix)
+		if err != nil {
+			return nil, nil, err
+		}
+		m = append(m, s)
+	}
+	if config.Metrics.Prometheus.Enabled {
+		prom, err = newPrometheusMetrics()
+		if err != nil {
+			return nil, nil, err
+		}
+		m = append(m, prom)
+	}
+
+	return m, prom, nil
+}
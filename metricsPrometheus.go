@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// prometheusMetrics implements `metrics` by collecting them in a registry
+// which is scraped from `/metrics`
+type prometheusMetrics struct {
+	registry *prometheus.Registry
+
+	inFlight   prometheus.Gauge
+	requests   *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	errors     *prometheus.CounterVec
+	deprecated *prometheus.CounterVec
+	internal   *prometheus.CounterVec
+	db         *prometheus.HistogramVec
+}
+
+var _ metrics = (*prometheusMetrics)(nil)
+
+func newPrometheusMetrics() (*prometheusMetrics, error) {
+	m := &prometheusMetrics{
+		registry: prometheus.NewRegistry(),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "vbrest_requests_in_flight",
+			Help: "Number of requests currently being processed.",
+		}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "vbrest_requests_total",
+			Help: "Number of finished requests by route, method and HTTP status.",
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "vbrest_request_duration_seconds",
+			Help:    "Latency of requests by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "vbrest_errors_total",
+			Help: "Number of failed requests by error code.",
+		}, []string{"code"}),
+		deprecated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "vbrest_deprecated_requests_total",
+			Help: "Number of requests to routes of deprecated API versions.",
+		}, []string{"version", "route"}),
+		internal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "vbrest_internal_errors_total",
+			Help: "Number of errors hidden from clients by kind.",
+		}, []string{"kind"}),
+		db: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "vbrest_db_duration_seconds",
+			Help:    "Latency of store operations by operation and success.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"op", "success"}),
+	}
+
+	collectors := []prometheus.Collector{
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.inFlight,
+		m.requests,
+		m.duration,
+		m.errors,
+		m.deprecated,
+		m.internal,
+		m.db,
+	}
+	for _, c := range collectors {
+		err := m.registry.Register(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *prometheusMetrics) RequestStarted() {
+	m.inFlight.Inc()
+}
+
+func (m *prometheusMetrics) RequestFinished(route, method string, status, code int, d time.Duration) {
+	m.inFlight.Dec()
+	m.requests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.duration.WithLabelValues(route, method).Observe(d.Seconds())
+	if code != 0 {
+		m.errors.WithLabelValues(strconv.Itoa(code)).Inc()
+	}
+}
+
+func (m *prometheusMetrics) Deprecated(version, route string) {
+	m.deprecated.WithLabelValues(version, route).Inc()
+}
+
+func (m *prometheusMetrics) InternalError(kind string) {
+	m.internal.WithLabelValues(kind).Inc()
+}
+
+func (m *prometheusMetrics) DBCall(op string, d time.Duration, success bool) {
+	m.db.WithLabelValues(op, strconv.FormatBool(success)).Observe(d.Seconds())
+}
+
+func (m *prometheusMetrics) Close() error {
+	return nil
+}
+
+// expose renders all metrics in the Prometheus text format
+func (m *prometheusMetrics) expose() ([]byte, error) {
+	families, err := m.registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.FmtText)
+	for _, f := range families {
+		err = enc.Encode(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
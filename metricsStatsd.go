@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cactus/go-statsd-client/statsd"
+)
+
+// statsdMetrics implements `metrics` by sending them to a statsd server.
+// The counter names of earlier versions (`vbrest.req`, `vbrest.req_ok`, ...)
+// are kept, so existing dashboards continue to work.
+type statsdMetrics struct {
+	s statsd.Statter
+}
+
+var _ metrics = (*statsdMetrics)(nil)
+
+func newStatsdMetrics(addr, prefix string) (*statsdMetrics, error) {
+	s, err := statsd.NewBufferedClient(addr, prefix, time.Second*1, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdMetrics{s: s}, nil
+}
+
+func (m *statsdMetrics) RequestStarted() {
+	m.s.Inc("vbrest.req", 1, 1)
+	m.s.GaugeDelta("vbrest.req_in_flight", 1, 1)
+}
+
+func (m *statsdMetrics) RequestFinished(route, method string, status, code int, d time.Duration) {
+	m.s.GaugeDelta("vbrest.req_in_flight", -1, 1)
+	m.s.TimingDuration("vbrest.route."+statName(route)+"."+method, d, 1)
+	if code == 0 {
+		m.s.Inc("vbrest.req_ok", 1, 1)
+		return
+	}
+	m.s.Inc("vbrest.req_failed_http"+strconv.Itoa(status), 1, 1)
+	m.s.Inc("vbrest.req_failed_code"+strconv.Itoa(code), 1, 1)
+}
+
+func (m *statsdMetrics) Deprecated(version, route string) {
+	m.s.Inc("vbrest.deprecated."+version, 1, 1)
+	m.s.Inc("vbrest.deprecated."+version+"."+statName(route), 1, 1)
+}
+
+func (m *statsdMetrics) InternalError(kind string) {
+	m.s.Inc("vbrest."+kind, 1, 1)
+}
+
+func (m *statsdMetrics) DBCall(op string, d time.Duration, success bool) {
+	m.s.TimingDuration("vbrest.db."+op, d, 1)
+	if !success {
+		m.s.Inc("vbrest.db."+op+".failed", 1, 1)
+	}
+}
+
+func (m *statsdMetrics) Close() error {
+	return m.s.Close()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/valyala/fasthttp"
+)
+
+// documentsMetrics reports whether the served OpenAPI document lists
+// `/metrics`
+func documentsMetrics(t *testing.T, server *fasthttp.Server) bool {
+	t.Helper()
+	var doc openAPI
+	decodeBody(t, do(server, testRequest{method: "GET", path: "/v1/meta/openapi.json"}), &doc)
+	_, ok := doc.Paths["/metrics"]
+	return ok
+}
+
+// counter returns the value of the counter `name` with `labels`
+func counter(families map[string]*dto.MetricFamily, name string, labels map[string]string) float64 {
+	f, ok := families[name]
+	if !ok {
+		return 0
+	}
+	for _, m := range f.GetMetric() {
+		matches := 0
+		for _, l := range m.GetLabel() {
+			if labels[l.GetName()] == l.GetValue() {
+				matches++
+			}
+		}
+		if matches == len(labels) {
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	resp := do(server, testRequest{method: "GET", path: "/metrics"})
+	if code := errorCode(t, resp); code != errUnknownEndpoit.Code() {
+		t.Fatalf("want %d but got %d", errUnknownEndpoit.Code(), code)
+	}
+	if documentsMetrics(t, server) {
+		t.Fatal("/metrics documented without the Prometheus backend")
+	}
+}
+
+func TestMetricsScrape(t *testing.T) {
+	config := testConfig(t)
+	config.Metrics.Prometheus.Enabled = true
+	server := newTestServer(t, config)
+
+	do(server, testRequest{method: "GET", path: "/v1/test"})
+	do(server, testRequest{method: "GET", path: "/v2/users/me"})
+
+	resp := do(server, testRequest{method: "GET", path: "/metrics"})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+	if ct := string(resp.Header.ContentType()); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("want the text format but got %q", ct)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(resp.Body()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := counter(families, "vbrest_requests_total", map[string]string{"route": "/v1/test", "method": "GET", "status": "200"}); v != 1 {
+		t.Errorf("want 1 request to /v1/test but got %v", v)
+	}
+	if v := counter(families, "vbrest_errors_total", map[string]string{"code": "9005"}); v != 1 {
+		t.Errorf("want 1 error 9005 but got %v", v)
+	}
+	if _, ok := families["go_goroutines"]; !ok {
+		t.Error("go collector isn't registered")
+	}
+
+	if !documentsMetrics(t, server) {
+		t.Fatal("/metrics isn't documented with the Prometheus backend")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/vikebot/vbrest/vbtrace"
+	"go.uber.org/zap"
+)
+
+// middleware wraps a handler with additional behaviour. It can act before
+// and after the wrapped handler runs or skip it completely by returning
+// early.
+type middleware func(next handler) handler
+
+// chain wraps `h` with all passed middlewares. The first middleware is the
+// outermost one and therefore runs first.
+func chain(h handler, mws ...middleware) handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// requestIDHeader lets callers choose the id of their request. It's echoed
+// in every response.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDValidator restricts caller chosen ids to characters which are
+// safe to log
+var requestIDValidator = regexp.MustCompile("^[a-zA-Z0-9._:-]{1,128}$")
+
+// requestID assigns every request an id and binds it and the request's span
+// to the logging context. The id is taken from `X-Request-ID` if the caller
+// sent a valid one and falls back to the trace id.
+func requestID(next handler) handler {
+	return func(req *request) (interface{}, error) {
+		traceID := req.Span.Context().TraceID.String()
+		req.Rqid = string(req.Request.Header.Peek(requestIDHeader))
+		if !requestIDValidator.MatchString(req.Rqid) {
+			req.Rqid = traceID
+		}
+		req.Response.Header.Set(requestIDHeader, req.Rqid)
+		req.Span.SetAttribute("vbrest.request_id", req.Rqid)
+
+		req.Log = req.Log.With(zap.String("rqid", req.Rqid), zap.String("trace_id", traceID))
+		req.Log = vbtrace.WithSpan(req.Log, req.Span)
+		return next(req)
+	}
+}
+
+// recovery catches panics of all inner handlers and converts them into an
+// internal server error
+func recovery(next handler) handler {
+	return func(req *request) (r interface{}, err error) {
+		defer func() {
+			if rval := recover(); rval != nil {
+				req.Log.Error("recover handler panic",
+					zap.Stack("rval_stack"),
+					zap.String("rval_string", fmt.Sprint(rval)))
+
+				r, err = nil, errInternalServerError
+			}
+		}()
+
+		return next(req)
+	}
+}
+
+const (
+	// statusPolicyHeader lets `/v1` clients opt in to the current HTTP
+	// status policy by sending `statusPolicyCurrent`
+	statusPolicyHeader  = "Vikebot-Status-Policy"
+	statusPolicyCurrent = "2"
+)
+
+// statusPolicy keeps the HTTP status of errors on `/v1` routes stable for
+// existing clients. They get the legacy status of all errors whose status
+// changed, unless they opt in to the current policy.
+func statusPolicy(next handler) handler {
+	return func(req *request) (interface{}, error) {
+		if bytes.HasPrefix(req.Path(), []byte("/v1/")) &&
+			string(req.Request.Header.Peek(statusPolicyHeader)) != statusPolicyCurrent {
+			req.LegacyStatus = true
+		}
+		return next(req)
+	}
+}
+
+// jsonContentType sets the response type to json
+func jsonContentType(next handler) handler {
+	return func(req *request) (interface{}, error) {
+		req.SetContentType("application/json")
+		return next(req)
+	}
+}
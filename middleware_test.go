@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// panicMarshaler fails while the response is written, after the `recovery`
+// middleware already returned
+type panicMarshaler struct{}
+
+func (panicMarshaler) MarshalJSON() ([]byte, error) {
+	panic("marshal")
+}
+
+func TestDispatchRecoversPanics(t *testing.T) {
+	newTestServer(t, nil)
+
+	tests := []struct {
+		name string
+		h    handler
+	}{
+		{"handler", chain(func(req *request) (interface{}, error) { panic("handler") }, recovery)},
+		{"respond", func(req *request) (interface{}, error) { return panicMarshaler{}, nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req fasthttp.Request
+			req.SetRequestURI("/v1/test")
+			var c fasthttp.RequestCtx
+			c.Init(&req, &net.TCPAddr{IP: net.ParseIP(testClientIP)}, nil)
+
+			dispatch(tt.h)(&c)
+			if c.Response.StatusCode() != fasthttp.StatusInternalServerError {
+				t.Fatalf("want 500 but got %d", c.Response.StatusCode())
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	server := newTestServer(t, nil)
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceparent := "00-" + traceID + "-00f067aa0ba902b7-01"
+	generated := regexp.MustCompile("^[0-9a-f]{32}$")
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		// want is the expected id. Empty if a new one must be generated.
+		want string
+	}{
+		{"propagated", map[string]string{"X-Request-ID": "client-42"}, "client-42"},
+		{"propagated with traceparent", map[string]string{"X-Request-ID": "client-42", "traceparent": traceparent}, "client-42"},
+		{"absent", nil, ""},
+		{"absent with traceparent", map[string]string{"traceparent": traceparent}, traceID},
+		{"invalid", map[string]string{"X-Request-ID": "no spaces allowed"}, ""},
+		{"too long", map[string]string{"X-Request-ID": strings.Repeat("a", 129)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Unknown routes answer with an error listing the id as well
+			resp := do(server, testRequest{method: "GET", path: "/v1/unknown", header: tt.header})
+			rqid := string(resp.Header.Peek("X-Request-ID"))
+			if len(tt.want) > 0 && rqid != tt.want {
+				t.Fatalf("want X-Request-ID %q but got %q", tt.want, rqid)
+			}
+			if len(tt.want) == 0 && (!generated.MatchString(rqid) || rqid == traceID) {
+				t.Fatalf("want a generated X-Request-ID but got %q", rqid)
+			}
+
+			var e struct {
+				RequestID string `json:"request_id"`
+			}
+			decodeBody(t, resp, &e)
+			if e.RequestID != rqid {
+				t.Fatalf("want request_id %q in the body but got %q", rqid, e.RequestID)
+			}
+		})
+	}
+
+	// Generated ids are unique
+	first := string(do(server, testRequest{method: "GET", path: "/healthz"}).Header.Peek("X-Request-ID"))
+	second := string(do(server, testRequest{method: "GET", path: "/healthz"}).Header.Peek("X-Request-ID"))
+	if first == second {
+		t.Fatalf("two requests got the same X-Request-ID %s", first)
+	}
+}
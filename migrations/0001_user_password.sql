@@ -0,0 +1,12 @@
+-- Passwords users log in with at /v1/auth/login. Every change inserts a new
+-- row, the latest one is valid. `hash` and `salt` are hex encoded as returned
+-- by vbcore.CryptoPwd.
+CREATE TABLE IF NOT EXISTS user_password (
+	id      INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	user_id INT UNSIGNED NOT NULL,
+	msg_id  INT UNSIGNED NOT NULL,
+	hash    VARCHAR(128) NOT NULL,
+	salt    VARCHAR(64)  NOT NULL,
+	PRIMARY KEY (id),
+	KEY user_password_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,18 @@
+-- Refresh tokens issued together with every JWT. Only the SHA-256 `hash` of
+-- a token is stored. Tokens derived from the same login share a `family`.
+CREATE TABLE IF NOT EXISTS refresh_token (
+	id         INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	hash       CHAR(64)     NOT NULL,
+	family     VARCHAR(64)  NOT NULL,
+	user_id    INT UNSIGNED NOT NULL,
+	access_jti VARCHAR(64)  NOT NULL,
+	iat        DATETIME     NOT NULL,
+	exp        DATETIME     NOT NULL,
+	used       TINYINT(1)   NOT NULL DEFAULT 0,
+	revoked    TINYINT(1)   NOT NULL DEFAULT 0,
+	PRIMARY KEY (id),
+	UNIQUE KEY refresh_token_hash (hash),
+	KEY refresh_token_family (family),
+	KEY refresh_token_user_id (user_id),
+	KEY refresh_token_access_jti (access_jti)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,14 @@
+-- Audit log of admin actions. The reason is stored in `msg`, `details` is a
+-- JSON object.
+CREATE TABLE IF NOT EXISTS audit (
+	id             INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	actor_id       INT UNSIGNED NOT NULL,
+	action         VARCHAR(64)  NOT NULL,
+	target_user_id INT UNSIGNED NOT NULL,
+	msg_id         INT UNSIGNED NOT NULL,
+	details        TEXT         NOT NULL,
+	ip             VARCHAR(45)  NOT NULL,
+	time           DATETIME     NOT NULL,
+	PRIMARY KEY (id),
+	KEY audit_target_user_id (target_user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
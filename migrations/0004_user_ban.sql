@@ -0,0 +1,14 @@
+-- Bans of users. The reason is stored in `msg` and shared with the
+-- `user_permission` row setting the user to banned. `previous` is the
+-- permission restored once `exp` passes. Bans without `exp` are permanent.
+-- Every later permission change sets `lifted`, lifted bans are ignored.
+CREATE TABLE IF NOT EXISTS user_ban (
+	id       INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	user_id  INT UNSIGNED NOT NULL,
+	msg_id   INT UNSIGNED NOT NULL,
+	previous TINYINT      NOT NULL,
+	exp      DATETIME     NULL,
+	lifted   TINYINT(1)   NOT NULL DEFAULT 0,
+	PRIMARY KEY (id),
+	KEY user_ban_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
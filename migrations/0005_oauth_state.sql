@@ -0,0 +1,10 @@
+-- State and PKCE verifier of started OAuth flows. Rows are deleted when the
+-- callback consumes them or, once expired, when the next flow is started.
+CREATE TABLE IF NOT EXISTS oauth_state (
+	state    VARCHAR(64) NOT NULL,
+	provider VARCHAR(32) NOT NULL,
+	verifier VARCHAR(128) NOT NULL,
+	exp      DATETIME    NOT NULL,
+	PRIMARY KEY (state),
+	KEY oauth_state_exp (exp)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbrest/vbapi"
+)
+
+// fakeGithub is a GitHub stand-in which only hands out an access token if
+// the PKCE verifier matches the challenge of the authorization request
+func fakeGithub(t *testing.T, challenge *string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if r.FormValue("code") != "the-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != *challenge {
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "the-token"})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer the-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":       42,
+			"login":    "octocat",
+			"html_url": "https://github.com/octocat",
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOAuthFlow(t *testing.T) {
+	var challenge string
+	provider := fakeGithub(t, &challenge)
+
+	config := testConfig(t)
+	config.OAuth = map[string]oauthConf{
+		"github": {
+			ClientID:     "id",
+			ClientSecret: "secret",
+			RedirectURL:  "https://vikebot.com/oauth/github",
+			AuthURL:      provider.URL + "/authorize",
+			TokenURL:     provider.URL + "/token",
+			UserURL:      provider.URL + "/user",
+		},
+	}
+	server := newTestServer(t, config)
+
+	resp := do(server, testRequest{method: "GET", path: "/v1/oauth/github/start"})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("start failed with %d: %s", resp.StatusCode(), resp.Body())
+	}
+	var start vbapi.OAuthStartResponse
+	decodeBody(t, resp, &start)
+	redirect, err := url.Parse(start.RedirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge = redirect.Query().Get("code_challenge")
+
+	callback := testRequest{
+		method: "GET",
+		path:   "/v1/oauth/github/callback?code=the-code&state=" + url.QueryEscape(start.State),
+		header: map[string]string{"Cookie": oauthCookieName + "=" + start.State},
+	}
+	resp = do(server, callback)
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("callback failed with %d: %s", resp.StatusCode(), resp.Body())
+	}
+	var result vbapi.OAuthResponse
+	decodeBody(t, resp, &result)
+	if result.Status != vbapi.OAuthStatusRegistrationPending || len(result.RegisterCode) == 0 {
+		t.Fatalf("want pending registration but got %s", resp.Body())
+	}
+
+	// States are single use
+	resp = do(server, callback)
+	if code := errorCode(t, resp); code != 11034 {
+		t.Fatalf("want invalid state (11034) on replay but got %d: %s", code, resp.Body())
+	}
+}
+
+func TestOAuthStateMustMatchCookie(t *testing.T) {
+	config := testConfig(t)
+	config.OAuth = map[string]oauthConf{
+		"github": {ClientID: "id", ClientSecret: "secret", RedirectURL: "https://vikebot.com/oauth/github"},
+	}
+	server := newTestServer(t, config)
+
+	resp := do(server, testRequest{method: "GET", path: "/v1/oauth/github/start"})
+	var start vbapi.OAuthStartResponse
+	decodeBody(t, resp, &start)
+
+	tests := []struct {
+		name   string
+		state  string
+		cookie string
+	}{
+		{"no cookie", start.State, ""},
+		{"other cookie", start.State, "other"},
+		{"unknown state", "unknown", "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testRequest{method: "GET", path: "/v1/oauth/github/callback?code=the-code&state=" + url.QueryEscape(tt.state)}
+			if len(tt.cookie) > 0 {
+				r.header = map[string]string{"Cookie": oauthCookieName + "=" + tt.cookie}
+			}
+			resp := do(server, r)
+			if code := errorCode(t, resp); code != 11034 {
+				t.Fatalf("want invalid state (11034) but got %d: %s", code, resp.Body())
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+//go:generate go run . -openapi docs/openapi.json
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vikebot/vbcore"
+)
+
+// openAPI is an OpenAPI 3 document. Only the parts vbrest uses are modeled.
+type openAPI struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]*openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// schemaGen derives JSON schemas from Go types the same way encoding/json
+// marshals them. Structs are added as components and referenced.
+type schemaGen struct {
+	schemas map[string]*openAPISchema
+	names   map[reflect.Type]string
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGen) schema(t reflect.Type) *openAPISchema {
+	switch {
+	case t == timeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Ptr:
+		s := g.schema(t.Elem())
+		if len(s.Ref) == 0 {
+			s.Nullable = true
+		}
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return &openAPISchema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+	// Interfaces can hold anything
+	return &openAPISchema{}
+}
+
+// component adds the struct `t` to the schemas and returns it's name
+func (g *schemaGen) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	// Unexported types like `errorResponse` are still exported as schemas
+	name := t.Name()
+	if len(name) > 0 {
+		name = strings.ToUpper(name[:1]) + name[1:]
+	}
+	if _, taken := g.schemas[name]; taken || len(name) == 0 {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = pkg + "." + name
+	}
+
+	// Register before descending, so recursive types terminate
+	s := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	g.names[t] = name
+	g.schemas[name] = s
+	g.fields(t, s, false)
+	sort.Strings(s.Required)
+	return name
+}
+
+// fields adds all fields of the struct `t` marshaled by encoding/json to `s`.
+// Embedded structs without a json name are flattened. If `optional` is set
+// none of the fields are required (e.g. for embedded pointers).
+func (g *schemaGen) fields(t reflect.Type, s *openAPISchema, optional bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx:]
+		}
+
+		ft := f.Type
+		if f.Anonymous && len(name) == 0 {
+			embeddedOptional := optional
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+				embeddedOptional = true
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, s, embeddedOptional)
+				continue
+			}
+		}
+		if len(f.PkgPath) > 0 {
+			// unexported
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+
+		s.Properties[name] = g.schema(ft)
+		if !optional && !strings.Contains(opts, ",omitempty") && ft.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// openAPIPath converts a route pattern to an OpenAPI path and returns the
+// names of it's parameters, e.g. `/v2/users/:id` to `/v2/users/{id}`
+func openAPIPath(pattern string) (path string, params []string) {
+	segs := splitPath(pattern)
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, ":") {
+			params = append(params, seg[1:])
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return "/" + strings.Join(segs, "/"), params
+}
+
+// operationID derives a unique id from the method and pattern, e.g.
+// `getV2UsersId`
+func operationID(method, pattern string) string {
+	id := strings.ToLower(method)
+	for _, seg := range splitPath(pattern) {
+		seg = strings.TrimPrefix(seg, ":")
+		if len(seg) > 0 {
+			id += strings.ToUpper(seg[:1]) + seg[1:]
+		}
+	}
+	return id
+}
+
+// newOpenAPI generates the OpenAPI document of all endpoints. Every endpoint
+// must be documented with a summary. `metrics` documents `/metrics` like
+// it's passed to `allEndpoints`.
+func newOpenAPI(versions []*apiVersion, metrics bool) (*openAPI, error) {
+	g := &schemaGen{
+		schemas: make(map[string]*openAPISchema),
+		names:   make(map[reflect.Type]string),
+	}
+	errRef := &openAPISchema{Ref: "#/components/schemas/" + g.component(reflect.TypeOf(errorResponse{}))}
+
+	doc := &openAPI{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:       "vbrest",
+			Description: "REST API of vikebot. Error codes are documented in docs/errors.md and served at /v1/meta/errors.",
+			Version:     versions[len(versions)-1].Name,
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]*openAPISecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer"},
+				"cookieAuth": {Type: "apiKey", In: "cookie", Name: authCookieName},
+			},
+		},
+	}
+
+	for _, ep := range allEndpoints(versions, metrics) {
+		if len(ep.Summary) == 0 {
+			return nil, fmt.Errorf("route '%s' has no summary", ep.Name)
+		}
+
+		path, params := openAPIPath(ep.Name)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*openAPIOperation)
+		}
+
+		for _, m := range ep.Methods {
+			op := &openAPIOperation{
+				OperationID: operationID(m, ep.Name),
+				Summary:     ep.Summary,
+				Tags:        []string{"health"},
+				Responses:   make(map[string]*openAPIResponse),
+			}
+
+			if ep.Version != nil {
+				op.Tags = []string{ep.Version.Name}
+				op.Deprecated = ep.Version.deprecated()
+			}
+
+			for _, p := range params {
+				op.Parameters = append(op.Parameters, openAPIParameter{
+					Name:     p,
+					In:       "path",
+					Required: true,
+					Schema:   &openAPISchema{Type: "string"},
+				})
+			}
+			query := make([]string, 0, len(ep.Query))
+			for q := range ep.Query {
+				query = append(query, q)
+			}
+			sort.Strings(query)
+			for _, q := range query {
+				op.Parameters = append(op.Parameters, openAPIParameter{
+					Name:        q,
+					In:          "query",
+					Description: ep.Query[q],
+					Schema:      &openAPISchema{Type: "string"},
+				})
+			}
+
+			if ep.Request != nil {
+				op.RequestBody = &openAPIRequestBody{
+					Required: true,
+					Content: map[string]*openAPIMediaType{
+						"application/json": {Schema: g.schema(reflect.TypeOf(ep.Request))},
+					},
+				}
+			}
+
+			resp := ep.Response
+			if resp == nil {
+				resp = &simpleResponse{}
+			}
+			status := ep.Status
+			if status == 0 {
+				status = 200
+			}
+			op.Responses[fmt.Sprint(status)] = &openAPIResponse{
+				Description: "Success",
+				Content: map[string]*openAPIMediaType{
+					"application/json": {Schema: g.schema(reflect.TypeOf(resp))},
+				},
+			}
+			op.Responses["default"] = &openAPIResponse{
+				Description: "Error",
+				Content: map[string]*openAPIMediaType{
+					"application/json": {Schema: errRef},
+				},
+			}
+
+			if !ep.Auth.public {
+				op.Description = "Requires the permission `" + vbcore.PermissionItoA(ep.Auth.permission) + "`."
+				op.Security = []map[string][]string{
+					{"bearerAuth": {}},
+					{"cookieAuth": {}},
+				}
+			}
+
+			doc.Paths[path][strings.ToLower(m)] = op
+		}
+	}
+	return doc, nil
+}
+
+// openAPISpec is the marshaled OpenAPI document. It's generated once at
+// startup, because it's endpoint is part of the document itself.
+var openAPISpec json.RawMessage
+
+// writeOpenAPI writes the OpenAPI document of all versions to `path`. Used
+// by `go generate` to keep `docs/openapi.json` up to date. The document
+// describes the default config, so `/metrics` isn't part of it.
+func writeOpenAPI(path string) error {
+	versions, err := allVersions(&conf{})
+	if err != nil {
+		return err
+	}
+	doc, err := newOpenAPI(versions, false)
+	if err != nil {
+		return err
+	}
+	buf, err := json.MarshalIndent(doc, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(buf, '\n'), 0644)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// walkRoutes returns every registered route as "METHOD /openapi/{path}"
+func walkRoutes(n *routeNode, routes []string) []string {
+	for m := range n.endpoints {
+		path, _ := openAPIPath(n.pattern)
+		routes = append(routes, m+" "+path)
+	}
+	for _, child := range n.static {
+		routes = walkRoutes(child, routes)
+	}
+	if n.param != nil {
+		routes = walkRoutes(n.param, routes)
+	}
+	return routes
+}
+
+// loadOpenAPI reads the committed docs/openapi.json
+func loadOpenAPI(t *testing.T) *openAPI {
+	t.Helper()
+	buf, err := ioutil.ReadFile("docs/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc openAPI
+	err = json.Unmarshal(buf, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &doc
+}
+
+func TestOpenAPIMatchesRouter(t *testing.T) {
+	versions, err := allVersions(&conf{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt, err := newRoutes(versions, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := walkRoutes(rt.root, nil)
+	sort.Strings(routes)
+
+	var documented []string
+	for path, ops := range loadOpenAPI(t).Paths {
+		for m := range ops {
+			documented = append(documented, strings.ToUpper(m)+" "+path)
+		}
+	}
+	sort.Strings(documented)
+
+	if !reflect.DeepEqual(routes, documented) {
+		t.Fatalf("docs/openapi.json is out of date, run `go generate`\nrouter: %v\ndocs:   %v", routes, documented)
+	}
+}
+
+func TestOpenAPIIsUpToDate(t *testing.T) {
+	versions, err := allVersions(&conf{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := newOpenAPI(versions, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Round trip through JSON, so both sides are compared in the same form
+	buf, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var generated openAPI
+	err = json.Unmarshal(buf, &generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(&generated, loadOpenAPI(t)) {
+		t.Fatal("docs/openapi.json is out of date, run `go generate`")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// trustedProxies are the networks of the load balancers and reverse proxies
+// in front of vbrest. Only their `X-Forwarded-For` header is honoured,
+// otherwise every client could choose the IP its tokens are bound to and
+// login lockouts are counted for.
+var trustedProxies []*net.IPNet
+
+// parseTrustedProxies parses IP addresses and CIDR networks
+func parseTrustedProxies(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, p := range list {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy '%s' is neither an IP address nor a CIDR network", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy '%s' is neither an IP address nor a CIDR network", p)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// realipFromFasthttp returns the IP of the client. If the connection comes
+// from a trusted proxy the `X-Forwarded-For` header is walked from the right
+// and the first address not belonging to a trusted proxy is the client.
+// Entries left of it could have been sent by the client itself.
+func realipFromFasthttp(c *fasthttp.RequestCtx) string {
+	ip := c.RemoteIP()
+	if !isTrustedProxy(ip) {
+		return ip.String()
+	}
+
+	hops := strings.Split(string(c.Request.Header.Peek("X-Forwarded-For")), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// The header was tampered with. The last valid hop is the best
+			// we know.
+			break
+		}
+		ip = hop
+		if !isTrustedProxy(ip) {
+			break
+		}
+	}
+	return ip.String()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := parseTrustedProxies([]string{"10.0.0.1", "192.168.0.0/16", "2001:db8::1", "2001:db8:1::/48"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 4 {
+		t.Fatalf("want 4 networks but got %d", len(nets))
+	}
+
+	for _, invalid := range []string{"", "10.0.0", "10.0.0.0/33", "proxy.vikebot.com"} {
+		_, err := parseTrustedProxies([]string{invalid})
+		if err == nil {
+			t.Errorf("want error for %q", invalid)
+		}
+	}
+}
+
+func TestRealipFromFasthttp(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedProxies = proxies
+	defer func() { trustedProxies = nil }()
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		ip     string
+	}{
+		{"direct", "203.0.113.1", "", "203.0.113.1"},
+		{"untrusted spoofs header", "203.0.113.1", "198.51.100.7", "203.0.113.1"},
+		{"trusted proxy", "10.0.0.1", "198.51.100.7", "198.51.100.7"},
+		{"trusted ipv6 proxy", "2001:db8::1", "198.51.100.7", "198.51.100.7"},
+		{"trusted proxy without header", "10.0.0.1", "", "10.0.0.1"},
+		{"client prepends fake hop", "10.0.0.1", "1.2.3.4, 198.51.100.7", "198.51.100.7"},
+		{"chain of trusted proxies", "10.0.0.1", "198.51.100.7, 10.0.0.2, 10.0.0.3", "198.51.100.7"},
+		{"invalid hop", "10.0.0.1", "garbage, 198.51.100.7", "198.51.100.7"},
+		{"only invalid", "10.0.0.1", "garbage", "10.0.0.1"},
+		{"only trusted", "10.0.0.1", "10.0.0.2", "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req fasthttp.Request
+			if len(tt.xff) > 0 {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			var c fasthttp.RequestCtx
+			c.Init(&req, &net.TCPAddr{IP: net.ParseIP(tt.remote)}, nil)
+
+			if ip := realipFromFasthttp(&c); ip != tt.ip {
+				t.Fatalf("want %s but got %s", tt.ip, ip)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbnet"
+	"github.com/vikebot/vbrest/vbapi"
+	"go.uber.org/zap"
+)
+
+// errorResponse is the body of every failed request. The codes are
+// documented in `docs/errors.md`.
+type errorResponse struct {
+	Code       int         `json:"code"`
+	Message    string      `json:"message"`
+	HTTPStatus int         `json:"http_status"`
+	RequestID  string      `json:"request_id"`
+	Details    interface{} `json:"details,omitempty"`
+}
+
+// rawResponse is written as is instead of being marshaled to JSON
+type rawResponse struct {
+	ContentType string
+	Body        []byte
+}
+
+// respond writes the result of a handler to the client. `r` is ether the
+// response object (marshaled to JSON) or an error.
+func respond(req *request, r interface{}) {
+	ctx := req.Log
+
+	// If r == nil we where succesful so set response: ok
+	if r == nil {
+		r = &simpleResponse{Response: "ok"}
+	}
+
+	switch v := r.(type) {
+	// Non JSON response - body is sent as is
+	case *rawResponse:
+		if req.Status == 0 {
+			req.Status = fasthttp.StatusOK
+		}
+		req.SetContentType(v.ContentType)
+		req.SetStatusCode(req.Status)
+		req.SetBody(v.Body)
+		return
+	// Valid request - response only needs to be marshaled and sent
+	default:
+		body, err := json.Marshal(v)
+		if err != nil {
+			ctx.Error("marshaling response failed", zap.Error(err))
+			metric.InternalError("response_marshal_error")
+			respondError(req, errInternalServerError)
+			return
+		}
+		if req.Status == 0 {
+			req.Status = fasthttp.StatusOK
+		}
+		req.SetStatusCode(req.Status)
+		req.SetBody(body)
+		return
+		// Valid request - but internal server error
+	case error:
+		if http, ok := v.(vbnet.HTTPError); ok {
+			ctx.Info("req_failed", zap.Error(http))
+			respondError(req, http)
+			return
+		}
+		ctx.Error("internal_error", zap.Error(v))
+		metric.InternalError("internal_error")
+		respondError(req, errInternalServerError)
+		return
+	}
+}
+
+// respondError writes the error envelope for `err`
+func respondError(req *request, err vbnet.HTTPError) {
+	status := err.HTTPCode()
+	if req.LegacyStatus {
+		if legacy, ok := vbapi.LegacyHTTPStatus(err.Code()); ok {
+			status = legacy
+		}
+	}
+
+	req.ErrorCode = err.Code()
+	resp := errorResponse{
+		Code:       err.Code(),
+		Message:    err.Message(),
+		HTTPStatus: status,
+		RequestID:  req.Rqid,
+	}
+	if detailed, ok := err.(vbapi.DetailedError); ok {
+		resp.Details = detailed.Details()
+	}
+
+	body, merr := json.Marshal(resp)
+	if merr != nil {
+		// Only the details can fail to marshal
+		req.Log.Error("marshaling error details failed", zap.Error(merr))
+		resp.Details = nil
+		body, _ = json.Marshal(resp)
+	}
+
+	req.SetStatusCode(status)
+	req.SetBody(body)
+}
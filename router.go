@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vikebot/vbrest/vbtrace"
+)
+
+// params holds the named path parameters of a matched route, e.g. the
+// pattern `/v1/round/join/:roundID` matching `/v1/round/join/4` yields
+// `roundID` -> `4`.
+type params map[string]string
+
+// ByName returns the value of the path parameter `name` or an empty string
+// if the route doesn't define it.
+func (ps params) ByName(name string) string {
+	return ps[name]
+}
+
+// routeNode is a single path segment in the routes tree. Static children are
+// always preferred over the parameter child.
+type routeNode struct {
+	static    map[string]*routeNode
+	param     *routeNode
+	paramName string
+	pattern   string
+	endpoints map[string]*endpoint
+}
+
+func newRouteNode() *routeNode {
+	return &routeNode{
+		static:    make(map[string]*routeNode),
+		endpoints: make(map[string]*endpoint),
+	}
+}
+
+// router matches request paths segment by segment against the registered
+// endpoint patterns and dispatches on the HTTP method.
+type router struct {
+	root *routeNode
+}
+
+func newRouter() *router {
+	return &router{root: newRouteNode()}
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+// add registers the endpoint for all of it's methods. It fails if the
+// pattern is malformed or one of the methods is already registered for the
+// same pattern.
+func (rt *router) add(ep endpoint) error {
+	if !strings.HasPrefix(ep.Name, "/") {
+		return fmt.Errorf("route '%s' must start with '/'", ep.Name)
+	}
+	if len(ep.Methods) == 0 {
+		return fmt.Errorf("route '%s' doesn't declare any methods", ep.Name)
+	}
+	if !ep.Auth.declared {
+		return fmt.Errorf("route '%s' doesn't declare it's auth requirement", ep.Name)
+	}
+
+	n := rt.root
+	for _, seg := range splitPath(ep.Name) {
+		if strings.HasPrefix(seg, ":") {
+			name := seg[1:]
+			if len(name) == 0 {
+				return fmt.Errorf("route '%s' has an unnamed parameter", ep.Name)
+			}
+			if n.param == nil {
+				n.param = newRouteNode()
+				n.param.paramName = name
+			} else if n.param.paramName != name {
+				return fmt.Errorf("route '%s' uses parameter ':%s' where ':%s' is already registered", ep.Name, name, n.param.paramName)
+			}
+			n = n.param
+			continue
+		}
+
+		child, ok := n.static[seg]
+		if !ok {
+			child = newRouteNode()
+			n.static[seg] = child
+		}
+		n = child
+	}
+
+	// Wrap the handler with the route's auth enforcement and own middlewares
+	// once, so requests don't have to build the chain again. Deprecation
+	// headers are added first, so even failed requests announce them.
+	if ep.Handler != nil {
+		var mws []middleware
+		if ep.Version != nil && ep.Version.deprecated() {
+			mws = append(mws, ep.Version.versionHeaders(strings.TrimPrefix(ep.Name, "/"+ep.Version.Name)))
+		}
+		mws = append(mws, authenticate(ep.Auth))
+		ep.Handler = chain(ep.Handler, append(mws, ep.Middlewares...)...)
+	}
+
+	n.pattern = ep.Name
+	for _, m := range ep.Methods {
+		if _, exists := n.endpoints[m]; exists {
+			return fmt.Errorf("route '%s' registered twice for method %s", ep.Name, m)
+		}
+		e := ep
+		n.endpoints[m] = &e
+	}
+	return nil
+}
+
+// match walks the tree for the given path segments and returns the node
+// holding the endpoints. Parameter values are collected into `ps`.
+func (n *routeNode) match(segs []string, ps params) *routeNode {
+	if len(segs) == 0 {
+		if len(n.endpoints) == 0 {
+			return nil
+		}
+		return n
+	}
+
+	if child, ok := n.static[segs[0]]; ok {
+		if m := child.match(segs[1:], ps); m != nil {
+			return m
+		}
+	}
+
+	if n.param != nil && len(segs[0]) > 0 {
+		if m := n.param.match(segs[1:], ps); m != nil {
+			ps[n.param.paramName] = segs[0]
+			return m
+		}
+	}
+
+	return nil
+}
+
+// allowed returns the sorted list of methods registered for this node.
+// HEAD is included whenever GET is registered.
+func (n *routeNode) allowed() []string {
+	methods := make([]string, 0, len(n.endpoints)+1)
+	for m := range n.endpoints {
+		methods = append(methods, m)
+	}
+	if _, ok := n.endpoints["HEAD"]; !ok {
+		if _, ok := n.endpoints["GET"]; ok {
+			methods = append(methods, "HEAD")
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// lookup finds the endpoint for the method and path. `route` is the pattern
+// of the matched route. If the path matches a route but the method doesn't
+// `ep` is nil and `allowed` lists the methods the route supports. If no
+// route matches at all `found` is false. HEAD requests are answered by the
+// GET endpoint unless the route registers HEAD itself.
+func (rt *router) lookup(method, path string) (ep *endpoint, route string, ps params, allowed []string, found bool) {
+	ps = params{}
+	n := rt.root.match(splitPath(path), ps)
+	if n == nil {
+		return nil, "", nil, nil, false
+	}
+
+	ep, ok := n.endpoints[method]
+	if !ok && method == "HEAD" {
+		ep, ok = n.endpoints["GET"]
+	}
+	if !ok {
+		return nil, n.pattern, ps, n.allowed(), true
+	}
+	return ep, n.pattern, ps, nil, true
+}
+
+// handle is the innermost global handler. It finds the endpoint matching the
+// request and executes it's handler chain.
+func (rt *router) handle(req *request) (interface{}, error) {
+	ep, route, ps, allowed, found := rt.lookup(string(req.Method()), string(req.Path()))
+
+	// No route matches the request
+	if !found {
+		return nil, errUnknownEndpoit
+	}
+
+	// Set before the method is checked, so even rejected requests are
+	// logged with their sensitive parameters redacted
+	req.Route = route
+
+	// Route exists but doesn't support the request's method
+	if ep == nil {
+		req.Response.Header.Set("Allow", strings.Join(append(allowed, "OPTIONS"), ", "))
+		return nil, errMethodNotAllowed
+	}
+
+	if ep.Handler == nil {
+		return nil, errNotImplemented
+	}
+
+	req.Params = ps
+	req.Status = ep.Status
+
+	span := vbtrace.Start(req.Log, "handler "+ep.Name, vbtrace.KindInternal)
+	defer span.End()
+	req.Log = vbtrace.WithSpan(req.Log, span)
+
+	r, err := ep.Handler(req)
+	span.SetError(err)
+	return r, err
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func noop(req *request) (interface{}, error) {
+	return nil, nil
+}
+
+func testRouter(t *testing.T, eps ...endpoint) *router {
+	t.Helper()
+	rt := newRouter()
+	for _, ep := range eps {
+		err := rt.add(ep)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rt
+}
+
+func TestRouterLookup(t *testing.T) {
+	rt := testRouter(t,
+		endpoint{Name: "/v1/user/get", Methods: get, Handler: noop, Auth: public},
+		endpoint{Name: "/v1/user/get/id/:id", Methods: get, Handler: noop, Auth: public},
+		endpoint{Name: "/v1/user/get/id/me", Methods: get, Handler: noop, Auth: public},
+		endpoint{Name: "/v1/user/update", Methods: post, Handler: noop, Auth: public},
+		endpoint{Name: "/v1/status", Methods: []string{"GET", "HEAD"}, Handler: noop, Auth: public},
+	)
+
+	tests := []struct {
+		method  string
+		path    string
+		route   string
+		params  params
+		allowed []string
+		found   bool
+	}{
+		{"GET", "/v1/user/get", "/v1/user/get", params{}, nil, true},
+		{"GET", "/v1/user/get/id/4", "/v1/user/get/id/:id", params{"id": "4"}, nil, true},
+		{"GET", "/v1/user/get/id/me", "/v1/user/get/id/me", params{}, nil, true},
+		{"HEAD", "/v1/user/get/id/4", "/v1/user/get/id/:id", params{"id": "4"}, nil, true},
+		{"HEAD", "/v1/status", "/v1/status", params{}, nil, true},
+		{"POST", "/v1/user/get", "/v1/user/get", params{}, []string{"GET", "HEAD"}, true},
+		{"HEAD", "/v1/user/update", "/v1/user/update", params{}, []string{"POST"}, true},
+		{"GET", "/v1/user/get/id/", "", nil, nil, false},
+		{"GET", "/v1/unknown", "", nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			ep, route, ps, allowed, found := rt.lookup(tt.method, tt.path)
+			if found != tt.found {
+				t.Fatalf("want found=%v but got %v", tt.found, found)
+			}
+			if ep != nil && ep.Name != route {
+				t.Errorf("want endpoint of %q but got %q", route, ep.Name)
+			}
+			if route != tt.route {
+				t.Errorf("want route %q but got %q", tt.route, route)
+			}
+			if !reflect.DeepEqual(ps, tt.params) {
+				t.Errorf("want params %v but got %v", tt.params, ps)
+			}
+			if !reflect.DeepEqual(allowed, tt.allowed) {
+				t.Errorf("want allowed %v but got %v", tt.allowed, allowed)
+			}
+		})
+	}
+}
+
+func TestRouterAddRejectsConflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		eps  []endpoint
+	}{
+		{"relative", []endpoint{{Name: "v1/test", Methods: get, Auth: public}}},
+		{"no methods", []endpoint{{Name: "/v1/test", Auth: public}}},
+		{"no auth", []endpoint{{Name: "/v1/test", Methods: get}}},
+		{"unnamed param", []endpoint{{Name: "/v1/user/:", Methods: get, Auth: public}}},
+		{"duplicate", []endpoint{
+			{Name: "/v1/test", Methods: get, Auth: public},
+			{Name: "/v1/test", Methods: get, Auth: public},
+		}},
+		{"param names differ", []endpoint{
+			{Name: "/v1/user/:id", Methods: get, Auth: public},
+			{Name: "/v1/user/:name/x", Methods: get, Auth: public},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newRouter()
+			var err error
+			for _, ep := range tt.eps {
+				if err = rt.add(ep); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				t.Fatal("want error but got none")
+			}
+		})
+	}
+}
+
+func TestHeadAnsweredByGet(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	resp := do(server, testRequest{method: "HEAD", path: "/v1/test"})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+
+	resp = do(server, testRequest{method: "PUT", path: "/v1/test"})
+	if resp.StatusCode() != fasthttp.StatusMethodNotAllowed {
+		t.Fatalf("want 405 but got %d", resp.StatusCode())
+	}
+	if allow := string(resp.Header.Peek("Allow")); allow != "GET, HEAD, OPTIONS" {
+		t.Fatalf("want Allow 'GET, HEAD, OPTIONS' but got %q", allow)
+	}
+}
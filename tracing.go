@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbrest/vbtrace"
+)
+
+// startRequestSpan starts the server span of a request. It continues the
+// caller's trace if the request has a valid `traceparent` header.
+func startRequestSpan(c *fasthttp.RequestCtx) *vbtrace.Span {
+	method := string(c.Method())
+	sc, ok := vbtrace.ParseTraceparent(string(c.Request.Header.Peek("traceparent")))
+	if !ok {
+		return vbtrace.StartSpan(method, vbtrace.KindServer, nil)
+	}
+	return vbtrace.StartSpan(method, vbtrace.KindServer, &sc)
+}
+
+// endRequestSpan names the server span after the matched `route` and
+// records the outcome of the request. Only server errors mark the span as
+// failed, because client errors are expected behaviour.
+func endRequestSpan(req *request, route string) {
+	method := string(req.Method())
+	status := req.Response.StatusCode()
+
+	req.Span.SetName(method + " " + route)
+	req.Span.SetAttribute("http.method", method)
+	req.Span.SetAttribute("http.route", route)
+	req.Span.SetAttribute("http.status_code", status)
+	if req.ErrorCode != 0 {
+		req.Span.SetAttribute("vbrest.error_code", req.ErrorCode)
+	}
+	if status >= 500 {
+		req.Span.Fail(http.StatusText(status))
+	}
+	req.Span.End()
+}
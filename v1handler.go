@@ -1,47 +1,97 @@
 package main
 
 import (
-	"encoding/json"
-
-	"github.com/valyala/fasthttp"
 	"github.com/vikebot/vbcore"
 	"github.com/vikebot/vbrest/vbapi"
 	"go.uber.org/zap"
 )
 
-func v1Test(req *fasthttp.RequestCtx, p string, ctx *zap.Logger) (r interface{}, err error) {
+func v1Test(req *request) (r interface{}, err error) {
 	return &simpleResponse{Response: "ok"}, nil
 }
 
-func v1UserGet(req *fasthttp.RequestCtx, p string, ctx *zap.Logger) (r interface{}, err error) {
-	userID, err := authproxy(req, vbcore.PermissionDefault, ctx)
+func v1MetaErrors(req *request) (r interface{}, err error) {
+	return vbapi.RegisteredErrors(), nil
+}
+
+func v1MetaOpenAPI(req *request) (r interface{}, err error) {
+	return openAPISpec, nil
+}
+
+func v1MetaVersion(req *request) (r interface{}, err error) {
+	return newBuildInfo(), nil
+}
+
+func v1UserGet(req *request) (r interface{}, err error) {
+	return api.UserGet(req.UserID, req.Log)
+}
+
+func v1UserGetPublicByID(req *request) (r interface{}, err error) {
+	return api.UserGetPublicByID(req.Params.ByName("id"), req.Log)
+}
+
+func v1UserGetPublicByUsername(req *request) (r interface{}, err error) {
+	return api.UserGetPublicByUsername(req.Params.ByName("username"), req.Log)
+}
+
+func v1UserUpdate(req *request) (r interface{}, err error) {
+	var user vbcore.User
+	err = decodeLegacyJSON(req, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = api.UserUpdate(req.UserID, &user, "", req.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func v1RoundActive(req *request) (r interface{}, err error) {
+	return api.RoundActive(req.Log)
+}
+
+func v1RoundJoin(req *request) (r interface{}, err error) {
+	err = api.RoundJoin(req.UserID, req.Params.ByName("id"), req.Log)
 	if err != nil {
 		return nil, err
 	}
-	return vbapi.UserGet(userID, ctx)
+	return nil, nil
 }
 
-func v1UserGetPublicByID(req *fasthttp.RequestCtx, p string, ctx *zap.Logger) (r interface{}, err error) {
-	return vbapi.UserGetPublicByID(p[len("/v1/user/get/id/"):], ctx)
+func v1RoundentryActive(req *request) (r interface{}, err error) {
+	roundentries, err := api.RoundentryActive(req.UserID, req.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	return roundentries, nil
 }
 
-func v1UserGetPublicByUsername(req *fasthttp.RequestCtx, p string, ctx *zap.Logger) (r interface{}, err error) {
-	return vbapi.UserGetPublicByUsername(p[len("/v1/user/get/username/"):], ctx)
+func v1RoundentryConnectinfo(req *request) (r interface{}, err error) {
+	return api.RoundentryConnectinfo(req.Params.ByName("authtoken"), req.Log)
 }
 
-func v1UserUpdate(req *fasthttp.RequestCtx, p string, ctx *zap.Logger) (r interface{}, err error) {
-	userID, err := authproxy(req, vbcore.PermissionDefault, ctx)
+func v1RoundentryWatchresolve(req *request) (r interface{}, err error) {
+	websocket, err := api.RoundentryWatchresolve(req.Params.ByName("watchtoken"), req.Log)
 	if err != nil {
 		return nil, err
 	}
+	return websocket, nil
+}
 
-	var user vbcore.User
-	err = json.Unmarshal(req.PostBody(), &user)
+func v1RegisterConfirm(req *request) (r interface{}, err error) {
+	req.Log.Debug("register confirm body", zap.String("json", redactJSON(req.PostBody())))
+
+	var data vbapi.RegisterConfirmRequest
+	err = decodeLegacyJSON(req, &data)
 	if err != nil {
 		return nil, err
 	}
 
-	err = vbapi.UserUpdate(userID, &user, "", ctx)
+	err = api.RegisterConfirm(data, realipFromFasthttp(req.RequestCtx), req.Log)
 	if err != nil {
 		return nil, err
 	}
@@ -49,61 +99,140 @@ func v1UserUpdate(req *fasthttp.RequestCtx, p string, ctx *zap.Logger) (r interf
 	return nil, nil
 }
 
-func v1RoundActive(req *fasthttp.RequestCtx, p string, ctx *zap.Logger) (r interface{}, err error) {
-	return vbapi.RoundActive(ctx)
-}
+func v1AuthLogin(req *request) (r interface{}, err error) {
+	var data vbapi.LoginRequest
+	err = decodeJSON(req, &data)
+	if err != nil {
+		return nil, err
+	}
 
-func v1RoundJoin(req *fasthttp.RequestCtx, p string, ctx *zap.Logger) (r interface{}, err error) {
-	userID, err := authproxy(req, vbcore.PermissionDefault, ctx)
+	resp, err := api.Login(data, realipFromFasthttp(req.RequestCtx), req.Log)
 	if err != nil {
 		return nil, err
 	}
-	err = vbapi.RoundJoin(userID, p[len("/v1/round/join/"):], ctx)
+
+	setTokenCookies(req, resp)
+	return resp, nil
+}
+
+func v1AuthRefresh(req *request) (r interface{}, err error) {
+	var data vbapi.RefreshRequest
+	if len(req.PostBody()) > 0 {
+		err = decodeJSON(req, &data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Browser clients send their refresh token via cookie
+	if data.RefreshToken == nil {
+		if c := req.Request.Header.Cookie(refreshCookieName); len(c) > 0 {
+			token := string(c)
+			data.RefreshToken = &token
+		}
+	}
+
+	resp, err := api.Refresh(data, realipFromFasthttp(req.RequestCtx), req.Log)
 	if err != nil {
 		return nil, err
 	}
-	return nil, nil
+
+	setTokenCookies(req, resp)
+	return resp, nil
 }
 
-func v1RoundentryActive(req *fasthttp.RequestCtx, p string, ctx *zap.Logger) (r interface{}, err error) {
-	userID, err := authproxy(req, vbcore.PermissionDefault, ctx)
+func v1OAuthStart(req *request) (r interface{}, err error) {
+	resp, err := api.OAuthStart(req.Params.ByName("provider"), req.Log)
 	if err != nil {
 		return nil, err
 	}
 
-	roundentries, err := vbapi.RoundentryActive(userID, ctx)
+	setOAuthCookie(req, resp.State, oauthCookieMaxAge)
+	return resp, nil
+}
+
+func v1OAuthCallback(req *request) (r interface{}, err error) {
+	args := req.QueryArgs()
+	resp, err := api.OAuthCallback(
+		req.Params.ByName("provider"),
+		string(args.Peek("code")),
+		string(args.Peek("state")),
+		string(req.Request.Header.Cookie(oauthCookieName)),
+		realipFromFasthttp(req.RequestCtx),
+		req.Log)
 	if err != nil {
 		return nil, err
 	}
 
-	return roundentries, nil
+	// The state is consumed, so the binding isn't needed anymore
+	setOAuthCookie(req, "", 0)
+	if resp.TokenResponse != nil {
+		setTokenCookies(req, resp.TokenResponse)
+	}
+	return resp, nil
 }
 
-func v1RoundentryConnectinfo(req *fasthttp.RequestCtx, p string, ctx *zap.Logger) (r interface{}, err error) {
-	return vbapi.RoundentryConnectinfo(p[len("/v1/roundentry/connectinfo/"):], ctx)
+func v1AuthLogout(req *request) (r interface{}, err error) {
+	err = api.Logout(req.UserID, req.TokenID, req.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	clearTokenCookies(req)
+	return nil, nil
 }
 
-func v1RoundentryWatchresolve(req *fasthttp.RequestCtx, p string, ctx *zap.Logger) (r interface{}, err error) {
-	websocket, err := vbapi.RoundentryWatchresolve(p[len("/v1/roundentry/watchresolve/"):], ctx)
+func v1AuthPasswordSet(req *request) (r interface{}, err error) {
+	var data vbapi.PasswordSetRequest
+	err = decodeJSON(req, &data)
 	if err != nil {
 		return nil, err
 	}
-	return websocket, nil
+
+	return nil, api.PasswordSet(req.UserID, data, req.Log)
 }
 
-func v1RegisterConfirm(req *fasthttp.RequestCtx, p string, ctx *zap.Logger) (r interface{}, err error) {
-	ctx.Debug("", zap.String("json", string(req.PostBody())))
+func v1AuthSessions(req *request) (r interface{}, err error) {
+	return api.Sessions(req.UserID, req.TokenID, req.Log)
+}
 
-	var data vbapi.RegisterConfirmRequest
-	err = json.Unmarshal(req.PostBody(), &data)
+func v1AuthSessionRevoke(req *request) (r interface{}, err error) {
+	err = api.SessionRevoke(req.UserID, req.Params.ByName("jti"), req.Log)
 	if err != nil {
 		return nil, err
 	}
 
-	err = vbapi.RegisterConfirm(data, realipFromFasthttp(req), ctx)
+	// Revoking the token used for this request equals a logout
+	if req.Params.ByName("jti") == req.TokenID {
+		clearTokenCookies(req)
+	}
+	return nil, nil
+}
+
+func v1AdminTokenCreate(req *request) (r interface{}, err error) {
+	var data vbapi.AdminTokenRequest
+	err = decodeJSON(req, &data)
 	if err != nil {
 		return nil, err
 	}
 
+	return api.AdminTokenCreate(req.UserID, data, realipFromFasthttp(req.RequestCtx), req.Log)
+}
+
+func v1AdminPermissionSet(req *request) (r interface{}, err error) {
+	var data vbapi.PermissionSetRequest
+	err = decodeJSON(req, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = api.AdminPermissionSet(req.UserID, req.Permission, req.Params.ByName("id"), data, realipFromFasthttp(req.RequestCtx), req.Log)
+	if err != nil {
+		return nil, err
+	}
 	return nil, nil
 }
+
+func v1AdminAudit(req *request) (r interface{}, err error) {
+	return api.AdminAudit(string(req.QueryArgs().Peek("limit")), req.Log)
+}
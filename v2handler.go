@@ -0,0 +1,36 @@
+package main
+
+import "github.com/vikebot/vbcore"
+
+// v2RoundEntryCreate joins the round like `v1RoundJoin`, but answers with
+// the created roundentry instead of a plain "ok"
+func v2RoundEntryCreate(req *request) (r interface{}, err error) {
+	err = api.RoundJoin(req.UserID, req.Params.ByName("id"), req.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := api.RoundentryGet(req.UserID, req.Params.ByName("id"), req.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// v2UserUpdate updates the profile like `v1UserUpdate`, but rejects unknown
+// fields and invalid profiles instead of ignoring them
+func v2UserUpdate(req *request) (r interface{}, err error) {
+	var user vbcore.User
+	err = decodeJSON(req, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = api.UserUpdateValidated(req.UserID, &user, "", req.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
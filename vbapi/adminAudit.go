@@ -0,0 +1,68 @@
+package vbapi
+
+import (
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// AuditActionTokenCreate is recorded whenever an admin mints a token
+	AuditActionTokenCreate = "token_create"
+
+	auditDefaultLimit = 50
+	auditMaxLimit     = 500
+)
+
+// AuditEntry records a single privileged action performed through the admin
+// API.
+type AuditEntry struct {
+	ID           int               `json:"id"`
+	ActorID      int               `json:"actor_id"`
+	Action       string            `json:"action"`
+	TargetUserID int               `json:"target_user_id"`
+	Reason       string            `json:"reason"`
+	Details      map[string]string `json:"details"`
+	IP           string            `json:"ip"`
+	Time         time.Time         `json:"time"`
+}
+
+// audit records the entry. Callers must not complete the audited action if
+// recording fails.
+func (s *Service) audit(entry AuditEntry, ctx *zap.Logger) error {
+	entry.Time = time.Now().UTC()
+	success := s.store.AuditAdd(entry, ctx)
+	if !success {
+		return errInternalServerError
+	}
+	logAudit(entry, ctx)
+	return nil
+}
+
+// logAudit logs an entry after it was recorded
+func logAudit(entry AuditEntry, ctx *zap.Logger) {
+	ctx.Info("audit",
+		zap.Int("actor_id", entry.ActorID),
+		zap.String("action", entry.Action),
+		zap.Int("target_user_id", entry.TargetUserID))
+}
+
+// AdminAudit returns the latest `limit` audit entries, newest first. An
+// empty `limit` returns the default amount.
+func (s *Service) AdminAudit(limit string, ctx *zap.Logger) ([]AuditEntry, error) {
+	n := auditDefaultLimit
+	if len(limit) > 0 {
+		var err error
+		n, err = strconv.Atoi(limit)
+		if err != nil || n < 1 || n > auditMaxLimit {
+			return nil, errInvalidAuditLimit
+		}
+	}
+
+	entries, success := s.store.Audit(n, ctx)
+	if !success {
+		return nil, errInternalServerError
+	}
+	return entries, nil
+}
@@ -0,0 +1,133 @@
+package vbapi
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vikebot/vbcore"
+	"go.uber.org/zap"
+)
+
+// AuditActionPermissionSet is recorded whenever a user's permission changes
+const AuditActionPermissionSet = "permission_set"
+
+// PermissionSetRequest changes the permission of a user. `Permission` is one
+// of the `vbcore.Permission*String` values. `ExpiresAt` can only be set when
+// banning and makes the ban lift automatically.
+type PermissionSetRequest struct {
+	Permission *string    `json:"permission"`
+	Reason     *string    `json:"reason"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+var permissionLevels = map[string]int{
+	vbcore.PermissionBannedString:   vbcore.PermissionBanned,
+	vbcore.PermissionDefaultString:  vbcore.PermissionDefault,
+	vbcore.PermissionVerifiedString: vbcore.PermissionVerified,
+	vbcore.PermissionTeamString:     vbcore.PermissionTeam,
+	vbcore.PermissionAdminString:    vbcore.PermissionAdmin,
+}
+
+// AdminPermissionSet changes the permission of the user `targetID`. Team
+// members can move users between banned, default and verified, only admins
+// can grant or revoke team and admin. Nobody can change their own
+// permission. The change and it's audit entry are recorded together. Banning
+// a user revokes all of it's tokens immediately afterwards.
+func (s *Service) AdminPermissionSet(actorID, actorPermission int, targetID string, data PermissionSetRequest, ip string, ctx *zap.Logger) error {
+	id, err := strconv.Atoi(targetID)
+	if err != nil {
+		return errUserIDMustBeInt
+	}
+	if data.Permission == nil {
+		return errInvalidPermission
+	}
+	permission, ok := permissionLevels[*data.Permission]
+	if !ok {
+		return errInvalidPermission
+	}
+	if data.Reason == nil || len(strings.TrimSpace(*data.Reason)) == 0 {
+		return errReasonMissing
+	}
+	if data.ExpiresAt != nil && (permission != vbcore.PermissionBanned || !data.ExpiresAt.After(time.Now())) {
+		return errInvalidBanExpiry
+	}
+	if id == actorID {
+		return errOwnPermission
+	}
+
+	target, success := s.store.UserFromID(id, ctx)
+	if !success {
+		return errInternalServerError
+	}
+	if target == nil {
+		return errUserIDDoesnotExist
+	}
+
+	// Only admins may touch team and admin permissions, in both directions
+	if actorPermission < vbcore.PermissionAdmin &&
+		(permission > vbcore.PermissionVerified || target.Permission > vbcore.PermissionVerified) {
+		return errPermissionDenied
+	}
+
+	audit := AuditEntry{
+		ActorID:      actorID,
+		Action:       AuditActionPermissionSet,
+		TargetUserID: id,
+		Reason:       *data.Reason,
+		Details: map[string]string{
+			"old": vbcore.PermissionItoA(target.Permission),
+			"new": vbcore.PermissionItoA(permission),
+		},
+		IP:   ip,
+		Time: time.Now().UTC(),
+	}
+	if permission == vbcore.PermissionBanned {
+		// Re-banning keeps the permission from before the first ban
+		previous := target.Permission
+		if previous == vbcore.PermissionBanned {
+			ban, success := s.store.UserBan(id, ctx)
+			if !success {
+				return errInternalServerError
+			}
+			previous = vbcore.PermissionDefault
+			if ban != nil {
+				previous = ban.Previous
+			}
+		}
+		if data.ExpiresAt != nil {
+			audit.Details["expires_at"] = data.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+
+		success = s.store.UserBanSet(id, Ban{
+			Reason:    *data.Reason,
+			ExpiresAt: data.ExpiresAt,
+			Previous:  previous,
+		}, &audit, ctx)
+	} else {
+		success = s.store.UserPermissionSet(id, permission, *data.Reason, &audit, ctx)
+	}
+	if !success {
+		return errInternalServerError
+	}
+	logAudit(audit, ctx)
+
+	if permission == vbcore.PermissionBanned {
+		return s.revokeUserTokens(id, ctx)
+	}
+	return nil
+}
+
+// revokeUserTokens blacklists all JWTs and revokes all refresh tokens of
+// the user
+func (s *Service) revokeUserTokens(userID int, ctx *zap.Logger) error {
+	success := s.store.RefreshTokenRevokeUser(userID, ctx)
+	if !success {
+		return errInternalServerError
+	}
+	success = s.store.JwtBlacklistUser(userID, ctx)
+	if !success {
+		return errInternalServerError
+	}
+	return nil
+}
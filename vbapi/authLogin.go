@@ -0,0 +1,87 @@
+package vbapi
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vikebot/vbcore"
+	"go.uber.org/zap"
+)
+
+const (
+	// Failed logins allowed per account and per IP within the window before
+	// further attempts are refused
+	loginMaxAccountFailures = 5
+	loginMaxIPFailures      = 20
+	loginFailureWindow      = time.Minute * 15
+	loginLockoutDuration    = time.Minute * 15
+)
+
+var (
+	// dummy credentials used to verify passwords of unknown users, so the
+	// response time doesn't reveal whether a username exists
+	loginDummyHash, loginDummySalt, _ = vbcore.CryptoPwd("vikebot")
+)
+
+// LoginRequest contains the credentials sent by clients to `/v1/auth/login`
+type LoginRequest struct {
+	Username *string `json:"username"`
+	Password *string `json:"password"`
+}
+
+// Login verifies the user's credentials and issues a JWT only usable from
+// `ip` together with a refresh token starting a new family. Accounts and IPs
+// with too many failed attempts are locked out for a while.
+func (s *Service) Login(data LoginRequest, ip string, ctx *zap.Logger) (*TokenResponse, error) {
+	if data.Username == nil || len(*data.Username) == 0 || data.Password == nil || len(*data.Password) == 0 {
+		return nil, errCredentialsMissing
+	}
+
+	accountKey := "account:" + strings.ToLower(*data.Username)
+	ipKey := "ip:" + ip
+
+	// Refuse before verifying anything if ether the account or the IP is
+	// locked
+	for _, check := range []struct {
+		l   *lockout
+		key string
+	}{
+		{s.accountLockout, accountKey},
+		{s.ipLockout, ipKey},
+	} {
+		if remaining, locked := check.l.locked(check.key); locked {
+			ctx.Warn("login locked out", zap.String("key", check.key))
+			return nil, withMessage(errLoginLockedOut, errLoginLockedOut.Message()+" Try again in "+strconv.Itoa(int(remaining.Seconds())+1)+" seconds.")
+		}
+	}
+
+	userID, hash, salt, exists, success := s.store.UserCredentials(*data.Username, ctx)
+	if !success {
+		return nil, errInternalServerError
+	}
+	if !exists {
+		hash, salt = loginDummyHash, loginDummySalt
+	}
+
+	ok, err := vbcore.CryptoPwdVerify(hash, salt, *data.Password)
+	if err != nil {
+		ctx.Error("verifying password failed", zap.Error(err))
+		return nil, errInternalServerError
+	}
+	if !exists || !ok {
+		s.accountLockout.fail(accountKey)
+		s.ipLockout.fail(ipKey)
+		ctx.Info("login failed", zap.String("username", *data.Username))
+		return nil, errInvalidCredentials
+	}
+	s.accountLockout.reset(accountKey)
+
+	resp, err := s.issueTokens(userID, "", ip, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.Info("login succeeded", zap.Int("user_id", userID))
+	return resp, nil
+}
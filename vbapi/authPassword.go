@@ -0,0 +1,91 @@
+package vbapi
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vikebot/vbcore"
+	"go.uber.org/zap"
+)
+
+const (
+	passwordMinLength = 8
+	passwordMaxLength = 128
+)
+
+// PasswordSetRequest changes the password of the authenticated user.
+// `CurrentPassword` is only needed if the user already has a password, e.g.
+// users who registered through OAuth can set their first one without it.
+type PasswordSetRequest struct {
+	CurrentPassword *string `json:"current_password"`
+	Password        *string `json:"password"`
+}
+
+// validPassword checks the length of a new password
+func validPassword(password string) bool {
+	l := utf8.RuneCountInString(password)
+	return l >= passwordMinLength && l <= passwordMaxLength
+}
+
+// setPassword hashes the password and stores it for the user
+func (s *Service) setPassword(userID int, password string, msg string, ctx *zap.Logger) error {
+	hash, salt, err := vbcore.CryptoPwd(password)
+	if err != nil {
+		ctx.Error("hashing password failed", zap.Error(err))
+		return errInternalServerError
+	}
+	if !s.store.UserPasswordSet(userID, hash, salt, msg, ctx) {
+		return errInternalServerError
+	}
+	return nil
+}
+
+// PasswordSet changes the password the user logs in with. Wrong current
+// passwords count as failed logins of the account, so they can't be used to
+// guess it.
+func (s *Service) PasswordSet(userID int, data PasswordSetRequest, ctx *zap.Logger) error {
+	if data.Password == nil || !validPassword(*data.Password) {
+		return errPasswordInvalid
+	}
+
+	user, success := s.store.UserFromID(userID, ctx)
+	if !success {
+		return errInternalServerError
+	}
+	if user == nil {
+		return errUserIDDoesnotExist
+	}
+
+	_, hash, salt, exists, success := s.store.UserCredentials(user.Username, ctx)
+	if !success {
+		return errInternalServerError
+	}
+	if exists {
+		accountKey := "account:" + strings.ToLower(user.Username)
+		if remaining, locked := s.accountLockout.locked(accountKey); locked {
+			ctx.Warn("password change locked out", zap.String("key", accountKey))
+			return withMessage(errLoginLockedOut, errLoginLockedOut.Message()+" Try again in "+strconv.Itoa(int(remaining.Seconds())+1)+" seconds.")
+		}
+		if data.CurrentPassword == nil {
+			return errCurrentPasswordInvalid
+		}
+		ok, err := vbcore.CryptoPwdVerify(hash, salt, *data.CurrentPassword)
+		if err != nil {
+			ctx.Error("verifying password failed", zap.Error(err))
+			return errInternalServerError
+		}
+		if !ok {
+			s.accountLockout.fail(accountKey)
+			ctx.Info("password change with wrong current password")
+			return errCurrentPasswordInvalid
+		}
+	}
+
+	err := s.setPassword(userID, *data.Password, "password_set", ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Info("password changed")
+	return nil
+}
@@ -0,0 +1,81 @@
+package vbapi
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Session describes a single issued JWT of a user
+type Session struct {
+	JTI       string    `json:"jti"`
+	IP        string    `json:"ip"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Current is true for the token used to make the request
+	Current bool `json:"current"`
+}
+
+// Logout blacklists the token `jti` the user is currently authenticated
+// with and revokes the refresh token family it was issued with.
+func (s *Service) Logout(userID int, jti string, ctx *zap.Logger) error {
+	_, success := s.store.JwtBlacklist(userID, jti, ctx)
+	if !success {
+		return errInternalServerError
+	}
+
+	family, exists, success := s.store.RefreshTokenFamily(jti, ctx)
+	if !success {
+		return errInternalServerError
+	}
+	if exists {
+		err := s.revokeFamily(userID, family, ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx.Info("logged out", zap.String("jti", jti))
+	return nil
+}
+
+// Sessions lists all tokens of the user which are still usable. The token
+// with the ID `currentJTI` is marked as current.
+func (s *Service) Sessions(userID int, currentJTI string, ctx *zap.Logger) ([]Session, error) {
+	sessions, success := s.store.JwtSessions(userID, ctx)
+	if !success {
+		return nil, errInternalServerError
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].JTI == currentJTI
+	}
+	return sessions, nil
+}
+
+// SessionRevoke blacklists one of the user's tokens, e.g. because it was
+// stolen, and revokes the refresh token family it was issued with like
+// `Logout` does. Otherwise the session could mint new tokens.
+func (s *Service) SessionRevoke(userID int, jti string, ctx *zap.Logger) error {
+	found, success := s.store.JwtBlacklist(userID, jti, ctx)
+	if !success {
+		return errInternalServerError
+	}
+	if !found {
+		return errSessionNotFound
+	}
+
+	family, exists, success := s.store.RefreshTokenFamily(jti, ctx)
+	if !success {
+		return errInternalServerError
+	}
+	if exists {
+		err := s.revokeFamily(userID, family, ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx.Info("session revoked", zap.String("jti", jti))
+	return nil
+}
@@ -0,0 +1,136 @@
+package vbapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/vikebot/vbcore"
+	"go.uber.org/zap"
+)
+
+// RefreshToken is the server-side state of a refresh token. Only the hash of
+// the token itself is stored. All tokens created by rotating the same
+// initial token share the same `Family`.
+type RefreshToken struct {
+	Hash      string
+	Family    string
+	UserID    int
+	AccessJTI string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	Used      bool
+	Revoked   bool
+}
+
+// TokenResponse is returned after a successful login or token refresh
+type TokenResponse struct {
+	UserID           int       `json:"user_id"`
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// RefreshRequest contains the refresh token sent by clients to
+// `/v1/auth/refresh`
+type RefreshRequest struct {
+	RefreshToken *string `json:"refresh_token"`
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokens generates a new JWT bound to `ip` together with a refresh token
+// belonging to `family`. Tokens are always signed with the current default
+// signing key, so rotating keys never requires users to log in again.
+func (s *Service) issueTokens(userID int, family string, ip string, ctx *zap.Logger) (*TokenResponse, error) {
+	now := time.Now().UTC()
+	expires := now.Add(s.config.AccessTokenLifetime)
+
+	token, jti, err := s.issueToken(userID, expires, ip, []string{ip}, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := vbcore.CryptoGenString(48)
+	if err != nil {
+		ctx.Error("unable to generate refresh token", zap.Error(err))
+		return nil, errInternalServerError
+	}
+	if len(family) == 0 {
+		family, err = vbcore.CryptoGenString(32)
+		if err != nil {
+			ctx.Error("unable to generate refresh token family", zap.Error(err))
+			return nil, errInternalServerError
+		}
+	}
+
+	rt := RefreshToken{
+		Hash:      hashRefreshToken(refresh),
+		Family:    family,
+		UserID:    userID,
+		AccessJTI: jti,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.config.RefreshTokenLifetime),
+	}
+	success := s.store.RefreshTokenAdd(rt, ctx)
+	if !success {
+		return nil, errInternalServerError
+	}
+
+	return &TokenResponse{
+		UserID:           userID,
+		Token:            token,
+		ExpiresAt:        expires,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: rt.ExpiresAt,
+	}, nil
+}
+
+// revokeFamily revokes all refresh tokens of the family and blacklists every
+// JWT issued together with them.
+func (s *Service) revokeFamily(userID int, family string, ctx *zap.Logger) error {
+	jtis, success := s.store.RefreshTokenRevokeFamily(family, ctx)
+	if !success {
+		return errInternalServerError
+	}
+	for _, jti := range jtis {
+		_, success = s.store.JwtBlacklist(userID, jti, ctx)
+		if !success {
+			return errInternalServerError
+		}
+	}
+	return nil
+}
+
+// Refresh exchanges a refresh token for a new JWT and a new refresh token.
+// Every refresh token can only be used once. If a used token is presented
+// again it was most likely stolen, so the whole family gets revoked.
+func (s *Service) Refresh(data RefreshRequest, ip string, ctx *zap.Logger) (*TokenResponse, error) {
+	if data.RefreshToken == nil || len(*data.RefreshToken) == 0 {
+		return nil, errRefreshTokenMissing
+	}
+
+	rt, success := s.store.RefreshTokenUse(hashRefreshToken(*data.RefreshToken), ctx)
+	if !success {
+		return nil, errInternalServerError
+	}
+	if rt == nil || rt.Revoked || time.Now().After(rt.ExpiresAt) {
+		return nil, errRefreshTokenInvalid
+	}
+	if rt.Used {
+		ctx.Warn("refresh token reused. revoking family",
+			zap.Int("user_id", rt.UserID),
+			zap.String("family", rt.Family))
+		err := s.revokeFamily(rt.UserID, rt.Family, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return nil, errRefreshTokenReused
+	}
+
+	return s.issueTokens(rt.UserID, rt.Family, ip, ctx)
+}
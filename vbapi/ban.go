@@ -0,0 +1,84 @@
+package vbapi
+
+import (
+	"time"
+
+	"github.com/vikebot/vbcore"
+	"github.com/vikebot/vbnet"
+	"go.uber.org/zap"
+)
+
+// Ban describes why and until when a user is banned
+type Ban struct {
+	Reason string
+	// ExpiresAt is nil for permanent bans
+	ExpiresAt *time.Time
+	// Previous is the permission restored once the ban expires
+	Previous int
+}
+
+// BannedError is returned for every authenticated request of a banned user.
+type BannedError struct {
+	vbnet.HTTPError
+	Reason    string
+	ExpiresAt *time.Time
+}
+
+// BanDetails are the details of a `BannedError`
+type BanDetails struct {
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+var _ DetailedError = (*BannedError)(nil)
+
+// Details implements `DetailedError`
+func (e *BannedError) Details() interface{} {
+	return &BanDetails{
+		Reason:    e.Reason,
+		ExpiresAt: e.ExpiresAt,
+	}
+}
+
+func newBannedError(ban *Ban) *BannedError {
+	msg := errBanned.Message()
+	if len(ban.Reason) > 0 {
+		msg += ": " + ban.Reason
+	}
+	if ban.ExpiresAt != nil {
+		msg += ". The ban expires at " + ban.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	return &BannedError{
+		HTTPError: withMessage(errBanned, msg),
+		Reason:    ban.Reason,
+		ExpiresAt: ban.ExpiresAt,
+	}
+}
+
+// BanCheck must be called for every authenticated request of a user with
+// `vbcore.PermissionBanned`. If the user's ban expired it is lifted and the
+// restored permission is returned, otherwise a `*BannedError`.
+func (s *Service) BanCheck(userID int, ctx *zap.Logger) (permission int, err error) {
+	ban, success := s.store.UserBan(userID, ctx)
+	if !success {
+		return 0, errInternalServerError
+	}
+	if ban == nil {
+		// Banned without details (e.g. directly in the database)
+		ban = &Ban{}
+	}
+
+	if ban.ExpiresAt == nil || time.Now().Before(*ban.ExpiresAt) {
+		return 0, newBannedError(ban)
+	}
+
+	success = s.store.UserPermissionSet(userID, ban.Previous, "Ban expired", nil, ctx)
+	if !success {
+		return 0, errInternalServerError
+	}
+	ctx.Info("ban expired and lifted",
+		zap.Int("user_id", userID),
+		zap.String("permission", vbcore.PermissionItoA(ban.Previous)))
+	return ban.Previous, nil
+}
@@ -1,46 +1,182 @@
 package vbapi
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"sync"
 
 	"github.com/vikebot/vbnet"
 )
 
-const (
-	codeInternalServerError     = 11000
-	codeUserIDMustBeInt         = 11001
-	codeUserIDDoesnotExist      = 11002
-	codeUsernameDoesnotExist    = 11003
-	codeInvalidAuthtokenFormat  = 11004
-	codeUnknownAuthtoken        = 11005
-	codeInvalidUserIDFormat     = 11006
-	codeInvalidRoundIDFormat    = 11007
-	codeAlreadyJoined           = 11008
-	codeRoundNotExists          = 11009
-	codeInvalidWatchtokenFormat = 11010
-	codeUnknownWatchtoken       = 11011
-
-	codeInvalidRegisterCode           = 11010
-	codeUserCannotBeNull              = 11011
-	codeBadUserState                  = 11012
-	codeAlreadyFinishedRegistration   = 11013
-	codeRegistrationCodeUnknown       = 11014
-	codeEmailAddressManipulated       = 11015
-	codeEmailStatusManipulated        = 11016
-	codeCannotUseMultiplePrimaryEmail = 11017
-	codeEmailQuotaExhausted           = 11018
-	codeInvalidEmailVerificationCode  = 11019
-	codeRegisterVerificationEntry     = 11020
-	codeMustHavePrimaryEmail          = 11021
-	codeManipulatedWebLink            = 11022
-	codeInvalidSocialPlatfrom         = 11023
-	codeRecaptchaNotTicked            = 11024
+// ErrorInfo describes a registered error as published in the error
+// catalogue
+type ErrorInfo struct {
+	Code       int `json:"code"`
+	HTTPStatus int `json:"http_status"`
+	// LegacyHTTPStatus is the status `/v1` clients get unless they opt in
+	// to the current status policy. Zero if it equals `HTTPStatus`.
+	LegacyHTTPStatus int    `json:"legacy_http_status,omitempty"`
+	Message          string `json:"message"`
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[int]ErrorInfo)
+)
+
+// RegisterError declares an error and returns it. Every code can only be
+// registered once. Registering a code twice panics, so collisions are
+// detected as soon as the binary starts.
+func RegisterError(code int, httpStatus int, message string) vbnet.HTTPError {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if existing, ok := registry[code]; ok {
+		panic(fmt.Sprintf("vbapi: error code %d registered twice (%q and %q)", code, existing.Message, message))
+	}
+	registry[code] = ErrorInfo{
+		Code:       code,
+		HTTPStatus: httpStatus,
+		Message:    message,
+	}
+	return vbnet.NewHTTPError(message, httpStatus, code, nil)
+}
+
+// RegisterLegacyError is the same as `RegisterError` for errors whose status
+// changed with the status policy. `/v1` clients which didn't opt in to the
+// policy still get `legacyStatus`.
+func RegisterLegacyError(code int, httpStatus int, legacyStatus int, message string) vbnet.HTTPError {
+	err := RegisterError(code, httpStatus, message)
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	info := registry[code]
+	info.LegacyHTTPStatus = legacyStatus
+	registry[code] = info
+	return err
+}
+
+// LegacyHTTPStatus returns the status `/v1` clients without opt in get for
+// the error `code`. `ok` is false if the status didn't change.
+func LegacyHTTPStatus(code int) (status int, ok bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	info, exists := registry[code]
+	if !exists || info.LegacyHTTPStatus == 0 {
+		return 0, false
+	}
+	return info.LegacyHTTPStatus, true
+}
+
+// RegisteredErrors returns all registered errors sorted by their code
+func RegisteredErrors() []ErrorInfo {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	errs := make([]ErrorInfo, 0, len(registry))
+	for _, info := range registry {
+		errs = append(errs, info)
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Code < errs[j].Code
+	})
+	return errs
+}
+
+// withMessage returns a copy of the registered error `err` with a more
+// specific message
+func withMessage(err vbnet.HTTPError, message string) vbnet.HTTPError {
+	return vbnet.NewHTTPError(message, err.HTTPCode(), err.Code(), nil)
+}
+
+// DetailedError is implemented by errors that carry machine-readable details
+// in addition to their message, e.g. which fields failed validation.
+// `Details` is marshaled to JSON.
+type DetailedError interface {
+	vbnet.HTTPError
+	Details() interface{}
+}
+
+// The token errors keep the codes vbjwt used, because clients already handle
+// them
+var (
+	errJwtEmpty                   = RegisterError(10000, http.StatusBadRequest, "Empty JWTs aren't allowed")
+	errJwtTooOld                  = RegisterError(10001, http.StatusBadRequest, "JWT signing key too old. Please refresh your token.")
+	errJwtMalformed               = RegisterError(10002, http.StatusBadRequest, "JWT malformed")
+	errJwtExpired                 = RegisterError(10003, http.StatusForbidden, "JWT already expired")
+	errJwtInvalidSignature        = RegisterError(10004, http.StatusForbidden, "JWT signature invalid")
+	errJwtUnverifiable            = RegisterError(10005, http.StatusBadRequest, "JWT unverifiable")
+	errJwtInvalid                 = RegisterError(10006, http.StatusForbidden, "JWT token invalid")
+	errJwtInvalidAudience         = RegisterError(10007, http.StatusForbidden, "JWT isn't for this service")
+	errJwtInvalidIssuer           = RegisterError(10008, http.StatusForbidden, "JWT is from an untrusted issuer")
+	errJwtBlacklisted             = RegisterError(10009, http.StatusForbidden, "JWT already blacklisted")
+	errJwtInternalServerError     = RegisterError(10010, http.StatusInternalServerError, "Internal server error")
+	errUnauthorizedRequestOrigin  = RegisterError(10011, http.StatusForbidden, "Unauthorized request origin. Your IP isn't allowed to use this JWT")
+	errJwtUnexpectedSigningMethod = RegisterError(10013, http.StatusBadRequest, "Unexpected signing method. Want HS512")
 )
 
+// The status of errors follows this policy:
+//
+//	400 the request is malformed, e.g. a path parameter has the wrong format
+//	401 authentication is missing or invalid
+//	403 the user isn't allowed to do this
+//	404 a referenced resource doesn't exist
+//	409 the request conflicts with the resource's current state
+//	422 the request is well-formed but fails validation
+//	428 a multi-step flow needs additional input before it can continue
+//	429 a rate limit or quota is exhausted
 var (
-	errInternalServerError = vbnet.NewHTTPError(
-		"Internal Server Error",
-		http.StatusInternalServerError,
-		codeInternalServerError,
-		nil)
+	errInternalServerError           = RegisterError(11000, http.StatusInternalServerError, "Internal Server Error")
+	errUserIDMustBeInt               = RegisterError(11001, http.StatusBadRequest, "user_id must be an int")
+	errUserIDDoesnotExist            = RegisterError(11002, http.StatusNotFound, "user_id doesn't exist")
+	errUsernameDoesnotExist          = RegisterLegacyError(11003, http.StatusNotFound, http.StatusOK, "username doesn't exist")
+	errInvalidAuthtokenFormat        = RegisterError(11004, http.StatusBadRequest, "Invalid authtoken format")
+	errUnknownAuthtoken              = RegisterLegacyError(11005, http.StatusNotFound, http.StatusOK, "Unknown authtoken")
+	errInvalidUserIDFormat           = RegisterError(11006, http.StatusBadRequest, "User id must be greater than 0")
+	errInvalidRoundIDFormat          = RegisterError(11007, http.StatusBadRequest, "Round id must be an int greater than 0")
+	errAlreadyJoined                 = RegisterLegacyError(11008, http.StatusConflict, http.StatusForbidden, "User already joined this round")
+	errRoundNotExists                = RegisterLegacyError(11009, http.StatusNotFound, http.StatusBadRequest, "Specified round doesn't exist")
+	errInvalidWatchtokenFormat       = RegisterError(11010, http.StatusBadRequest, "Invalid watchtoken format")
+	errUnknownWatchtoken             = RegisterLegacyError(11011, http.StatusNotFound, http.StatusForbidden, "Unknown watchtoken.")
+	errBadUserState                  = RegisterLegacyError(11012, http.StatusUnprocessableEntity, http.StatusBadRequest, "User state is invalid")
+	errAlreadyFinishedRegistration   = RegisterLegacyError(11013, http.StatusConflict, http.StatusBadRequest, "You already finished registration")
+	errRegistrationCodeUnknown       = RegisterLegacyError(11014, http.StatusNotFound, http.StatusBadRequest, "Registration code unknown")
+	errEmailAddressManipulated       = RegisterLegacyError(11015, http.StatusUnprocessableEntity, http.StatusBadRequest, "Email addresses manipulated")
+	errEmailStatusManipulated        = RegisterLegacyError(11016, http.StatusUnprocessableEntity, http.StatusBadRequest, "Email address status manipulated")
+	errCannotUseMultiplePrimaryEmail = RegisterLegacyError(11017, http.StatusUnprocessableEntity, http.StatusBadRequest, "Cannot use multiple primary addresses")
+	errEmailQuotaExhausted           = RegisterLegacyError(11018, http.StatusTooManyRequests, http.StatusBadRequest, "Unable to send verification email. Quota for user exhausted.")
+	errInvalidEmailVerificationCode  = RegisterLegacyError(11019, http.StatusUnprocessableEntity, http.StatusBadRequest, "Invalid verification code")
+	errRegisterVerificationEntry     = RegisterLegacyError(11020, http.StatusPreconditionRequired, http.StatusExpectationFailed, "Please enter the code sent to your primary email address in the verification box.")
+	errMustHavePrimaryEmail          = RegisterLegacyError(11021, http.StatusUnprocessableEntity, http.StatusBadRequest, "Must have primary email. Request manipulated")
+	errManipulatedWebLink            = RegisterLegacyError(11022, http.StatusUnprocessableEntity, http.StatusBadRequest, "Invalid web link. Manipulated")
+	errInvalidSocialPlatfrom         = RegisterLegacyError(11023, http.StatusUnprocessableEntity, http.StatusBadRequest, "Invalid social platform. Request manipulated")
+	errRecaptchaNotTicked            = RegisterLegacyError(11024, http.StatusUnprocessableEntity, http.StatusBadRequest, "Recaptcha not ticked")
+	errCredentialsMissing            = RegisterError(11025, http.StatusUnprocessableEntity, "Username and password must be provided")
+	errInvalidCredentials            = RegisterError(11026, http.StatusUnauthorized, "Invalid username or password")
+	errLoginLockedOut                = RegisterError(11027, http.StatusTooManyRequests, "Too many failed login attempts.")
+	errSessionNotFound               = RegisterError(11028, http.StatusNotFound, "Session doesn't exist or is already revoked")
+	errRefreshTokenMissing           = RegisterError(11029, http.StatusUnprocessableEntity, "Refresh token must be provided")
+	errRefreshTokenInvalid           = RegisterError(11030, http.StatusUnauthorized, "Refresh token invalid or expired")
+	errRefreshTokenReused            = RegisterError(11031, http.StatusUnauthorized, "Refresh token already used. All sessions derived from it were revoked")
+	errOAuthProviderUnknown          = RegisterError(11032, http.StatusNotFound, "OAuth provider unknown")
+	errOAuthCodeMissing              = RegisterError(11033, http.StatusBadRequest, "Authorization code must be provided")
+	errOAuthStateInvalid             = RegisterError(11034, http.StatusBadRequest, "OAuth state invalid or expired")
+	errOAuthProviderFailed           = RegisterError(11035, http.StatusBadGateway, "OAuth provider unavailable or rejected the authorization")
+	errReasonMissing                 = RegisterError(11036, http.StatusUnprocessableEntity, "A reason must be provided")
+	errInvalidTokenExpiry            = RegisterError(11037, http.StatusUnprocessableEntity, "expires_at must be in the future and at most "+adminTokenMaxLifetime.String()+" away")
+	errInvalidAllowedIPs             = RegisterError(11038, http.StatusUnprocessableEntity, "allowed_ips must contain at least one ip address and nothing else")
+	errInvalidAuditLimit             = RegisterError(11039, http.StatusBadRequest, "limit must be an int between 1 and "+strconv.Itoa(auditMaxLimit))
+	errInvalidPermission             = RegisterError(11040, http.StatusUnprocessableEntity, "Permission must be one of banned, default, verified, team or admin")
+	errOwnPermission                 = RegisterError(11041, http.StatusForbidden, "You can't change your own permission")
+	errPermissionDenied              = RegisterError(11042, http.StatusForbidden, "Only admins can change team and admin permissions")
+	errBanned                        = RegisterError(11043, http.StatusForbidden, "Your account is banned")
+	errInvalidBanExpiry              = RegisterError(11044, http.StatusUnprocessableEntity, "expires_at must be in the future and is only allowed for bans")
+	errInvalidRegisterCode           = RegisterError(11045, http.StatusBadRequest, "Code must be valid")
+	errUserCannotBeNull              = RegisterLegacyError(11046, http.StatusUnprocessableEntity, http.StatusBadRequest, "User cannot be null")
+	errPasswordInvalid               = RegisterError(11047, http.StatusUnprocessableEntity, "Password must be between "+strconv.Itoa(passwordMinLength)+" and "+strconv.Itoa(passwordMaxLength)+" characters")
+	errCurrentPasswordInvalid        = RegisterError(11048, http.StatusForbidden, "Current password is wrong")
 )
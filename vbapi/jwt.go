@@ -0,0 +1,202 @@
+package vbapi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/vikebot/vbcore"
+	"github.com/vikebot/vbnet"
+	"go.uber.org/zap"
+	jwt "gopkg.in/dgrijalva/jwt-go.v3"
+)
+
+// Tokens have the same format as the ones vbjwt issued before, so tokens
+// keep working across the switch: HS512 signed, the key ID in the `vbskid`
+// header and the IPs allowed to use the token in the `allowed_ips` claim.
+// Issued tokens and their blacklisting are persisted through the `Store`.
+
+const (
+	jwtAudience  = "api.vikebot.com"
+	jwtKeyHeader = "vbskid"
+)
+
+// jwtClaims are the claims of all issued tokens
+type jwtClaims struct {
+	AllowedIPs []string `json:"allowed_ips"`
+	jwt.StandardClaims
+}
+
+// signingKeys holds the keys tokens are verified with. Retired keys have an
+// empty value, so tokens signed with them ask the client to refresh.
+type signingKeys struct {
+	defaultID string
+	keys      map[string][]byte
+}
+
+// newSigningKeys decodes the hex encoded keys. It fails if one of them isn't
+// valid hex.
+func newSigningKeys(defaultID string, encoded map[string]string) (*signingKeys, error) {
+	sk := &signingKeys{
+		defaultID: defaultID,
+		keys:      make(map[string][]byte, len(encoded)),
+	}
+	for id, v := range encoded {
+		key, err := hex.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("vbapi: signing key '%s' isn't valid hex: %v", id, err)
+		}
+		sk.keys[id] = key
+	}
+	return sk, nil
+}
+
+// ready reports whether new tokens can be signed
+func (sk *signingKeys) ready() bool {
+	return len(sk.keys[sk.defaultID]) > 0
+}
+
+// TokensReady reports whether the default signing key is configured, so
+// tokens can be issued
+func (s *Service) TokensReady() bool {
+	return s.keys.ready()
+}
+
+// issueToken signs a JWT for the user which is valid until `expires` and
+// only usable from `allowedIPs` ("*" allows every IP). The token is recorded
+// in the store before it's returned, so it can be listed and blacklisted.
+func (s *Service) issueToken(userID int, expires time.Time, ip string, allowedIPs []string, ctx *zap.Logger) (token string, jti string, err error) {
+	if !s.keys.ready() {
+		ctx.Error("unable to issue token. default signing key not configured",
+			zap.String("default_signing_key_id", s.keys.defaultID))
+		return "", "", errInternalServerError
+	}
+
+	issuedAt := time.Now()
+	claims := &jwtClaims{
+		AllowedIPs: allowedIPs,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    s.config.JwtIssuer,
+			Subject:   strconv.Itoa(userID),
+			Audience:  jwtAudience,
+			ExpiresAt: expires.Unix(),
+			IssuedAt:  issuedAt.Unix(),
+			Id:        vbcore.FastRandomString(32),
+		},
+	}
+
+	success := s.store.JwtAdd(claims.Id, userID, ip, issuedAt, expires, ctx)
+	if !success {
+		return "", "", errInternalServerError
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+	t.Header[jwtKeyHeader] = s.keys.defaultID
+	token, err = t.SignedString(s.keys.keys[s.keys.defaultID])
+	if err != nil {
+		ctx.Error("unable to sign token",
+			zap.Int("user_id", userID),
+			zap.String("skid", s.keys.defaultID),
+			zap.Error(err))
+		return "", "", errInternalServerError
+	}
+	return token, claims.Id, nil
+}
+
+// VerifyToken checks the token's signature, claims, origin `ip` and that it
+// isn't blacklisted. It returns the token's user together with it's current
+// permission and the token's ID.
+func (s *Service) VerifyToken(token string, ip string, ctx *zap.Logger) (userID int, permission int, jti string, err error) {
+	if len(token) == 0 {
+		return 0, 0, "", errJwtEmpty
+	}
+
+	parsed, err := jwt.ParseWithClaims(token, &jwtClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errJwtUnexpectedSigningMethod
+		}
+		kid, ok := t.Header[jwtKeyHeader].(string)
+		if !ok {
+			return nil, errJwtTooOld
+		}
+		key := s.keys.keys[kid]
+		if len(key) == 0 {
+			return nil, errJwtTooOld
+		}
+		return key, nil
+	})
+	if err != nil {
+		if e, ok := err.(*jwt.ValidationError); ok {
+			if httpErr, ok := e.Inner.(vbnet.HTTPError); ok {
+				return 0, 0, "", httpErr
+			}
+			switch {
+			case e.Errors&jwt.ValidationErrorMalformed != 0:
+				return 0, 0, "", errJwtMalformed
+			case e.Errors&jwt.ValidationErrorExpired != 0:
+				return 0, 0, "", errJwtExpired
+			case e.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+				return 0, 0, "", errJwtInvalidSignature
+			}
+		}
+		ctx.Warn("unhandled jwt parsing error. Returning unverifiable", zap.Error(err))
+		return 0, 0, "", errJwtUnverifiable
+	}
+	if !parsed.Valid {
+		return 0, 0, "", errJwtInvalid
+	}
+
+	claims := parsed.Claims.(*jwtClaims)
+	if claims.Issuer != s.config.JwtIssuer {
+		ctx.Warn("invalid issuer",
+			zap.String("issuer_got", claims.Issuer),
+			zap.String("issuer_want", s.config.JwtIssuer))
+		return 0, 0, "", errJwtInvalidIssuer
+	}
+	if claims.Audience != jwtAudience {
+		ctx.Warn("invalid audience",
+			zap.String("audience_got", claims.Audience),
+			zap.String("audience_want", jwtAudience))
+		return 0, 0, "", errJwtInvalidAudience
+	}
+
+	ipAllowed := false
+	for _, allowed := range claims.AllowedIPs {
+		if allowed == "*" || allowed == ip {
+			ipAllowed = true
+			break
+		}
+	}
+	if !ipAllowed {
+		ctx.Warn("unauthorized origin used JWT",
+			zap.String("ip", ip),
+			zap.Strings("allowed_ips", claims.AllowedIPs))
+		return 0, 0, "", errUnauthorizedRequestOrigin
+	}
+
+	userID, err = strconv.Atoi(claims.Subject)
+	if err != nil {
+		ctx.Error("invalid userID in JWT claim",
+			zap.String("subject", claims.Subject),
+			zap.Error(err))
+		return 0, 0, "", errJwtInternalServerError
+	}
+
+	blacklisted, success := s.store.JwtIsBlacklisted(claims.Id, ctx)
+	if !success {
+		return 0, 0, "", errJwtInternalServerError
+	}
+	if blacklisted {
+		ctx.Warn("request with blacklisted jwt",
+			zap.String("jti", claims.Id),
+			zap.Int("user_id", userID))
+		return 0, 0, "", errJwtBlacklisted
+	}
+
+	permission, success = s.store.UserPermission(userID, ctx)
+	if !success {
+		return 0, 0, "", errJwtInternalServerError
+	}
+	return userID, permission, claims.Id, nil
+}
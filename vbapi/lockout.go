@@ -0,0 +1,84 @@
+package vbapi
+
+import (
+	"sync"
+	"time"
+)
+
+// lockout counts failed attempts per key (e.g. an account or an IP) and
+// locks the key once `max` failures happened within `window`. Locked keys
+// stay locked for `duration`.
+type lockout struct {
+	max      int
+	window   time.Duration
+	duration time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*lockoutEntry
+}
+
+type lockoutEntry struct {
+	failures    int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+func newLockout(max int, window, duration time.Duration) *lockout {
+	return &lockout{
+		max:      max,
+		window:   window,
+		duration: duration,
+		entries:  make(map[string]*lockoutEntry),
+	}
+}
+
+// locked reports whether `key` is currently locked and for how long.
+func (l *lockout) locked(key string) (remaining time.Duration, locked bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return 0, false
+	}
+	remaining = time.Until(e.lockedUntil)
+	return remaining, remaining > 0
+}
+
+// fail records a failed attempt for `key` and locks it if it exceeded the
+// allowed amount of failures.
+func (l *lockout) fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.gc(now)
+
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.first) > l.window {
+		e = &lockoutEntry{first: now}
+		l.entries[key] = e
+	}
+	e.failures++
+	if e.failures >= l.max {
+		e.lockedUntil = now.Add(l.duration)
+	}
+}
+
+// reset forgets all failures recorded for `key`.
+func (l *lockout) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+}
+
+// gc removes all entries which neither count failures in their window nor
+// are locked any more. Must be called with `l.mu` held.
+func (l *lockout) gc(now time.Time) {
+	for k, e := range l.entries {
+		if now.Sub(e.first) > l.window && now.After(e.lockedUntil) {
+			delete(l.entries, k)
+		}
+	}
+}
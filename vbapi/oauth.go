@@ -0,0 +1,351 @@
+package vbapi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/vikebot/vbcore"
+	"go.uber.org/zap"
+)
+
+const (
+	// oauthStateLifetime defines how long users have to authorize vikebot at
+	// the provider after starting the flow
+	oauthStateLifetime = 10 * time.Minute
+	oauthHTTPTimeout   = 10 * time.Second
+)
+
+const (
+	// OAuthStatusLogin means the provider account is linked to a registered
+	// user who is now logged in
+	OAuthStatusLogin = "login"
+	// OAuthStatusRegistrationPending means the provider account belongs to a
+	// user who still has to finish the registration using `RegisterConfirm`
+	OAuthStatusRegistrationPending = "registration_pending"
+)
+
+// OAuthProviderConfig configures a single OAuth provider. Empty endpoint URLs
+// default to the provider's public ones, so only tests pointing vbrest to a
+// local fake provider need to set them.
+type OAuthProviderConfig struct {
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback registered at the provider. It is
+	// expected to forward the `code` and `state` query parameters to
+	// `/v1/oauth/<provider>/callback`.
+	RedirectURL string
+	AuthURL     string
+	TokenURL    string
+	UserURL     string
+	Scopes      []string
+}
+
+var oauthDefaults = map[string]OAuthProviderConfig{
+	vbcore.OAuthProviderGithub: {
+		AuthURL:  "https://github.com/login/oauth/authorize",
+		TokenURL: "https://github.com/login/oauth/access_token",
+		UserURL:  "https://api.github.com/user",
+		Scopes:   []string{"read:user", "user:email"},
+	},
+	vbcore.OAuthProviderGoogle: {
+		AuthURL:  "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL: "https://oauth2.googleapis.com/token",
+		UserURL:  "https://openidconnect.googleapis.com/v1/userinfo",
+		Scopes:   []string{"openid", "profile", "email"},
+	},
+}
+
+// newOAuthProviders fills all unset fields of the configured providers with
+// their defaults. Providers without a client ID are disabled.
+func newOAuthProviders(configs map[string]OAuthProviderConfig) (map[string]OAuthProviderConfig, error) {
+	providers := make(map[string]OAuthProviderConfig)
+	for name, c := range configs {
+		if len(c.ClientID) == 0 {
+			continue
+		}
+		def, ok := oauthDefaults[name]
+		if !ok {
+			return nil, fmt.Errorf("vbapi: unsupported oauth provider '%s'", name)
+		}
+		if len(c.RedirectURL) == 0 {
+			return nil, fmt.Errorf("vbapi: oauth provider '%s' has no redirect url", name)
+		}
+		if len(c.AuthURL) == 0 {
+			c.AuthURL = def.AuthURL
+		}
+		if len(c.TokenURL) == 0 {
+			c.TokenURL = def.TokenURL
+		}
+		if len(c.UserURL) == 0 {
+			c.UserURL = def.UserURL
+		}
+		if len(c.Scopes) == 0 {
+			c.Scopes = def.Scopes
+		}
+		providers[name] = c
+	}
+	return providers, nil
+}
+
+// OAuthState is the state of a started OAuth flow, kept in the `Store` until
+// it's callback arrives or it expires. The PKCE verifier never leaves the
+// server.
+type OAuthState struct {
+	State     string
+	Provider  string
+	Verifier  string
+	ExpiresAt time.Time
+}
+
+// OAuthStartResponse tells the client where to send the user to authorize
+// vikebot. `State` must be passed back to the callback unchanged.
+type OAuthStartResponse struct {
+	RedirectURL string `json:"redirect_url"`
+	State       string `json:"state"`
+}
+
+// OAuthResponse is returned after a successful OAuth callback. Depending on
+// `Status` it either contains the tokens of the logged in user or the
+// registration code needed to finish the registration.
+type OAuthResponse struct {
+	Status string `json:"status"`
+	*TokenResponse
+	RegisterCode string `json:"register_code,omitempty"`
+}
+
+func (s *Service) oauthProvider(provider string) (OAuthProviderConfig, error) {
+	p, ok := s.oauthProviders[provider]
+	if !ok {
+		return OAuthProviderConfig{}, errOAuthProviderUnknown
+	}
+	return p, nil
+}
+
+// OAuthStart begins the authorization-code flow with PKCE for `provider`.
+func (s *Service) OAuthStart(provider string, ctx *zap.Logger) (*OAuthStartResponse, error) {
+	p, err := s.oauthProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := vbcore.CryptoGenString(32)
+	if err != nil {
+		ctx.Error("unable to generate oauth state", zap.Error(err))
+		return nil, errInternalServerError
+	}
+	verifier, err := vbcore.CryptoGenString(64)
+	if err != nil {
+		ctx.Error("unable to generate pkce verifier", zap.Error(err))
+		return nil, errInternalServerError
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	success := s.store.OAuthStateAdd(OAuthState{
+		State:     state,
+		Provider:  provider,
+		Verifier:  verifier,
+		ExpiresAt: time.Now().Add(oauthStateLifetime),
+	}, ctx)
+	if !success {
+		return nil, errInternalServerError
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		sep = "&"
+	}
+	return &OAuthStartResponse{
+		RedirectURL: p.AuthURL + sep + q.Encode(),
+		State:       state,
+	}, nil
+}
+
+// OAuthCallback finishes the flow started by `OAuthStart`. `boundState` is
+// the state stored in the client (e.g. a cookie) when the flow was started
+// and must equal `state`, so a flow can't be finished in another browser
+// than the one it was started in. The authorization code is then exchanged
+// for the user's provider profile and ether the linked user is logged in or
+// a pending registration is created.
+func (s *Service) OAuthCallback(provider, code, state, boundState, ip string, ctx *zap.Logger) (*OAuthResponse, error) {
+	p, err := s.oauthProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) == 0 {
+		return nil, errOAuthCodeMissing
+	}
+
+	if len(state) == 0 || !vbcore.CryptoCmpStr(state, boundState) {
+		return nil, errOAuthStateInvalid
+	}
+	entry, success := s.store.OAuthStateConsume(state, ctx)
+	if !success {
+		return nil, errInternalServerError
+	}
+	if entry == nil || entry.Provider != provider || time.Now().After(entry.ExpiresAt) {
+		return nil, errOAuthStateInvalid
+	}
+
+	accessToken, err := oauthExchange(p, code, entry.Verifier)
+	if err != nil {
+		ctx.Warn("oauth code exchange failed", zap.String("provider", provider), zap.Error(err))
+		return nil, errOAuthProviderFailed
+	}
+
+	var providerID string
+	var register func() (int, string, bool)
+	switch provider {
+	case vbcore.OAuthProviderGithub:
+		var u github.User
+		err = oauthUser(p, accessToken, &u)
+		if err == nil && (u.ID == nil || u.Login == nil || u.HTMLURL == nil) {
+			err = fmt.Errorf("incomplete github profile")
+		}
+		if err != nil {
+			break
+		}
+		providerID = strconv.FormatInt(*u.ID, 10)
+		register = func() (int, string, bool) { return s.store.RegisterOAuthGithub(&u, ctx) }
+	case vbcore.OAuthProviderGoogle:
+		var u vbcore.GoogleUser
+		err = oauthUser(p, accessToken, &u)
+		if err == nil && len(u.Sub) == 0 {
+			err = fmt.Errorf("incomplete google profile")
+		}
+		if err != nil {
+			break
+		}
+		providerID = u.Sub
+		register = func() (int, string, bool) { return s.store.RegisterOAuthGoogle(&u, ctx) }
+	}
+	if err != nil {
+		ctx.Warn("oauth profile request failed", zap.String("provider", provider), zap.Error(err))
+		return nil, errOAuthProviderFailed
+	}
+
+	userID, exists, success := s.store.OAuthExists(providerID, provider, ctx)
+	if !success {
+		return nil, errInternalServerError
+	}
+
+	// Unknown provider account -> create a new user whose registration must
+	// be finished through `RegisterConfirm`
+	if !exists {
+		userID, regcode, success := register()
+		if !success {
+			return nil, errInternalServerError
+		}
+		ctx.Info("oauth registration created", zap.Int("user_id", userID), zap.String("provider", provider))
+		return &OAuthResponse{
+			Status:       OAuthStatusRegistrationPending,
+			RegisterCode: regcode,
+		}, nil
+	}
+
+	regcode, finished, success := s.store.RegcodeFromUserID(userID, ctx)
+	if !success {
+		return nil, errInternalServerError
+	}
+	if !finished {
+		return &OAuthResponse{
+			Status:       OAuthStatusRegistrationPending,
+			RegisterCode: regcode,
+		}, nil
+	}
+
+	resp, err := s.issueTokens(userID, "", ip, ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Info("oauth login succeeded", zap.Int("user_id", userID), zap.String("provider", provider))
+	return &OAuthResponse{
+		Status:        OAuthStatusLogin,
+		TokenResponse: resp,
+	}, nil
+}
+
+var oauthClient = &http.Client{Timeout: oauthHTTPTimeout}
+
+// oauthExchange redeems the authorization code at the provider's token
+// endpoint and returns the access token.
+func oauthExchange(p OAuthProviderConfig, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequest("POST", p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub responds form encoded otherwise
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = oauthDo(req, &token)
+	if err != nil {
+		return "", err
+	}
+	if len(token.Error) > 0 {
+		return "", fmt.Errorf("%s: %s", token.Error, token.ErrorDescription)
+	}
+	if len(token.AccessToken) == 0 {
+		return "", fmt.Errorf("no access token in response")
+	}
+	return token.AccessToken, nil
+}
+
+// oauthUser loads the profile of the user owning `accessToken` into `v`
+func oauthUser(p OAuthProviderConfig, accessToken string, v interface{}) error {
+	req, err := http.NewRequest("GET", p.UserURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return oauthDo(req, v)
+}
+
+func oauthDo(req *http.Request, v interface{}) error {
+	// GitHub rejects API requests without user agent
+	req.Header.Set("User-Agent", "vikebot-vbrest")
+
+	resp, err := oauthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Host)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -2,7 +2,6 @@ package vbapi
 
 import (
 	"fmt"
-	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
@@ -10,9 +9,8 @@ import (
 
 	"github.com/dpapathanasiou/go-recaptcha"
 	"github.com/vikebot/vbcore"
-	"github.com/vikebot/vbdb"
-	"github.com/vikebot/vbnet"
 	"github.com/vikebot/vbrest/vbmail"
+	"github.com/vikebot/vbrest/vbtrace"
 	"go.uber.org/zap"
 )
 
@@ -25,52 +23,61 @@ type RegisterConfirmRequest struct {
 	User         *vbcore.User `json:"user"`
 	Verification *string      `json:"verification"`
 	Recaptcha    *string      `json:"recaptcha"`
+	// Password is optional and lets the user log in with their username in
+	// addition to the OAuth provider they registered with
+	Password *string `json:"password"`
 }
 
 // RegisterConfirm registers the user specified by the user object
-func RegisterConfirm(data RegisterConfirmRequest, ip string, ctx *zap.Logger) error {
+func (s *Service) RegisterConfirm(data RegisterConfirmRequest, ip string, ctx *zap.Logger) error {
 	// Check recaptcha
 	if data.Recaptcha == nil {
-		return vbnet.NewHTTPError("Recaptcha not ticked", http.StatusBadRequest, codeRecaptchaNotTicked, nil)
+		return errRecaptchaNotTicked
 	}
+	span := vbtrace.Start(ctx, "recaptcha confirm", vbtrace.KindClient)
 	hasTicked, err := recaptcha.Confirm(ip, *data.Recaptcha)
+	span.SetError(err)
+	span.End()
 	if err != nil {
 		return errInternalServerError
 	}
 	if !hasTicked {
-		return vbnet.NewHTTPError("Recaptcha not ticked", http.StatusBadRequest, codeRecaptchaNotTicked, nil)
+		return errRecaptchaNotTicked
 	}
 
 	// Check registration code syntax
 	if data.Code == nil || !registercodeValidator.MatchString(*data.Code) {
-		return vbnet.NewHTTPError("Code must be valid", http.StatusBadRequest, codeInvalidRegisterCode, nil)
+		return errInvalidRegisterCode
 	}
 
 	// Verify that user is provided
 	if data.User == nil {
-		return vbnet.NewHTTPError("User cannot be null", http.StatusBadRequest, codeUserCannotBeNull, nil)
+		return errUserCannotBeNull
+	}
+	if data.Password != nil && !validPassword(*data.Password) {
+		return errPasswordInvalid
 	}
 
 	// Validate the user provided object
-	user, valid := data.User.Validate()
-	if !valid {
-		return vbnet.NewHTTPError("User state is invalid", http.StatusBadRequest, codeBadUserState, nil)
+	user, fields := validateUser(data.User)
+	if user == nil {
+		return NewValidationError(errBadUserState, fields)
 	}
 
 	// Load id of originial user from provided reg code
-	userID, finished, success := vbdb.UserIDFromRegcodeCtx(*data.Code, ctx)
+	userID, finished, success := s.store.UserIDFromRegcode(*data.Code, ctx)
 	if !success {
 		return errInternalServerError
 	}
 	if finished {
-		return vbnet.NewHTTPError("You already finished registration", http.StatusBadRequest, codeAlreadyFinishedRegistration, nil)
+		return errAlreadyFinishedRegistration
 	}
 	if userID == 0 {
-		return vbnet.NewHTTPError("Registration code unknown", http.StatusBadRequest, codeRegistrationCodeUnknown, nil)
+		return errRegistrationCodeUnknown
 	}
 
 	// Load olduser with id
-	oldUser, success := vbdb.UserFromIDCtx(userID, ctx)
+	oldUser, success := s.store.UserFromID(userID, ctx)
 	if !success {
 		return errInternalServerError
 	}
@@ -80,35 +87,35 @@ func RegisterConfirm(data RegisterConfirmRequest, ip string, ctx *zap.Logger) er
 	var selectedPrimary vbcore.Email
 	for idx, ne := range user.Emails {
 		if ne.Email != oldUser.Emails[idx].Email {
-			return vbnet.NewHTTPError("Email addresses manipulated", http.StatusBadRequest, codeEmailAddressManipulated, nil)
+			return errEmailAddressManipulated
 		}
 		if oldUser.Emails[idx].Status == 0 && ne.Status == 1 {
-			return vbnet.NewHTTPError("Email address status manipulated", http.StatusBadRequest, codeEmailStatusManipulated, nil)
+			return errEmailStatusManipulated
 		}
 		if ne.Status == vbcore.EmailPrimary {
 			if !selected {
 				selected = true
 				selectedPrimary = ne
 			} else {
-				return vbnet.NewHTTPError("Cannot use multiple primary addresses", http.StatusBadRequest, codeCannotUseMultiplePrimaryEmail, nil)
+				return errCannotUseMultiplePrimaryEmail
 			}
 		}
 	}
 
 	// Check if primary was selected
 	if !selected {
-		return vbnet.NewHTTPError("Must have primary email. Request manipulated", http.StatusBadRequest, codeMustHavePrimaryEmail, nil)
+		return errMustHavePrimaryEmail
 	}
 
 	// If user has selected a primary address and it's verified already
 	if selectedPrimary.Status == vbcore.EmailVerified {
-		success = vbdb.UpdateUserEmailStatusCtx(userID, selectedPrimary.Email, vbcore.EmailPrimary, ctx)
+		success = s.store.UpdateUserEmailStatus(userID, selectedPrimary.Email, vbcore.EmailPrimary, ctx)
 		if !success {
 			return errInternalServerError
 		}
 	} else {
 		if data.Verification == nil {
-			last, valid, success := vbdb.UserEmailVerificationLoadCtx(userID, selectedPrimary.Email, ctx)
+			last, valid, success := s.store.UserEmailVerificationLoad(userID, selectedPrimary.Email, ctx)
 			if !success {
 				return errInternalServerError
 			}
@@ -116,7 +123,7 @@ func RegisterConfirm(data RegisterConfirmRequest, ip string, ctx *zap.Logger) er
 			if valid {
 				sec := time.Now().UTC().Sub(*last).Seconds()
 				if sec < 60*5 {
-					return vbnet.NewHTTPError("Unable to send verification email. Quota for user exhausted. Try again in "+strconv.Itoa(int(sec))+" seconds.", http.StatusBadRequest, codeEmailQuotaExhausted, nil)
+					return withMessage(errEmailQuotaExhausted, errEmailQuotaExhausted.Message()+" Try again in "+strconv.Itoa(int(sec))+" seconds.")
 				}
 			}
 
@@ -127,7 +134,7 @@ func RegisterConfirm(data RegisterConfirmRequest, ip string, ctx *zap.Logger) er
 			}
 			verificationCode = strings.ToLower(verificationCode[:4] + " " + verificationCode[4:])
 
-			success = vbdb.UserEmailVerificationSetCtx(userID, selectedPrimary.Email, verificationCode, ctx)
+			success = s.store.UserEmailVerificationSet(userID, selectedPrimary.Email, verificationCode, ctx)
 			if !success {
 				return errInternalServerError
 			}
@@ -135,7 +142,10 @@ func RegisterConfirm(data RegisterConfirmRequest, ip string, ctx *zap.Logger) er
 			plainText := fmt.Sprintf("Dear %s,\nIn order to verify this email address with Vikebot (https://vikebot.com) use the verification code: %s\nYour Vikebot Team!\n\n\nIf you didn't register with us, you can ignore this email.", user.Name, verificationCode)
 			htmlText := fmt.Sprintf("<h3>Dear %s,</h3><p>In order to verify this email address with Vikebot (https://vikebot.com) use the verification code: <strong>%s</strong></p><p>Your Vikebot Team</p><br><br><p>If you didn't register with us, you can ignore this email.</p>", user.Name, verificationCode)
 
+			span := vbtrace.Start(ctx, "sendgrid send", vbtrace.KindClient)
 			err = vbmail.SendTo("[Action Required] Verify your Email with Vikebot", user.Name, selectedPrimary.Email, plainText, htmlText)
+			span.SetError(err)
+			span.End()
 			if err != nil {
 				ctx.Error("Unable to send email",
 					zap.Error(err),
@@ -143,17 +153,17 @@ func RegisterConfirm(data RegisterConfirmRequest, ip string, ctx *zap.Logger) er
 				return errInternalServerError
 			}
 
-			return vbnet.NewHTTPError("Please enter the code sent to your primary email address in the verification box.", http.StatusExpectationFailed, codeRegisterVerificationEntry, nil)
+			return errRegisterVerificationEntry
 		} else {
-			verified, success := vbdb.UserEmailVerificationIsCtx(userID, selectedPrimary.Email, *data.Verification, ctx)
+			verified, success := s.store.UserEmailVerificationIs(userID, selectedPrimary.Email, *data.Verification, ctx)
 			if !success {
 				return errInternalServerError
 			}
 			if !verified {
-				return vbnet.NewHTTPError("Invalid verification code", http.StatusBadRequest, codeInvalidEmailVerificationCode, nil)
+				return errInvalidEmailVerificationCode
 			}
 
-			success = vbdb.UpdateUserEmailStatusCtx(userID, selectedPrimary.Email, vbcore.EmailPrimary, ctx)
+			success = s.store.UpdateUserEmailStatus(userID, selectedPrimary.Email, vbcore.EmailPrimary, ctx)
 			if !success {
 				return errInternalServerError
 			}
@@ -170,10 +180,10 @@ func RegisterConfirm(data RegisterConfirmRequest, ip string, ctx *zap.Logger) er
 			}
 		}
 		if !found {
-			return vbnet.NewHTTPError("Invalid web link. Manipulated", http.StatusBadRequest, codeManipulatedWebLink, nil)
+			return errManipulatedWebLink
 		}
 	}
-	success = vbdb.UserDeleteWebExpectCtx(userID, user.Web, ctx)
+	success = s.store.UserDeleteWebExpect(userID, user.Web, ctx)
 	if !success {
 		return errInternalServerError
 	}
@@ -182,23 +192,30 @@ func RegisterConfirm(data RegisterConfirmRequest, ip string, ctx *zap.Logger) er
 	socialKeys := []string{}
 	for k, v := range user.Social {
 		if link, ok := oldUser.Social[k]; !ok || link != v {
-			return vbnet.NewHTTPError("Invalid social platform. Request manipulated", http.StatusBadRequest, codeInvalidSocialPlatfrom, nil)
+			return errInvalidSocialPlatfrom
 		}
 		socialKeys = append(socialKeys, k)
 	}
-	success = vbdb.UserDeleteSocialExpectCtx(userID, socialKeys, ctx)
+	success = s.store.UserDeleteSocialExpect(userID, socialKeys, ctx)
 	if !success {
 		return errInternalServerError
 	}
 
 	// Update auto prop fields
-	success = vbdb.UpdateUserCtx(user.User(), oldUser, "register_autoPropUpdate", ctx)
+	success = s.store.UpdateUser(user.User(), oldUser, "register_autoPropUpdate", ctx)
 	if !success {
 		return errInternalServerError
 	}
 
+	if data.Password != nil {
+		err = s.setPassword(userID, *data.Password, "register_password", ctx)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Set registration to done
-	success = vbdb.UserSetRegistrationDoneCtx(userID, ctx)
+	success = s.store.UserSetRegistrationDone(userID, ctx)
 	if !success {
 		return errInternalServerError
 	}
@@ -2,13 +2,12 @@ package vbapi
 
 import (
 	"github.com/vikebot/vbcore"
-	"github.com/vikebot/vbdb"
 	"go.uber.org/zap"
 )
 
 // RoundentryActive loads all roundentry infos for a given user
-func RoundentryActive(userID int, ctx *zap.Logger) (response []vbcore.Roundentry, err error) {
-	roundentries, success := vbdb.ActiveRoundentriesCtx(userID, ctx)
+func (s *Service) RoundentryActive(userID int, ctx *zap.Logger) (response []vbcore.Roundentry, err error) {
+	roundentries, success := s.store.ActiveRoundentries(userID, ctx)
 	if !success {
 		return nil, errInternalServerError
 	}
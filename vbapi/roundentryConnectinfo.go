@@ -1,12 +1,9 @@
 package vbapi
 
 import (
-	"net/http"
 	"regexp"
 
 	"github.com/vikebot/vbcore"
-	"github.com/vikebot/vbdb"
-	"github.com/vikebot/vbnet"
 	"go.uber.org/zap"
 )
 
@@ -16,17 +13,17 @@ var (
 
 // RoundentryConnectinfo trades an authtoken for a
 // `vbcore.RoundentryConnectinfo`
-func RoundentryConnectinfo(authtoken string, ctx *zap.Logger) (response *vbcore.RoundentryConnectinfo, err error) {
+func (s *Service) RoundentryConnectinfo(authtoken string, ctx *zap.Logger) (response *vbcore.RoundentryConnectinfo, err error) {
 	if !authtokenValidator.MatchString(authtoken) {
-		return nil, vbnet.NewHTTPError("Invalid authtoken format", http.StatusBadRequest, codeInvalidAuthtokenFormat, nil)
+		return nil, errInvalidAuthtokenFormat
 	}
 
-	connectinfo, exists, success := vbdb.RoundentryConnectinfoCtx(authtoken, ctx)
+	connectinfo, exists, success := s.store.RoundentryConnectinfo(authtoken, ctx)
 	if !success {
 		return nil, errInternalServerError
 	}
 	if !exists {
-		return nil, vbnet.NewHTTPError("Unknown authtoken", http.StatusOK, codeUnknownAuthtoken, nil)
+		return nil, errUnknownAuthtoken
 	}
 
 	return connectinfo, nil
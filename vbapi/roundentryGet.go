@@ -0,0 +1,28 @@
+package vbapi
+
+import (
+	"strconv"
+
+	"github.com/vikebot/vbcore"
+	"go.uber.org/zap"
+)
+
+// RoundentryGet loads the user's roundentry of the specified round. Only
+// entries of active rounds are found.
+func (s *Service) RoundentryGet(userID int, roundID string, ctx *zap.Logger) (*vbcore.Roundentry, error) {
+	round, err := strconv.Atoi(roundID)
+	if err != nil || round < 1 {
+		return nil, errInvalidRoundIDFormat
+	}
+
+	roundentries, success := s.store.ActiveRoundentries(userID, ctx)
+	if !success {
+		return nil, errInternalServerError
+	}
+	for i := range roundentries {
+		if roundentries[i].ID == round {
+			return &roundentries[i], nil
+		}
+	}
+	return nil, errRoundNotExists
+}
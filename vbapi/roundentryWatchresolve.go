@@ -1,11 +1,8 @@
 package vbapi
 
 import (
-	"net/http"
 	"regexp"
 
-	"github.com/vikebot/vbdb"
-	"github.com/vikebot/vbnet"
 	"go.uber.org/zap"
 )
 
@@ -16,17 +13,17 @@ var (
 // RoundentryWatchresolve resolves a watchtoken to it's associated websocket
 // address, so clients only knowing the watchtoken can find their gameserver
 // information publisher
-func RoundentryWatchresolve(watchtoken string, ctx *zap.Logger) (websocket string, err error) {
+func (s *Service) RoundentryWatchresolve(watchtoken string, ctx *zap.Logger) (websocket string, err error) {
 	if !watchtokenValidator.MatchString(watchtoken) {
-		return "", vbnet.NewHTTPError("Invalid watchtoken format", http.StatusBadRequest, codeInvalidWatchtokenFormat, nil)
+		return "", errInvalidWatchtokenFormat
 	}
 
-	websocket, exists, success := vbdb.WebsocketAddressFromWatchtokenCtx(watchtoken, ctx)
+	websocket, exists, success := s.store.WebsocketAddressFromWatchtoken(watchtoken, ctx)
 	if !success {
 		return "", errInternalServerError
 	}
 	if !exists {
-		return "", vbnet.NewHTTPError("Unknown watchtoken.", http.StatusForbidden, codeUnknownWatchtoken, nil)
+		return "", errUnknownWatchtoken
 	}
 
 	return websocket, nil
@@ -0,0 +1,87 @@
+package vbapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/dpapathanasiou/go-recaptcha"
+)
+
+// Config collects all settings of a `Service`
+type Config struct {
+	// RecaptchaSecret is used to verify recaptcha responses during
+	// registration
+	RecaptchaSecret string
+	// JwtIssuer is set as issuer of all tokens. Tokens of other issuers are
+	// rejected.
+	JwtIssuer string
+	// SigningKeys maps key IDs to hex encoded HS512 keys. Tokens signed with
+	// any of them are accepted. Retired keys are kept with an empty value.
+	SigningKeys map[string]string
+	// DefaultSigningKeyID is the key all new tokens are signed with
+	DefaultSigningKeyID string
+	// AccessTokenLifetime defines how long issued JWTs are valid
+	AccessTokenLifetime time.Duration
+	// RefreshTokenLifetime defines how long a refresh token can be used to
+	// get a new JWT
+	RefreshTokenLifetime time.Duration
+	// OAuth maps the names of OAuth providers (`vbcore.OAuthProvider*`) to
+	// their config
+	OAuth map[string]OAuthProviderConfig
+	// LoginLockoutDuration defines how long accounts and IPs with too many
+	// failed logins are locked out. Defaults to 15 minutes.
+	LoginLockoutDuration time.Duration
+}
+
+// Service bundles all vbapi operations together with the `Store` they
+// operate on.
+type Service struct {
+	store  Store
+	config Config
+	keys   *signingKeys
+
+	accountLockout *lockout
+	ipLockout      *lockout
+
+	oauthProviders map[string]OAuthProviderConfig
+}
+
+// NewService creates a new `Service` which uses `store` for all data
+// operations. It fails if the config is invalid.
+func NewService(store Store, config Config) (*Service, error) {
+	oauthProviders, err := newOAuthProviders(config.OAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := newSigningKeys(config.DefaultSigningKeyID, config.SigningKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	recaptcha.Init(config.RecaptchaSecret)
+
+	if config.LoginLockoutDuration <= 0 {
+		config.LoginLockoutDuration = loginLockoutDuration
+	}
+
+	return &Service{
+		store:          store,
+		config:         config,
+		keys:           keys,
+		accountLockout: newLockout(loginMaxAccountFailures, loginFailureWindow, config.LoginLockoutDuration),
+		ipLockout:      newLockout(loginMaxIPFailures, loginFailureWindow, config.LoginLockoutDuration),
+		oauthProviders: oauthProviders,
+	}, nil
+}
+
+// Ping checks that the service's store is reachable
+func (s *Service) Ping(ctx context.Context) error {
+	return s.store.Ping(ctx)
+}
+
+// Close releases the service's store. No other method may be called
+// afterwards.
+func (s *Service) Close() error {
+	return s.store.Close()
+}
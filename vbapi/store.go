@@ -0,0 +1,145 @@
+package vbapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/vikebot/vbcore"
+	"go.uber.org/zap"
+)
+
+// Store abstracts every data operation vbapi relies on. The semantics of all
+// methods follow their `vbdb.*Ctx` equivalents: `success` is only false if
+// the backend itself failed, a missing entity is reported through the other
+// return values.
+type Store interface {
+	// UserFromID loads the user with the given ID. `user` is nil if no such
+	// user exists.
+	UserFromID(userID int, ctx *zap.Logger) (user *vbcore.SafeUser, success bool)
+	// UserFromUsername loads the user currently using `username`. `user` is
+	// nil if no such user exists.
+	UserFromUsername(username string, ctx *zap.Logger) (user *vbcore.SafeUser, success bool)
+	// UpdateUser persists all differences between `newUser` and `oldUser`
+	// and records `msg` as reason for the change.
+	UpdateUser(newUser *vbcore.User, oldUser *vbcore.SafeUser, msg string, ctx *zap.Logger) (success bool)
+	// UserIDFromRegcode exchanges a registration code for the ID of the user
+	// it was issued to. `userID` is 0 if the code is unknown.
+	UserIDFromRegcode(code string, ctx *zap.Logger) (userID int, finished bool, success bool)
+	// UpdateUserEmailStatus sets the status of one of the user's emails.
+	UpdateUserEmailStatus(userID int, email string, status int, ctx *zap.Logger) (success bool)
+	// UserEmailVerificationLoad returns when the last verification code was
+	// sent to `email`. `valid` is false if none was sent yet.
+	UserEmailVerificationLoad(userID int, email string, ctx *zap.Logger) (lastSent *time.Time, valid bool, success bool)
+	// UserEmailVerificationSet stores a new verification code for `email`.
+	UserEmailVerificationSet(userID int, email string, verificationCode string, ctx *zap.Logger) (success bool)
+	// UserEmailVerificationIs checks `verificationCode` against the one
+	// stored for `email`.
+	UserEmailVerificationIs(userID int, email string, verificationCode string, ctx *zap.Logger) (verified bool, success bool)
+	// UserDeleteWebExpect deletes all web links of the user except the
+	// passed ones.
+	UserDeleteWebExpect(userID int, web []string, ctx *zap.Logger) (success bool)
+	// UserDeleteSocialExpect deletes all social links of the user except the
+	// passed platforms.
+	UserDeleteSocialExpect(userID int, social []string, ctx *zap.Logger) (success bool)
+	// UserSetRegistrationDone marks the user's registration as finished.
+	UserSetRegistrationDone(userID int, ctx *zap.Logger) (success bool)
+	// RegcodeFromUserID returns the registration code issued to the user.
+	RegcodeFromUserID(userID int, ctx *zap.Logger) (code string, finished bool, success bool)
+	// UserPermissionSet changes the user's permission and records `reason`
+	// for the change. The user's ban, if any, is lifted. If `audit` isn't nil
+	// it's added to the audit trail in the same transaction.
+	UserPermissionSet(userID int, permission int, reason string, audit *AuditEntry, ctx *zap.Logger) (success bool)
+	// UserPermission loads the user's current permission. It fails if the
+	// user doesn't exist.
+	UserPermission(userID int, ctx *zap.Logger) (permission int, success bool)
+	// UserBanSet bans the user. `ban.Reason` is recorded as reason for the
+	// permission change. If `audit` isn't nil it's added to the audit trail
+	// in the same transaction.
+	UserBanSet(userID int, ban Ban, audit *AuditEntry, ctx *zap.Logger) (success bool)
+	// UserBan loads the user's current ban. `ban` is nil if the user wasn't
+	// banned through `UserBanSet` since it's last permission change.
+	UserBan(userID int, ctx *zap.Logger) (ban *Ban, success bool)
+	// UserCredentials loads the password hash and salt (as produced by
+	// `vbcore.CryptoPwd`) of the user currently using `username`. `exists`
+	// is false if there is no such user or the user has no password set.
+	UserCredentials(username string, ctx *zap.Logger) (userID int, hash string, salt string, exists bool, success bool)
+	// UserPasswordSet replaces the user's password hash and salt (as produced
+	// by `vbcore.CryptoPwd`). `msg` describes why the password changed.
+	UserPasswordSet(userID int, hash string, salt string, msg string, ctx *zap.Logger) (success bool)
+
+	// OAuthExists looks up the user linked to the account `providerID` of
+	// `provider`.
+	OAuthExists(providerID string, provider string, ctx *zap.Logger) (userID int, exists bool, success bool)
+	// RegisterOAuthGithub creates a user with an unfinished registration from
+	// the GitHub profile and links it.
+	RegisterOAuthGithub(user *github.User, ctx *zap.Logger) (userID int, regCode string, success bool)
+	// RegisterOAuthGoogle creates a user with an unfinished registration from
+	// the Google profile and links it.
+	RegisterOAuthGoogle(user *vbcore.GoogleUser, ctx *zap.Logger) (userID int, regCode string, success bool)
+	// OAuthStateAdd stores the state of a started OAuth flow until it's
+	// callback arrives. Expired states may be removed.
+	OAuthStateAdd(state OAuthState, ctx *zap.Logger) (success bool)
+	// OAuthStateConsume loads and removes the flow's `state`, so it can only
+	// be used once. `entry` is nil if the state is unknown.
+	OAuthStateConsume(state string, ctx *zap.Logger) (entry *OAuthState, success bool)
+
+	// ActiveRounds loads all rounds which aren't finished yet.
+	ActiveRounds(ctx *zap.Logger) (rounds []vbcore.Round, success bool)
+	// RoundExists checks whether the round with the given ID exists.
+	RoundExists(roundID int, ctx *zap.Logger) (exists bool, success bool)
+	// JoinRound adds the user to the round. `alreadyJoined` indicates that
+	// the call was redundant.
+	JoinRound(userID, roundID int, ctx *zap.Logger) (alreadyJoined bool, success bool)
+
+	// ActiveRoundentries loads the user's roundentries of all rounds which
+	// aren't finished yet.
+	ActiveRoundentries(userID int, ctx *zap.Logger) (roundentries []vbcore.Roundentry, success bool)
+	// RoundentryConnectinfo returns everything needed to connect to the
+	// gameserver hosting the game associated with `authtoken`.
+	RoundentryConnectinfo(authtoken string, ctx *zap.Logger) (connectinfo *vbcore.RoundentryConnectinfo, exists bool, success bool)
+	// JwtAdd records a newly issued token as valid.
+	JwtAdd(jti string, userID int, ip string, issuedAt time.Time, expiresAt time.Time, ctx *zap.Logger) (success bool)
+	// JwtIsBlacklisted checks whether the token `jti` may no longer be used.
+	// Unknown tokens are reported as blacklisted.
+	JwtIsBlacklisted(jti string, ctx *zap.Logger) (blacklisted bool, success bool)
+	// JwtSessions loads all tokens issued for the user which are neither
+	// expired nor blacklisted.
+	JwtSessions(userID int, ctx *zap.Logger) (sessions []Session, success bool)
+	// JwtBlacklist blacklists the token `jti` of the user. `found` is false
+	// if the user has no valid token with this ID.
+	JwtBlacklist(userID int, jti string, ctx *zap.Logger) (found bool, success bool)
+	// JwtBlacklistUser blacklists all tokens of the user.
+	JwtBlacklistUser(userID int, ctx *zap.Logger) (success bool)
+
+	// RefreshTokenAdd stores a newly issued refresh token.
+	RefreshTokenAdd(token RefreshToken, ctx *zap.Logger) (success bool)
+	// RefreshTokenUse atomically marks the refresh token with the given hash
+	// as used and returns it's state from before. `token` is nil if the hash
+	// is unknown.
+	RefreshTokenUse(hash string, ctx *zap.Logger) (token *RefreshToken, success bool)
+	// RefreshTokenRevokeFamily revokes all refresh tokens of the family and
+	// returns the JTIs of all JWTs issued together with them.
+	RefreshTokenRevokeFamily(family string, ctx *zap.Logger) (accessJTIs []string, success bool)
+	// RefreshTokenFamily returns the family of the refresh token issued
+	// together with the JWT `accessJTI`.
+	RefreshTokenFamily(accessJTI string, ctx *zap.Logger) (family string, exists bool, success bool)
+	// RefreshTokenRevokeUser revokes all refresh tokens of the user.
+	RefreshTokenRevokeUser(userID int, ctx *zap.Logger) (success bool)
+
+	// AuditAdd appends the entry to the audit trail.
+	AuditAdd(entry AuditEntry, ctx *zap.Logger) (success bool)
+	// Audit loads the latest `limit` audit entries, newest first.
+	Audit(limit int, ctx *zap.Logger) (entries []AuditEntry, success bool)
+
+	// WebsocketAddressFromWatchtoken resolves a watchtoken to the websocket
+	// address of it's gameserver.
+	WebsocketAddressFromWatchtoken(watchtoken string, ctx *zap.Logger) (websocket string, exists bool, success bool)
+
+	// Ping checks that the backend is reachable. It fails if `ctx` is done
+	// before.
+	Ping(ctx context.Context) error
+	// Close releases all resources of the store. No other method may be
+	// called afterwards.
+	Close() error
+}
@@ -0,0 +1,320 @@
+package vbapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/vikebot/vbcore"
+	"github.com/vikebot/vbrest/vbtrace"
+	"go.uber.org/zap"
+)
+
+// StoreObserver is called after every operation of an `InstrumentedStore`
+// with the operation's name, duration and whether it succeeded
+type StoreObserver func(op string, d time.Duration, success bool)
+
+// InstrumentedStore wraps a `Store` and reports the duration of every
+// operation to a `StoreObserver`, e.g. to record DB timings. Operations are
+// also traced as children of the span carried by their logger.
+type InstrumentedStore struct {
+	store    Store
+	observer StoreObserver
+}
+
+var _ Store = (*InstrumentedStore)(nil)
+
+// NewInstrumentedStore returns a `Store` reporting all operations of `store`
+// to `observer`
+func NewInstrumentedStore(store Store, observer StoreObserver) *InstrumentedStore {
+	return &InstrumentedStore{
+		store:    store,
+		observer: observer,
+	}
+}
+
+// operation is a running store operation
+type operation struct {
+	name  string
+	start time.Time
+	span  *vbtrace.Span
+}
+
+// start begins an operation and traces it as child of the span carried by
+// `ctx`
+func (s *InstrumentedStore) start(op string, ctx *zap.Logger) operation {
+	span := vbtrace.Start(ctx, "db "+op, vbtrace.KindClient)
+	span.SetAttribute("db.operation", op)
+	return operation{
+		name:  op,
+		start: time.Now(),
+		span:  span,
+	}
+}
+
+func (s *InstrumentedStore) observe(op operation, success *bool) {
+	if !*success {
+		op.span.Fail("store operation failed")
+	}
+	op.span.End()
+	s.observer(op.name, time.Since(op.start), *success)
+}
+
+// UserFromID implements `Store`
+func (s *InstrumentedStore) UserFromID(userID int, ctx *zap.Logger) (user *vbcore.SafeUser, success bool) {
+	defer s.observe(s.start("UserFromID", ctx), &success)
+	return s.store.UserFromID(userID, ctx)
+}
+
+// UserFromUsername implements `Store`
+func (s *InstrumentedStore) UserFromUsername(username string, ctx *zap.Logger) (user *vbcore.SafeUser, success bool) {
+	defer s.observe(s.start("UserFromUsername", ctx), &success)
+	return s.store.UserFromUsername(username, ctx)
+}
+
+// UpdateUser implements `Store`
+func (s *InstrumentedStore) UpdateUser(newUser *vbcore.User, oldUser *vbcore.SafeUser, msg string, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("UpdateUser", ctx), &success)
+	return s.store.UpdateUser(newUser, oldUser, msg, ctx)
+}
+
+// UserIDFromRegcode implements `Store`
+func (s *InstrumentedStore) UserIDFromRegcode(code string, ctx *zap.Logger) (userID int, finished bool, success bool) {
+	defer s.observe(s.start("UserIDFromRegcode", ctx), &success)
+	return s.store.UserIDFromRegcode(code, ctx)
+}
+
+// UpdateUserEmailStatus implements `Store`
+func (s *InstrumentedStore) UpdateUserEmailStatus(userID int, email string, status int, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("UpdateUserEmailStatus", ctx), &success)
+	return s.store.UpdateUserEmailStatus(userID, email, status, ctx)
+}
+
+// UserEmailVerificationLoad implements `Store`
+func (s *InstrumentedStore) UserEmailVerificationLoad(userID int, email string, ctx *zap.Logger) (lastSent *time.Time, valid bool, success bool) {
+	defer s.observe(s.start("UserEmailVerificationLoad", ctx), &success)
+	return s.store.UserEmailVerificationLoad(userID, email, ctx)
+}
+
+// UserEmailVerificationSet implements `Store`
+func (s *InstrumentedStore) UserEmailVerificationSet(userID int, email string, verificationCode string, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("UserEmailVerificationSet", ctx), &success)
+	return s.store.UserEmailVerificationSet(userID, email, verificationCode, ctx)
+}
+
+// UserEmailVerificationIs implements `Store`
+func (s *InstrumentedStore) UserEmailVerificationIs(userID int, email string, verificationCode string, ctx *zap.Logger) (verified bool, success bool) {
+	defer s.observe(s.start("UserEmailVerificationIs", ctx), &success)
+	return s.store.UserEmailVerificationIs(userID, email, verificationCode, ctx)
+}
+
+// UserDeleteWebExpect implements `Store`
+func (s *InstrumentedStore) UserDeleteWebExpect(userID int, web []string, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("UserDeleteWebExpect", ctx), &success)
+	return s.store.UserDeleteWebExpect(userID, web, ctx)
+}
+
+// UserDeleteSocialExpect implements `Store`
+func (s *InstrumentedStore) UserDeleteSocialExpect(userID int, social []string, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("UserDeleteSocialExpect", ctx), &success)
+	return s.store.UserDeleteSocialExpect(userID, social, ctx)
+}
+
+// UserSetRegistrationDone implements `Store`
+func (s *InstrumentedStore) UserSetRegistrationDone(userID int, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("UserSetRegistrationDone", ctx), &success)
+	return s.store.UserSetRegistrationDone(userID, ctx)
+}
+
+// RegcodeFromUserID implements `Store`
+func (s *InstrumentedStore) RegcodeFromUserID(userID int, ctx *zap.Logger) (code string, finished bool, success bool) {
+	defer s.observe(s.start("RegcodeFromUserID", ctx), &success)
+	return s.store.RegcodeFromUserID(userID, ctx)
+}
+
+// UserPermissionSet implements `Store`
+func (s *InstrumentedStore) UserPermissionSet(userID int, permission int, reason string, audit *AuditEntry, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("UserPermissionSet", ctx), &success)
+	return s.store.UserPermissionSet(userID, permission, reason, audit, ctx)
+}
+
+// UserPermission implements `Store`
+func (s *InstrumentedStore) UserPermission(userID int, ctx *zap.Logger) (permission int, success bool) {
+	defer s.observe(s.start("UserPermission", ctx), &success)
+	return s.store.UserPermission(userID, ctx)
+}
+
+// UserBanSet implements `Store`
+func (s *InstrumentedStore) UserBanSet(userID int, ban Ban, audit *AuditEntry, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("UserBanSet", ctx), &success)
+	return s.store.UserBanSet(userID, ban, audit, ctx)
+}
+
+// UserBan implements `Store`
+func (s *InstrumentedStore) UserBan(userID int, ctx *zap.Logger) (ban *Ban, success bool) {
+	defer s.observe(s.start("UserBan", ctx), &success)
+	return s.store.UserBan(userID, ctx)
+}
+
+// UserCredentials implements `Store`
+func (s *InstrumentedStore) UserCredentials(username string, ctx *zap.Logger) (userID int, hash string, salt string, exists bool, success bool) {
+	defer s.observe(s.start("UserCredentials", ctx), &success)
+	return s.store.UserCredentials(username, ctx)
+}
+
+// UserPasswordSet implements `Store`
+func (s *InstrumentedStore) UserPasswordSet(userID int, hash string, salt string, msg string, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("UserPasswordSet", ctx), &success)
+	return s.store.UserPasswordSet(userID, hash, salt, msg, ctx)
+}
+
+// OAuthExists implements `Store`
+func (s *InstrumentedStore) OAuthExists(providerID string, provider string, ctx *zap.Logger) (userID int, exists bool, success bool) {
+	defer s.observe(s.start("OAuthExists", ctx), &success)
+	return s.store.OAuthExists(providerID, provider, ctx)
+}
+
+// RegisterOAuthGithub implements `Store`
+func (s *InstrumentedStore) RegisterOAuthGithub(user *github.User, ctx *zap.Logger) (userID int, regCode string, success bool) {
+	defer s.observe(s.start("RegisterOAuthGithub", ctx), &success)
+	return s.store.RegisterOAuthGithub(user, ctx)
+}
+
+// RegisterOAuthGoogle implements `Store`
+func (s *InstrumentedStore) RegisterOAuthGoogle(user *vbcore.GoogleUser, ctx *zap.Logger) (userID int, regCode string, success bool) {
+	defer s.observe(s.start("RegisterOAuthGoogle", ctx), &success)
+	return s.store.RegisterOAuthGoogle(user, ctx)
+}
+
+// OAuthStateAdd implements `Store`
+func (s *InstrumentedStore) OAuthStateAdd(state OAuthState, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("OAuthStateAdd", ctx), &success)
+	return s.store.OAuthStateAdd(state, ctx)
+}
+
+// OAuthStateConsume implements `Store`
+func (s *InstrumentedStore) OAuthStateConsume(state string, ctx *zap.Logger) (entry *OAuthState, success bool) {
+	defer s.observe(s.start("OAuthStateConsume", ctx), &success)
+	return s.store.OAuthStateConsume(state, ctx)
+}
+
+// ActiveRounds implements `Store`
+func (s *InstrumentedStore) ActiveRounds(ctx *zap.Logger) (rounds []vbcore.Round, success bool) {
+	defer s.observe(s.start("ActiveRounds", ctx), &success)
+	return s.store.ActiveRounds(ctx)
+}
+
+// RoundExists implements `Store`
+func (s *InstrumentedStore) RoundExists(roundID int, ctx *zap.Logger) (exists bool, success bool) {
+	defer s.observe(s.start("RoundExists", ctx), &success)
+	return s.store.RoundExists(roundID, ctx)
+}
+
+// JoinRound implements `Store`
+func (s *InstrumentedStore) JoinRound(userID, roundID int, ctx *zap.Logger) (alreadyJoined bool, success bool) {
+	defer s.observe(s.start("JoinRound", ctx), &success)
+	return s.store.JoinRound(userID, roundID, ctx)
+}
+
+// ActiveRoundentries implements `Store`
+func (s *InstrumentedStore) ActiveRoundentries(userID int, ctx *zap.Logger) (roundentries []vbcore.Roundentry, success bool) {
+	defer s.observe(s.start("ActiveRoundentries", ctx), &success)
+	return s.store.ActiveRoundentries(userID, ctx)
+}
+
+// RoundentryConnectinfo implements `Store`
+func (s *InstrumentedStore) RoundentryConnectinfo(authtoken string, ctx *zap.Logger) (connectinfo *vbcore.RoundentryConnectinfo, exists bool, success bool) {
+	defer s.observe(s.start("RoundentryConnectinfo", ctx), &success)
+	return s.store.RoundentryConnectinfo(authtoken, ctx)
+}
+
+// JwtAdd implements `Store`
+func (s *InstrumentedStore) JwtAdd(jti string, userID int, ip string, issuedAt time.Time, expiresAt time.Time, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("JwtAdd", ctx), &success)
+	return s.store.JwtAdd(jti, userID, ip, issuedAt, expiresAt, ctx)
+}
+
+// JwtIsBlacklisted implements `Store`
+func (s *InstrumentedStore) JwtIsBlacklisted(jti string, ctx *zap.Logger) (blacklisted bool, success bool) {
+	defer s.observe(s.start("JwtIsBlacklisted", ctx), &success)
+	return s.store.JwtIsBlacklisted(jti, ctx)
+}
+
+// JwtSessions implements `Store`
+func (s *InstrumentedStore) JwtSessions(userID int, ctx *zap.Logger) (sessions []Session, success bool) {
+	defer s.observe(s.start("JwtSessions", ctx), &success)
+	return s.store.JwtSessions(userID, ctx)
+}
+
+// JwtBlacklist implements `Store`
+func (s *InstrumentedStore) JwtBlacklist(userID int, jti string, ctx *zap.Logger) (found bool, success bool) {
+	defer s.observe(s.start("JwtBlacklist", ctx), &success)
+	return s.store.JwtBlacklist(userID, jti, ctx)
+}
+
+// JwtBlacklistUser implements `Store`
+func (s *InstrumentedStore) JwtBlacklistUser(userID int, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("JwtBlacklistUser", ctx), &success)
+	return s.store.JwtBlacklistUser(userID, ctx)
+}
+
+// RefreshTokenAdd implements `Store`
+func (s *InstrumentedStore) RefreshTokenAdd(token RefreshToken, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("RefreshTokenAdd", ctx), &success)
+	return s.store.RefreshTokenAdd(token, ctx)
+}
+
+// RefreshTokenUse implements `Store`
+func (s *InstrumentedStore) RefreshTokenUse(hash string, ctx *zap.Logger) (token *RefreshToken, success bool) {
+	defer s.observe(s.start("RefreshTokenUse", ctx), &success)
+	return s.store.RefreshTokenUse(hash, ctx)
+}
+
+// RefreshTokenRevokeFamily implements `Store`
+func (s *InstrumentedStore) RefreshTokenRevokeFamily(family string, ctx *zap.Logger) (accessJTIs []string, success bool) {
+	defer s.observe(s.start("RefreshTokenRevokeFamily", ctx), &success)
+	return s.store.RefreshTokenRevokeFamily(family, ctx)
+}
+
+// RefreshTokenFamily implements `Store`
+func (s *InstrumentedStore) RefreshTokenFamily(accessJTI string, ctx *zap.Logger) (family string, exists bool, success bool) {
+	defer s.observe(s.start("RefreshTokenFamily", ctx), &success)
+	return s.store.RefreshTokenFamily(accessJTI, ctx)
+}
+
+// RefreshTokenRevokeUser implements `Store`
+func (s *InstrumentedStore) RefreshTokenRevokeUser(userID int, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("RefreshTokenRevokeUser", ctx), &success)
+	return s.store.RefreshTokenRevokeUser(userID, ctx)
+}
+
+// AuditAdd implements `Store`
+func (s *InstrumentedStore) AuditAdd(entry AuditEntry, ctx *zap.Logger) (success bool) {
+	defer s.observe(s.start("AuditAdd", ctx), &success)
+	return s.store.AuditAdd(entry, ctx)
+}
+
+// Audit implements `Store`
+func (s *InstrumentedStore) Audit(limit int, ctx *zap.Logger) (entries []AuditEntry, success bool) {
+	defer s.observe(s.start("Audit", ctx), &success)
+	return s.store.Audit(limit, ctx)
+}
+
+// WebsocketAddressFromWatchtoken implements `Store`
+func (s *InstrumentedStore) WebsocketAddressFromWatchtoken(watchtoken string, ctx *zap.Logger) (websocket string, exists bool, success bool) {
+	defer s.observe(s.start("WebsocketAddressFromWatchtoken", ctx), &success)
+	return s.store.WebsocketAddressFromWatchtoken(watchtoken, ctx)
+}
+
+// Ping implements `Store`
+func (s *InstrumentedStore) Ping(ctx context.Context) (err error) {
+	start := time.Now()
+	err = s.store.Ping(ctx)
+	s.observer("Ping", time.Since(start), err == nil)
+	return err
+}
+
+// Close implements `Store`
+func (s *InstrumentedStore) Close() error {
+	return s.store.Close()
+}
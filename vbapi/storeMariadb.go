@@ -0,0 +1,679 @@
+package vbapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/vikebot/vbcore"
+	"github.com/vikebot/vbdb"
+	"go.uber.org/zap"
+)
+
+// MariaDBStore implements `Store` on top of the vikebot MariaDB database by
+// delegating to vbdb. Operations vbdb doesn't offer are executed directly on
+// a separate connection pool and rely on these additional tables, created by
+// the scripts in `migrations/`:
+//
+//	user_password (id, user_id, msg_id, hash, salt)
+//	refresh_token (id, hash, family, user_id, access_jti, iat, exp, used, revoked)
+//	audit (id, actor_id, action, target_user_id, msg_id, details, ip, time)
+//	user_ban (id, user_id, msg_id, previous, exp, lifted)
+//	oauth_state (state, provider, verifier, exp)
+type MariaDBStore struct {
+	db *sql.DB
+}
+
+var _ Store = (*MariaDBStore)(nil)
+
+// NewMariaDBStore initializes vbdb's connection pool and the store's own one
+// and returns a `Store` using them.
+func NewMariaDBStore(dbAddr, dbUser, dbPass, dbName string, ctx *zap.Logger) (*MariaDBStore, error) {
+	addr := vbcore.NewEndpointAddr(dbAddr)
+	err := vbdb.Init(&vbdb.Config{
+		DbAddr: addr,
+		DbUser: dbUser,
+		DbPass: dbPass,
+		DbName: dbName,
+	}, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The mysql driver is registered by vbdb
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&collation=utf8mb4_unicode_ci&parseTime=true", dbUser, dbPass, addr, dbName))
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	return &MariaDBStore{db: db}, nil
+}
+
+// UserFromID implements `Store`
+func (s *MariaDBStore) UserFromID(userID int, ctx *zap.Logger) (*vbcore.SafeUser, bool) {
+	return vbdb.UserFromIDCtx(userID, ctx)
+}
+
+// UserFromUsername implements `Store`
+func (s *MariaDBStore) UserFromUsername(username string, ctx *zap.Logger) (*vbcore.SafeUser, bool) {
+	return vbdb.UserFromUsernameCtx(username, ctx)
+}
+
+// UpdateUser implements `Store`
+func (s *MariaDBStore) UpdateUser(newUser *vbcore.User, oldUser *vbcore.SafeUser, msg string, ctx *zap.Logger) bool {
+	return vbdb.UpdateUserCtx(newUser, oldUser, msg, ctx)
+}
+
+// UserIDFromRegcode implements `Store`
+func (s *MariaDBStore) UserIDFromRegcode(code string, ctx *zap.Logger) (int, bool, bool) {
+	return vbdb.UserIDFromRegcodeCtx(code, ctx)
+}
+
+// UpdateUserEmailStatus implements `Store`
+func (s *MariaDBStore) UpdateUserEmailStatus(userID int, email string, status int, ctx *zap.Logger) bool {
+	return vbdb.UpdateUserEmailStatusCtx(userID, email, status, ctx)
+}
+
+// UserEmailVerificationLoad implements `Store`
+func (s *MariaDBStore) UserEmailVerificationLoad(userID int, email string, ctx *zap.Logger) (*time.Time, bool, bool) {
+	return vbdb.UserEmailVerificationLoadCtx(userID, email, ctx)
+}
+
+// UserEmailVerificationSet implements `Store`
+func (s *MariaDBStore) UserEmailVerificationSet(userID int, email string, verificationCode string, ctx *zap.Logger) bool {
+	return vbdb.UserEmailVerificationSetCtx(userID, email, verificationCode, ctx)
+}
+
+// UserEmailVerificationIs implements `Store`
+func (s *MariaDBStore) UserEmailVerificationIs(userID int, email string, verificationCode string, ctx *zap.Logger) (bool, bool) {
+	return vbdb.UserEmailVerificationIsCtx(userID, email, verificationCode, ctx)
+}
+
+// UserDeleteWebExpect implements `Store`
+func (s *MariaDBStore) UserDeleteWebExpect(userID int, web []string, ctx *zap.Logger) bool {
+	return vbdb.UserDeleteWebExpectCtx(userID, web, ctx)
+}
+
+// UserDeleteSocialExpect implements `Store`
+func (s *MariaDBStore) UserDeleteSocialExpect(userID int, social []string, ctx *zap.Logger) bool {
+	return vbdb.UserDeleteSocialExpectCtx(userID, social, ctx)
+}
+
+// UserSetRegistrationDone implements `Store`
+func (s *MariaDBStore) UserSetRegistrationDone(userID int, ctx *zap.Logger) bool {
+	return vbdb.UserSetRegistrationDoneCtx(userID, ctx)
+}
+
+// RegcodeFromUserID implements `Store`
+func (s *MariaDBStore) RegcodeFromUserID(userID int, ctx *zap.Logger) (string, bool, bool) {
+	return vbdb.RegcodeFromUserIDCtx(userID, ctx)
+}
+
+// OAuthExists implements `Store`
+func (s *MariaDBStore) OAuthExists(providerID string, provider string, ctx *zap.Logger) (int, bool, bool) {
+	return vbdb.OAuthExistsCtx(providerID, provider, ctx)
+}
+
+// RegisterOAuthGithub implements `Store`
+func (s *MariaDBStore) RegisterOAuthGithub(user *github.User, ctx *zap.Logger) (int, string, bool) {
+	return vbdb.RegisterOauthGithubCtx(user, ctx)
+}
+
+// RegisterOAuthGoogle implements `Store`
+func (s *MariaDBStore) RegisterOAuthGoogle(user *vbcore.GoogleUser, ctx *zap.Logger) (int, string, bool) {
+	return vbdb.RegisterOauthGoogleCtx(user, ctx)
+}
+
+// OAuthStateAdd implements `Store`. Expired states are removed first.
+func (s *MariaDBStore) OAuthStateAdd(state OAuthState, ctx *zap.Logger) bool {
+	_, err := s.db.Exec("DELETE FROM oauth_state WHERE exp < ?", time.Now().UTC())
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.OAuthStateAdd", zap.Error(err))
+		return false
+	}
+	_, err = s.db.Exec("INSERT INTO oauth_state (state, provider, verifier, exp) VALUES (?, ?, ?, ?)",
+		state.State, state.Provider, state.Verifier, state.ExpiresAt.UTC())
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.OAuthStateAdd",
+			zap.String("provider", state.Provider),
+			zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// OAuthStateConsume implements `Store`
+func (s *MariaDBStore) OAuthStateConsume(state string, ctx *zap.Logger) (*OAuthState, bool) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.OAuthStateConsume", zap.Error(err))
+		return nil, false
+	}
+	defer tx.Rollback()
+
+	e := OAuthState{State: state}
+	err = tx.QueryRow(`SELECT provider, verifier, exp
+		FROM oauth_state
+		WHERE state=?
+		FOR UPDATE`, state).Scan(&e.Provider, &e.Verifier, &e.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, true
+	}
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.OAuthStateConsume", zap.Error(err))
+		return nil, false
+	}
+
+	_, err = tx.Exec("DELETE FROM oauth_state WHERE state=?", state)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.OAuthStateConsume", zap.Error(err))
+		return nil, false
+	}
+	err = tx.Commit()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.OAuthStateConsume", zap.Error(err))
+		return nil, false
+	}
+	return &e, true
+}
+
+// ActiveRounds implements `Store`
+func (s *MariaDBStore) ActiveRounds(ctx *zap.Logger) ([]vbcore.Round, bool) {
+	if ctx == nil {
+		return vbdb.ActiveRounds()
+	}
+	return vbdb.ActiveRoundsCtx(ctx)
+}
+
+// RoundExists implements `Store`
+func (s *MariaDBStore) RoundExists(roundID int, ctx *zap.Logger) (bool, bool) {
+	return vbdb.RoundExistsCtx(roundID, ctx)
+}
+
+// JoinRound implements `Store`
+func (s *MariaDBStore) JoinRound(userID, roundID int, ctx *zap.Logger) (bool, bool) {
+	return vbdb.JoinRoundCtx(userID, roundID, ctx)
+}
+
+// ActiveRoundentries implements `Store`
+func (s *MariaDBStore) ActiveRoundentries(userID int, ctx *zap.Logger) ([]vbcore.Roundentry, bool) {
+	return vbdb.ActiveRoundentriesCtx(userID, ctx)
+}
+
+// RoundentryConnectinfo implements `Store`
+func (s *MariaDBStore) RoundentryConnectinfo(authtoken string, ctx *zap.Logger) (*vbcore.RoundentryConnectinfo, bool, bool) {
+	return vbdb.RoundentryConnectinfoCtx(authtoken, ctx)
+}
+
+// WebsocketAddressFromWatchtoken implements `Store`
+func (s *MariaDBStore) WebsocketAddressFromWatchtoken(watchtoken string, ctx *zap.Logger) (string, bool, bool) {
+	return vbdb.WebsocketAddressFromWatchtokenCtx(watchtoken, ctx)
+}
+
+// UserPermission implements `Store`
+func (s *MariaDBStore) UserPermission(userID int, ctx *zap.Logger) (int, bool) {
+	return vbdb.UserPermissionCtx(userID, ctx)
+}
+
+// JwtAdd implements `Store`
+func (s *MariaDBStore) JwtAdd(jti string, userID int, ip string, issuedAt time.Time, expiresAt time.Time, ctx *zap.Logger) bool {
+	return vbdb.JwtAddCtx(jti, expiresAt, userID, issuedAt, ip, ctx)
+}
+
+// JwtIsBlacklisted implements `Store`
+func (s *MariaDBStore) JwtIsBlacklisted(jti string, ctx *zap.Logger) (bool, bool) {
+	return vbdb.JwtIsBlacklistedCtx(jti, ctx)
+}
+
+// UserCredentials implements `Store`
+func (s *MariaDBStore) UserCredentials(username string, ctx *zap.Logger) (userID int, hash string, salt string, exists bool, success bool) {
+	err := s.db.QueryRow(`
+		SELECT u.user_id, p.hash, p.salt
+		FROM user_username u
+			JOIN user_password p ON p.user_id = u.user_id
+		WHERE u.username=? AND u.active=1
+		ORDER BY p.id DESC
+		LIMIT 1`, strings.ToLower(username)).Scan(&userID, &hash, &salt)
+	if err == sql.ErrNoRows {
+		return 0, "", "", false, true
+	}
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserCredentials",
+			zap.String("username", username),
+			zap.Error(err))
+		return 0, "", "", false, false
+	}
+	return userID, hash, salt, true, true
+}
+
+// UserPasswordSet implements `Store`. The message is stored in the `msg`
+// table.
+func (s *MariaDBStore) UserPasswordSet(userID int, hash string, salt string, msg string, ctx *zap.Logger) bool {
+	tx, err := s.db.Begin()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserPasswordSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO msg (message) VALUES (?)", msg)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserPasswordSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	msgID, err := res.LastInsertId()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserPasswordSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	_, err = tx.Exec("INSERT INTO user_password (user_id, msg_id, hash, salt) VALUES (?, ?, ?, ?)", userID, msgID, hash, salt)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserPasswordSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserPasswordSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// JwtSessions implements `Store`
+func (s *MariaDBStore) JwtSessions(userID int, ctx *zap.Logger) ([]Session, bool) {
+	rows, err := s.db.Query(`
+		SELECT jti, ip, iat, exp
+		FROM jwts
+		WHERE user_id=? AND valid=1 AND exp > UTC_TIMESTAMP()
+		ORDER BY iat DESC`, userID)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.JwtSessions",
+			zap.Int("user_id", userID),
+			zap.Error(err))
+		return nil, false
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		err = rows.Scan(&session.JTI, &session.IP, &session.IssuedAt, &session.ExpiresAt)
+		if err != nil {
+			ctx.Error("vbapi.MariaDBStore.JwtSessions",
+				zap.Int("user_id", userID),
+				zap.Error(err))
+			return nil, false
+		}
+		sessions = append(sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		ctx.Error("vbapi.MariaDBStore.JwtSessions",
+			zap.Int("user_id", userID),
+			zap.Error(err))
+		return nil, false
+	}
+	return sessions, true
+}
+
+// JwtBlacklist implements `Store`
+func (s *MariaDBStore) JwtBlacklist(userID int, jti string, ctx *zap.Logger) (bool, bool) {
+	res, err := s.db.Exec("UPDATE jwts SET valid=0 WHERE jti=? AND user_id=? AND valid=1", jti, userID)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.JwtBlacklist",
+			zap.Int("user_id", userID),
+			zap.String("jti", jti),
+			zap.Error(err))
+		return false, false
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.JwtBlacklist",
+			zap.Int("user_id", userID),
+			zap.String("jti", jti),
+			zap.Error(err))
+		return false, false
+	}
+	return affected > 0, true
+}
+
+// RefreshTokenAdd implements `Store`
+func (s *MariaDBStore) RefreshTokenAdd(token RefreshToken, ctx *zap.Logger) bool {
+	_, err := s.db.Exec(`INSERT INTO refresh_token (hash, family, user_id, access_jti, iat, exp, used, revoked)
+		VALUES (?, ?, ?, ?, ?, ?, 0, 0)`,
+		token.Hash, token.Family, token.UserID, token.AccessJTI, token.IssuedAt, token.ExpiresAt)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.RefreshTokenAdd",
+			zap.Int("user_id", token.UserID),
+			zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// RefreshTokenUse implements `Store`
+func (s *MariaDBStore) RefreshTokenUse(hash string, ctx *zap.Logger) (*RefreshToken, bool) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.RefreshTokenUse", zap.Error(err))
+		return nil, false
+	}
+	defer tx.Rollback()
+
+	t := RefreshToken{Hash: hash}
+	err = tx.QueryRow(`SELECT family, user_id, access_jti, iat, exp, used, revoked
+		FROM refresh_token
+		WHERE hash=?
+		FOR UPDATE`, hash).Scan(&t.Family, &t.UserID, &t.AccessJTI, &t.IssuedAt, &t.ExpiresAt, &t.Used, &t.Revoked)
+	if err == sql.ErrNoRows {
+		return nil, true
+	}
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.RefreshTokenUse", zap.Error(err))
+		return nil, false
+	}
+
+	if !t.Used {
+		_, err = tx.Exec("UPDATE refresh_token SET used=1 WHERE hash=?", hash)
+		if err != nil {
+			ctx.Error("vbapi.MariaDBStore.RefreshTokenUse", zap.Error(err))
+			return nil, false
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.RefreshTokenUse", zap.Error(err))
+		return nil, false
+	}
+	return &t, true
+}
+
+// RefreshTokenRevokeFamily implements `Store`
+func (s *MariaDBStore) RefreshTokenRevokeFamily(family string, ctx *zap.Logger) ([]string, bool) {
+	_, err := s.db.Exec("UPDATE refresh_token SET revoked=1 WHERE family=?", family)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.RefreshTokenRevokeFamily",
+			zap.String("family", family),
+			zap.Error(err))
+		return nil, false
+	}
+
+	rows, err := s.db.Query("SELECT access_jti FROM refresh_token WHERE family=?", family)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.RefreshTokenRevokeFamily",
+			zap.String("family", family),
+			zap.Error(err))
+		return nil, false
+	}
+	defer rows.Close()
+
+	jtis := []string{}
+	for rows.Next() {
+		var jti string
+		err = rows.Scan(&jti)
+		if err != nil {
+			ctx.Error("vbapi.MariaDBStore.RefreshTokenRevokeFamily",
+				zap.String("family", family),
+				zap.Error(err))
+			return nil, false
+		}
+		jtis = append(jtis, jti)
+	}
+	if err = rows.Err(); err != nil {
+		ctx.Error("vbapi.MariaDBStore.RefreshTokenRevokeFamily",
+			zap.String("family", family),
+			zap.Error(err))
+		return nil, false
+	}
+	return jtis, true
+}
+
+// RefreshTokenFamily implements `Store`
+func (s *MariaDBStore) RefreshTokenFamily(accessJTI string, ctx *zap.Logger) (string, bool, bool) {
+	var family string
+	err := s.db.QueryRow("SELECT family FROM refresh_token WHERE access_jti=?", accessJTI).Scan(&family)
+	if err == sql.ErrNoRows {
+		return "", false, true
+	}
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.RefreshTokenFamily",
+			zap.String("jti", accessJTI),
+			zap.Error(err))
+		return "", false, false
+	}
+	return family, true, true
+}
+
+// AuditAdd implements `Store`. The reason is stored in the `msg` table.
+func (s *MariaDBStore) AuditAdd(entry AuditEntry, ctx *zap.Logger) bool {
+	tx, err := s.db.Begin()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.AuditAdd", zap.Error(err))
+		return false
+	}
+	defer tx.Rollback()
+
+	err = auditInsert(tx, entry)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.AuditAdd", zap.Error(err))
+		return false
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.AuditAdd", zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// auditInsert adds the entry and it's reason to the `audit` and `msg` tables
+// as part of `tx`
+func auditInsert(tx *sql.Tx, entry AuditEntry) error {
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec("INSERT INTO msg (message) VALUES (?)", entry.Reason)
+	if err != nil {
+		return err
+	}
+	msgID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO audit (actor_id, action, target_user_id, msg_id, details, ip, time)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.ActorID, entry.Action, entry.TargetUserID, msgID, string(details), entry.IP, entry.Time)
+	return err
+}
+
+// Audit implements `Store`
+func (s *MariaDBStore) Audit(limit int, ctx *zap.Logger) ([]AuditEntry, bool) {
+	rows, err := s.db.Query(`SELECT a.id, a.actor_id, a.action, a.target_user_id, m.message, a.details, a.ip, a.time
+		FROM audit a
+		JOIN msg m ON m.id=a.msg_id
+		ORDER BY a.id DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.Audit", zap.Error(err))
+		return nil, false
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var details string
+		err = rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetUserID, &e.Reason, &details, &e.IP, &e.Time)
+		if err == nil {
+			err = json.Unmarshal([]byte(details), &e.Details)
+		}
+		if err != nil {
+			ctx.Error("vbapi.MariaDBStore.Audit", zap.Error(err))
+			return nil, false
+		}
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		ctx.Error("vbapi.MariaDBStore.Audit", zap.Error(err))
+		return nil, false
+	}
+	return entries, true
+}
+
+// UserPermissionSet implements `Store`. The reason is stored in the `msg`
+// table and the user's bans are marked as lifted.
+func (s *MariaDBStore) UserPermissionSet(userID int, permission int, reason string, audit *AuditEntry, ctx *zap.Logger) bool {
+	tx, err := s.db.Begin()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserPermissionSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO msg (message) VALUES (?)", reason)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserPermissionSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	msgID, err := res.LastInsertId()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserPermissionSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	_, err = tx.Exec("INSERT INTO user_permission (user_id, msg_id, permission) VALUES (?, ?, ?)", userID, msgID, permission)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserPermissionSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	_, err = tx.Exec("UPDATE user_ban SET lifted=1 WHERE user_id=? AND lifted=0", userID)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserPermissionSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	if audit != nil {
+		err = auditInsert(tx, *audit)
+		if err != nil {
+			ctx.Error("vbapi.MariaDBStore.UserPermissionSet", zap.Int("user_id", userID), zap.Error(err))
+			return false
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserPermissionSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// JwtBlacklistUser implements `Store`
+func (s *MariaDBStore) JwtBlacklistUser(userID int, ctx *zap.Logger) bool {
+	_, err := s.db.Exec("UPDATE jwts SET valid=0 WHERE user_id=? AND valid=1", userID)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.JwtBlacklistUser", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// RefreshTokenRevokeUser implements `Store`
+func (s *MariaDBStore) RefreshTokenRevokeUser(userID int, ctx *zap.Logger) bool {
+	_, err := s.db.Exec("UPDATE refresh_token SET revoked=1 WHERE user_id=? AND revoked=0", userID)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.RefreshTokenRevokeUser", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// UserBanSet implements `Store`. The reason is stored in the `msg` table and
+// shared by the permission change and the ban.
+func (s *MariaDBStore) UserBanSet(userID int, ban Ban, audit *AuditEntry, ctx *zap.Logger) bool {
+	tx, err := s.db.Begin()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserBanSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO msg (message) VALUES (?)", ban.Reason)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserBanSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	msgID, err := res.LastInsertId()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserBanSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	_, err = tx.Exec("INSERT INTO user_permission (user_id, msg_id, permission) VALUES (?, ?, ?)", userID, msgID, vbcore.PermissionBanned)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserBanSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	_, err = tx.Exec("INSERT INTO user_ban (user_id, msg_id, previous, exp) VALUES (?, ?, ?, ?)", userID, msgID, ban.Previous, ban.ExpiresAt)
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserBanSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	if audit != nil {
+		err = auditInsert(tx, *audit)
+		if err != nil {
+			ctx.Error("vbapi.MariaDBStore.UserBanSet", zap.Int("user_id", userID), zap.Error(err))
+			return false
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserBanSet", zap.Int("user_id", userID), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// UserBan implements `Store`
+func (s *MariaDBStore) UserBan(userID int, ctx *zap.Logger) (*Ban, bool) {
+	var ban Ban
+	var exp sql.NullTime
+	err := s.db.QueryRow(`SELECT m.message, b.previous, b.exp
+		FROM user_ban b
+		JOIN msg m ON m.id=b.msg_id
+		WHERE b.user_id=? AND b.lifted=0
+		ORDER BY b.id DESC
+		LIMIT 1`, userID).Scan(&ban.Reason, &ban.Previous, &exp)
+	if err == sql.ErrNoRows {
+		return nil, true
+	}
+	if err != nil {
+		ctx.Error("vbapi.MariaDBStore.UserBan", zap.Int("user_id", userID), zap.Error(err))
+		return nil, false
+	}
+	if exp.Valid {
+		ban.ExpiresAt = &exp.Time
+	}
+	return &ban, true
+}
+
+// Ping implements `Store`. Only the store's own pool is pinged, vbdb
+// doesn't expose it's pool. Both connect to the same database with the same
+// credentials, so an unreachable database fails this ping as well.
+func (s *MariaDBStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close implements `Store`. Only the store's own pool is closed, vbdb offers
+// no way to close it's pool. It's released when the process exits.
+func (s *MariaDBStore) Close() error {
+	return s.db.Close()
+}
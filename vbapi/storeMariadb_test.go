@@ -0,0 +1,83 @@
+package vbapi
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// pingDriver opens connections whose ping fails if the DSN is "down"
+type pingDriver struct{}
+
+type pingConn struct {
+	down bool
+}
+
+func (pingDriver) Open(dsn string) (driver.Conn, error) {
+	return &pingConn{down: dsn == "down"}, nil
+}
+
+func (c *pingConn) Ping(ctx context.Context) error {
+	if c.down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (c *pingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *pingConn) Close() error {
+	return nil
+}
+
+func (c *pingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func init() {
+	sql.Register("vbapi_ping", pingDriver{})
+}
+
+// testStore returns a store whose pool uses `dsn`
+func testStore(t *testing.T, dsn string) *MariaDBStore {
+	t.Helper()
+	db, err := sql.Open("vbapi_ping", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &MariaDBStore{db: db}
+}
+
+func TestMariaDBStorePing(t *testing.T) {
+	s := testStore(t, "up")
+	defer s.Close()
+	err := s.Ping(context.Background())
+	if err != nil {
+		t.Fatalf("want success but got %v", err)
+	}
+
+	s = testStore(t, "down")
+	defer s.Close()
+	err = s.Ping(context.Background())
+	if err == nil || err.Error() != "connection refused" {
+		t.Fatalf("want connection refused but got %v", err)
+	}
+}
+
+func TestMariaDBStoreClose(t *testing.T) {
+	s := testStore(t, "up")
+
+	err := s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Ping(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Fatalf("want closed pool but got %v", err)
+	}
+}
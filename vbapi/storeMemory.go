@@ -0,0 +1,922 @@
+package vbapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/vikebot/vbcore"
+	"go.uber.org/zap"
+)
+
+// MemoryRound is a round as kept by the `MemoryStore` together with the
+// gameserver hosting it.
+type MemoryRound struct {
+	vbcore.Round
+	IPv4    string `json:"ipv4"`
+	IPv6    string `json:"ipv6"`
+	Port    int    `json:"port"`
+	WSProxy int    `json:"wsproxy"`
+}
+
+// MemorySeedUser is a user as described in a `MemorySeed`. If `Password` is
+// set the user can log in with it.
+type MemorySeedUser struct {
+	vbcore.SafeUser
+	Password string `json:"password"`
+}
+
+// MemorySeed describes the initial content of a `MemoryStore`.
+type MemorySeed struct {
+	Users  []MemorySeedUser `json:"users"`
+	Rounds []MemoryRound    `json:"rounds"`
+}
+
+type memoryVerification struct {
+	code string
+	last time.Time
+}
+
+type memoryUser struct {
+	user         vbcore.SafeUser
+	pwdHash      string
+	pwdSalt      string
+	regcode      string
+	regDone      bool
+	verification map[string]memoryVerification
+	ban          *Ban
+}
+
+type memoryJwt struct {
+	userID  int
+	valid   bool
+	session Session
+}
+
+type memoryRoundentry struct {
+	userID      int
+	roundID     int
+	authtoken   string
+	roundticket string
+	watchtoken  string
+	aeskey      string
+}
+
+// MemoryStore implements `Store` completely in memory. It is meant for tests
+// and local development and loses all data when the process exits.
+type MemoryStore struct {
+	mu           sync.RWMutex
+	lastUserID   int
+	users        map[int]*memoryUser
+	rounds       map[int]*MemoryRound
+	roundentries []*memoryRoundentry
+	jwts         []*memoryJwt
+	refresh      map[string]*RefreshToken
+	oauthStates  map[string]OAuthState
+	audit        []AuditEntry
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty `MemoryStore`.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:       make(map[int]*memoryUser),
+		rounds:      make(map[int]*MemoryRound),
+		refresh:     make(map[string]*RefreshToken),
+		oauthStates: make(map[string]OAuthState),
+	}
+}
+
+// Seed decodes a JSON encoded `MemorySeed` from `r` and adds all contained
+// users and rounds to the store.
+func (s *MemoryStore) Seed(r io.Reader) error {
+	var seed MemorySeed
+	err := json.NewDecoder(r).Decode(&seed)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range seed.Users {
+		userID, _, err := s.AddUser(u.SafeUser)
+		if err != nil {
+			return err
+		}
+		if len(u.Password) > 0 {
+			err = s.SetPassword(userID, u.Password)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, round := range seed.Rounds {
+		s.AddRound(round)
+	}
+	return nil
+}
+
+// AddUser adds a new user to the store and returns it's ID together with the
+// registration code needed to finish it's registration. The `ID` of the
+// passed user is ignored.
+func (s *MemoryStore) AddUser(user vbcore.SafeUser) (userID int, regCode string, err error) {
+	regCode, err = vbcore.CryptoGenString(32)
+	if err != nil {
+		return 0, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastUserID++
+	user.ID = s.lastUserID
+	user.PermissionString = vbcore.PermissionItoA(user.Permission)
+	s.users[user.ID] = &memoryUser{
+		user:         copySafeUser(user),
+		regcode:      regCode,
+		verification: make(map[string]memoryVerification),
+	}
+
+	return user.ID, regCode, nil
+}
+
+// SetPassword sets the password the user can log in with.
+func (s *MemoryStore) SetPassword(userID int, password string) error {
+	hash, salt, err := vbcore.CryptoPwd(password)
+	if err != nil {
+		return err
+	}
+
+	if !s.UserPasswordSet(userID, hash, salt, "seed", zap.NewNop()) {
+		return fmt.Errorf("vbapi: unknown user %d", userID)
+	}
+	return nil
+}
+
+// AddRound adds the round to the store or replaces an existing one with the
+// same ID.
+func (s *MemoryStore) AddRound(round MemoryRound) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rounds[round.ID] = &round
+}
+
+func copySafeUser(u vbcore.SafeUser) vbcore.SafeUser {
+	u.Emails = append([]vbcore.Email{}, u.Emails...)
+	u.Web = append([]string{}, u.Web...)
+	social := make(map[string]string, len(u.Social))
+	for k, v := range u.Social {
+		social[k] = v
+	}
+	u.Social = social
+	oauth := make(map[string]string, len(u.OAuth))
+	for k, v := range u.OAuth {
+		oauth[k] = v
+	}
+	u.OAuth = oauth
+	return u
+}
+
+func (s *MemoryStore) roundJoined(roundID int) (joined int) {
+	for _, re := range s.roundentries {
+		if re.roundID == roundID {
+			joined++
+		}
+	}
+	return joined
+}
+
+func (s *MemoryStore) roundActive(round *MemoryRound) bool {
+	return round.RoundStatus == vbcore.RoundStatusOpen ||
+		round.RoundStatus == vbcore.RoundStatusClosed ||
+		round.RoundStatus == vbcore.RoundStatusRunning
+}
+
+// UserFromID implements `Store`
+func (s *MemoryStore) UserFromID(userID int, ctx *zap.Logger) (*vbcore.SafeUser, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, true
+	}
+	user := copySafeUser(u.user)
+	return &user, true
+}
+
+// UserFromUsername implements `Store`
+func (s *MemoryStore) UserFromUsername(username string, ctx *zap.Logger) (*vbcore.SafeUser, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	username = strings.ToLower(username)
+	for _, u := range s.users {
+		if strings.ToLower(u.user.Username) == username {
+			user := copySafeUser(u.user)
+			return &user, true
+		}
+	}
+	return nil, true
+}
+
+// UpdateUser implements `Store`
+func (s *MemoryStore) UpdateUser(newUser *vbcore.User, oldUser *vbcore.SafeUser, msg string, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[oldUser.ID]
+	if !ok {
+		ctx.Error("vbapi.MemoryStore.UpdateUser: unknown user", zap.Int("user_id", oldUser.ID))
+		return false
+	}
+
+	if newUser.Username != nil {
+		u.user.Username = *newUser.Username
+	}
+	if newUser.Name != nil {
+		u.user.Name = *newUser.Name
+	}
+	if newUser.Bio != nil {
+		u.user.Bio = *newUser.Bio
+	}
+	if newUser.Location != nil {
+		u.user.Location = *newUser.Location
+	}
+	if newUser.Company != nil {
+		u.user.Company = *newUser.Company
+	}
+	return true
+}
+
+// UserIDFromRegcode implements `Store`
+func (s *MemoryStore) UserIDFromRegcode(code string, ctx *zap.Logger) (int, bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for id, u := range s.users {
+		if u.regcode == code {
+			return id, u.regDone, true
+		}
+	}
+	return 0, false, true
+}
+
+// UpdateUserEmailStatus implements `Store`
+func (s *MemoryStore) UpdateUserEmailStatus(userID int, email string, status int, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userID]; ok {
+		for i := range u.user.Emails {
+			if u.user.Emails[i].Email == email {
+				u.user.Emails[i].Status = status
+			}
+		}
+	}
+	return true
+}
+
+// UserEmailVerificationLoad implements `Store`
+func (s *MemoryStore) UserEmailVerificationLoad(userID int, email string, ctx *zap.Logger) (*time.Time, bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return &time.Time{}, false, true
+	}
+	v, ok := u.verification[email]
+	if !ok {
+		return &time.Time{}, false, true
+	}
+	last := v.last
+	return &last, true, true
+}
+
+// UserEmailVerificationSet implements `Store`
+func (s *MemoryStore) UserEmailVerificationSet(userID int, email string, verificationCode string, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userID]; ok {
+		u.verification[email] = memoryVerification{
+			code: verificationCode,
+			last: time.Now().UTC(),
+		}
+	}
+	return true
+}
+
+// UserEmailVerificationIs implements `Store`
+func (s *MemoryStore) UserEmailVerificationIs(userID int, email string, verificationCode string, ctx *zap.Logger) (bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return false, true
+	}
+	v, ok := u.verification[email]
+	return ok && v.code == verificationCode, true
+}
+
+// UserDeleteWebExpect implements `Store`
+func (s *MemoryStore) UserDeleteWebExpect(userID int, web []string, ctx *zap.Logger) bool {
+	if len(web) == 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return true
+	}
+	keep := []string{}
+	for _, w := range u.user.Web {
+		for _, expect := range web {
+			if w == expect {
+				keep = append(keep, w)
+				break
+			}
+		}
+	}
+	u.user.Web = keep
+	return true
+}
+
+// UserDeleteSocialExpect implements `Store`
+func (s *MemoryStore) UserDeleteSocialExpect(userID int, social []string, ctx *zap.Logger) bool {
+	if len(social) == 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return true
+	}
+	for platform := range u.user.Social {
+		var found bool
+		for _, expect := range social {
+			if platform == expect {
+				found = true
+				break
+			}
+		}
+		if !found {
+			delete(u.user.Social, platform)
+		}
+	}
+	return true
+}
+
+// UserSetRegistrationDone implements `Store`
+func (s *MemoryStore) UserSetRegistrationDone(userID int, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userID]; ok {
+		u.regDone = true
+	}
+	return true
+}
+
+// UserPermissionSet implements `Store`. The reason isn't kept.
+func (s *MemoryStore) UserPermissionSet(userID int, permission int, reason string, audit *AuditEntry, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userID]; ok {
+		u.user.Permission = permission
+		u.user.PermissionString = vbcore.PermissionItoA(permission)
+		u.ban = nil
+	}
+	if audit != nil {
+		s.auditAdd(*audit)
+	}
+	return true
+}
+
+// UserPermission implements `Store`
+func (s *MemoryStore) UserPermission(userID int, ctx *zap.Logger) (int, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		ctx.Error("vbapi.MemoryStore.UserPermission: user doesn't exist", zap.Int("user_id", userID))
+		return 0, false
+	}
+	return u.user.Permission, true
+}
+
+// UserBanSet implements `Store`
+func (s *MemoryStore) UserBanSet(userID int, ban Ban, audit *AuditEntry, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userID]; ok {
+		u.user.Permission = vbcore.PermissionBanned
+		u.user.PermissionString = vbcore.PermissionBannedString
+		u.ban = &ban
+	}
+	if audit != nil {
+		s.auditAdd(*audit)
+	}
+	return true
+}
+
+// UserBan implements `Store`
+func (s *MemoryStore) UserBan(userID int, ctx *zap.Logger) (*Ban, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok || u.ban == nil {
+		return nil, true
+	}
+	ban := *u.ban
+	return &ban, true
+}
+
+// UserCredentials implements `Store`
+func (s *MemoryStore) UserCredentials(username string, ctx *zap.Logger) (int, string, string, bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	username = strings.ToLower(username)
+	for id, u := range s.users {
+		if strings.ToLower(u.user.Username) == username {
+			if len(u.pwdHash) == 0 {
+				return 0, "", "", false, true
+			}
+			return id, u.pwdHash, u.pwdSalt, true, true
+		}
+	}
+	return 0, "", "", false, true
+}
+
+// UserPasswordSet implements `Store`. The message isn't kept.
+func (s *MemoryStore) UserPasswordSet(userID int, hash string, salt string, msg string, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		ctx.Error("vbapi.MemoryStore.UserPasswordSet: user doesn't exist", zap.Int("user_id", userID))
+		return false
+	}
+	u.pwdHash = hash
+	u.pwdSalt = salt
+	return true
+}
+
+// RegcodeFromUserID implements `Store`
+func (s *MemoryStore) RegcodeFromUserID(userID int, ctx *zap.Logger) (string, bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return "", false, true
+	}
+	return u.regcode, u.regDone, true
+}
+
+// OAuthExists implements `Store`
+func (s *MemoryStore) OAuthExists(providerID string, provider string, ctx *zap.Logger) (int, bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for id, u := range s.users {
+		if pid, ok := u.user.OAuth[provider]; ok && pid == providerID {
+			return id, true, true
+		}
+	}
+	return 0, false, true
+}
+
+// RegisterOAuthGithub implements `Store`. The profile is mapped the same way
+// `vbdb.RegisterOauthGithubCtx` does.
+func (s *MemoryStore) RegisterOAuthGithub(user *github.User, ctx *zap.Logger) (int, string, bool) {
+	u := vbcore.SafeUser{
+		Username: user.GetLogin(),
+		Name:     user.GetName(),
+		Emails:   []vbcore.Email{},
+		Bio:      user.GetBio(),
+		Location: user.GetLocation(),
+		Web:      []string{},
+		Company:  user.GetCompany(),
+		Social: map[string]string{
+			vbcore.OAuthProviderGithub: user.GetHTMLURL(),
+		},
+		OAuth: map[string]string{
+			vbcore.OAuthProviderGithub: strconv.FormatInt(user.GetID(), 10),
+		},
+	}
+	if user.Blog != nil {
+		u.Web = append(u.Web, *user.Blog)
+	}
+	if user.Email != nil {
+		u.Emails = append(u.Emails, vbcore.Email{
+			Email:  *user.Email,
+			Status: vbcore.EmailLinked,
+		})
+	}
+
+	userID, regCode, err := s.AddUser(u)
+	if err != nil {
+		ctx.Error("vbapi.MemoryStore.RegisterOAuthGithub", zap.Error(err))
+		return 0, "", false
+	}
+	return userID, regCode, true
+}
+
+// RegisterOAuthGoogle implements `Store`. The profile is mapped the same way
+// `vbdb.RegisterOauthGoogleCtx` does.
+func (s *MemoryStore) RegisterOAuthGoogle(user *vbcore.GoogleUser, ctx *zap.Logger) (int, string, bool) {
+	u := vbcore.SafeUser{
+		Username: strings.ToLower(user.GivenName) + "_" + user.Sub[len(user.Sub)/2:],
+		Name:     user.Name,
+		Emails: []vbcore.Email{
+			{
+				Email:  user.Email,
+				Status: vbcore.TernaryOperatorI(user.EmailVerified, vbcore.EmailVerified, vbcore.EmailLinked),
+			},
+		},
+		Social: map[string]string{
+			vbcore.OAuthProviderGoogle: user.Profile,
+		},
+		OAuth: map[string]string{
+			vbcore.OAuthProviderGoogle: user.Sub,
+		},
+	}
+
+	userID, regCode, err := s.AddUser(u)
+	if err != nil {
+		ctx.Error("vbapi.MemoryStore.RegisterOAuthGoogle", zap.Error(err))
+		return 0, "", false
+	}
+	return userID, regCode, true
+}
+
+// OAuthStateAdd implements `Store`
+func (s *MemoryStore) OAuthStateAdd(state OAuthState, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range s.oauthStates {
+		if now.After(e.ExpiresAt) {
+			delete(s.oauthStates, k)
+		}
+	}
+	s.oauthStates[state.State] = state
+	return true
+}
+
+// OAuthStateConsume implements `Store`
+func (s *MemoryStore) OAuthStateConsume(state string, ctx *zap.Logger) (*OAuthState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.oauthStates[state]
+	if !ok {
+		return nil, true
+	}
+	delete(s.oauthStates, state)
+	return &entry, true
+}
+
+// ActiveRounds implements `Store`
+func (s *MemoryStore) ActiveRounds(ctx *zap.Logger) ([]vbcore.Round, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rounds := []vbcore.Round{}
+	for _, r := range s.rounds {
+		if !s.roundActive(r) {
+			continue
+		}
+		round := r.Round
+		round.Joined = s.roundJoined(r.ID)
+		rounds = append(rounds, round)
+	}
+	sort.Slice(rounds, func(i, j int) bool {
+		return rounds[i].ID < rounds[j].ID
+	})
+	return rounds, true
+}
+
+// RoundExists implements `Store`
+func (s *MemoryStore) RoundExists(roundID int, ctx *zap.Logger) (bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.rounds[roundID]
+	return ok, true
+}
+
+// JoinRound implements `Store`
+func (s *MemoryStore) JoinRound(userID, roundID int, ctx *zap.Logger) (bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, re := range s.roundentries {
+		if re.userID == userID && re.roundID == roundID {
+			return true, true
+		}
+	}
+
+	entry := &memoryRoundentry{
+		userID:  userID,
+		roundID: roundID,
+	}
+	var err error
+	for _, gen := range []struct {
+		dst *string
+		len int
+	}{
+		{&entry.authtoken, 18},
+		{&entry.roundticket, 16},
+		{&entry.watchtoken, 12},
+	} {
+		*gen.dst, err = vbcore.CryptoGenString(gen.len)
+		if err != nil {
+			ctx.Error("vbapi.MemoryStore.JoinRound - token gen failed", zap.Error(err))
+			return false, false
+		}
+	}
+	entry.aeskey, err = vbcore.CryptoGen()
+	if err != nil {
+		ctx.Error("vbapi.MemoryStore.JoinRound - aeskey gen failed", zap.Error(err))
+		return false, false
+	}
+
+	s.roundentries = append(s.roundentries, entry)
+	return false, true
+}
+
+// ActiveRoundentries implements `Store`
+func (s *MemoryStore) ActiveRoundentries(userID int, ctx *zap.Logger) ([]vbcore.Roundentry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roundentries := []vbcore.Roundentry{}
+	for _, re := range s.roundentries {
+		if re.userID != userID {
+			continue
+		}
+		r, ok := s.rounds[re.roundID]
+		if !ok || !s.roundActive(r) {
+			continue
+		}
+		round := r.Round
+		round.Joined = s.roundJoined(r.ID)
+		roundentries = append(roundentries, vbcore.Roundentry{
+			Round:      round,
+			Authtoken:  re.authtoken,
+			Watchtoken: re.watchtoken,
+		})
+	}
+	sort.Slice(roundentries, func(i, j int) bool {
+		return roundentries[i].ID < roundentries[j].ID
+	})
+	return roundentries, true
+}
+
+// RoundentryConnectinfo implements `Store`
+func (s *MemoryStore) RoundentryConnectinfo(authtoken string, ctx *zap.Logger) (*vbcore.RoundentryConnectinfo, bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, re := range s.roundentries {
+		if re.authtoken != authtoken {
+			continue
+		}
+		r, ok := s.rounds[re.roundID]
+		if !ok {
+			return nil, false, true
+		}
+		return &vbcore.RoundentryConnectinfo{
+			Roundticket: re.roundticket,
+			AESKey:      re.aeskey,
+			IPv4:        r.IPv4,
+			IPv6:        r.IPv6,
+			Port:        r.Port,
+		}, true, true
+	}
+	return nil, false, true
+}
+
+// JwtAdd implements `Store`
+func (s *MemoryStore) JwtAdd(jti string, userID int, ip string, issuedAt time.Time, expiresAt time.Time, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jwts = append(s.jwts, &memoryJwt{
+		userID: userID,
+		valid:  true,
+		session: Session{
+			JTI:       jti,
+			IP:        ip,
+			IssuedAt:  issuedAt,
+			ExpiresAt: expiresAt,
+		},
+	})
+	return true
+}
+
+// JwtIsBlacklisted implements `Store`
+func (s *MemoryStore) JwtIsBlacklisted(jti string, ctx *zap.Logger) (bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, t := range s.jwts {
+		if t.session.JTI == jti {
+			return !t.valid, true
+		}
+	}
+	return true, true
+}
+
+// JwtSessions implements `Store`
+func (s *MemoryStore) JwtSessions(userID int, ctx *zap.Logger) ([]Session, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	sessions := []Session{}
+	for _, t := range s.jwts {
+		if t.userID == userID && t.valid && t.session.ExpiresAt.After(now) {
+			sessions = append(sessions, t.session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].IssuedAt.After(sessions[j].IssuedAt)
+	})
+	return sessions, true
+}
+
+// JwtBlacklist implements `Store`
+func (s *MemoryStore) JwtBlacklist(userID int, jti string, ctx *zap.Logger) (bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.jwts {
+		if t.userID == userID && t.session.JTI == jti && t.valid {
+			t.valid = false
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// RefreshTokenAdd implements `Store`
+func (s *MemoryStore) RefreshTokenAdd(token RefreshToken, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh[token.Hash] = &token
+	return true
+}
+
+// RefreshTokenUse implements `Store`
+func (s *MemoryStore) RefreshTokenUse(hash string, ctx *zap.Logger) (*RefreshToken, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.refresh[hash]
+	if !ok {
+		return nil, true
+	}
+	prior := *t
+	t.Used = true
+	return &prior, true
+}
+
+// RefreshTokenRevokeFamily implements `Store`
+func (s *MemoryStore) RefreshTokenRevokeFamily(family string, ctx *zap.Logger) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jtis := []string{}
+	for _, t := range s.refresh {
+		if t.Family == family {
+			t.Revoked = true
+			jtis = append(jtis, t.AccessJTI)
+		}
+	}
+	return jtis, true
+}
+
+// RefreshTokenFamily implements `Store`
+func (s *MemoryStore) RefreshTokenFamily(accessJTI string, ctx *zap.Logger) (string, bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, t := range s.refresh {
+		if t.AccessJTI == accessJTI {
+			return t.Family, true, true
+		}
+	}
+	return "", false, true
+}
+
+// JwtBlacklistUser implements `Store`
+func (s *MemoryStore) JwtBlacklistUser(userID int, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.jwts {
+		if t.userID == userID {
+			t.valid = false
+		}
+	}
+	return true
+}
+
+// RefreshTokenRevokeUser implements `Store`
+func (s *MemoryStore) RefreshTokenRevokeUser(userID int, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.refresh {
+		if t.UserID == userID {
+			t.Revoked = true
+		}
+	}
+	return true
+}
+
+// AuditAdd implements `Store`
+func (s *MemoryStore) AuditAdd(entry AuditEntry, ctx *zap.Logger) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditAdd(entry)
+	return true
+}
+
+// auditAdd appends a copy of the entry. The caller must hold `s.mu`.
+func (s *MemoryStore) auditAdd(entry AuditEntry) {
+	entry.ID = len(s.audit) + 1
+	details := make(map[string]string, len(entry.Details))
+	for k, v := range entry.Details {
+		details[k] = v
+	}
+	entry.Details = details
+	s.audit = append(s.audit, entry)
+}
+
+// Audit implements `Store`
+func (s *MemoryStore) Audit(limit int, ctx *zap.Logger) ([]AuditEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := []AuditEntry{}
+	for i := len(s.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, s.audit[i])
+	}
+	return entries, true
+}
+
+// WebsocketAddressFromWatchtoken implements `Store`
+func (s *MemoryStore) WebsocketAddressFromWatchtoken(watchtoken string, ctx *zap.Logger) (string, bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, re := range s.roundentries {
+		if re.watchtoken != watchtoken {
+			continue
+		}
+		r, ok := s.rounds[re.roundID]
+		if !ok {
+			return "", false, true
+		}
+		return r.IPv4 + ":" + strconv.Itoa(r.WSProxy), true, true
+	}
+	return "", false, true
+}
+
+// Ping implements `Store`
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Close implements `Store`
+func (s *MemoryStore) Close() error {
+	return nil
+}
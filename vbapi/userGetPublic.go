@@ -1,29 +1,26 @@
 package vbapi
 
 import (
-	"net/http"
 	"strconv"
 
 	"github.com/vikebot/vbcore"
-	"github.com/vikebot/vbdb"
-	"github.com/vikebot/vbnet"
 	"go.uber.org/zap"
 )
 
 // UserGetPublicByID returns the public user profile from the database
 // associated with the `userID`.
-func UserGetPublicByID(userID string, ctx *zap.Logger) (user *vbcore.SafeUser, err error) {
+func (s *Service) UserGetPublicByID(userID string, ctx *zap.Logger) (user *vbcore.SafeUser, err error) {
 	id, err := strconv.Atoi(userID)
 	if err != nil {
-		return nil, vbnet.NewHTTPError("user_id must be an int", http.StatusBadRequest, codeUserIDMustBeInt, nil)
+		return nil, errUserIDMustBeInt
 	}
 
-	user, success := vbdb.UserFromIDCtx(id, ctx)
+	user, success := s.store.UserFromID(id, ctx)
 	if !success {
 		return nil, errInternalServerError
 	}
 	if user == nil {
-		return nil, vbnet.NewHTTPError("user_id doesn't exist", http.StatusNotFound, codeUserIDDoesnotExist, nil)
+		return nil, errUserIDDoesnotExist
 	}
 
 	// Remove any sensitive data from the user
@@ -34,13 +31,13 @@ func UserGetPublicByID(userID string, ctx *zap.Logger) (user *vbcore.SafeUser, e
 
 // UserGetPublicByUsername returns the public user profile from the database
 // associated with the `username`.
-func UserGetPublicByUsername(username string, ctx *zap.Logger) (user *vbcore.SafeUser, err error) {
-	user, success := vbdb.UserFromUsernameCtx(username, ctx)
+func (s *Service) UserGetPublicByUsername(username string, ctx *zap.Logger) (user *vbcore.SafeUser, err error) {
+	user, success := s.store.UserFromUsername(username, ctx)
 	if !success {
 		return nil, errInternalServerError
 	}
 	if user == nil {
-		return nil, vbnet.NewHTTPError("username doesn't exist", http.StatusOK, codeUsernameDoesnotExist, nil)
+		return nil, errUsernameDoesnotExist
 	}
 
 	// Remove any sensitive data from the user
@@ -4,22 +4,72 @@ import (
 	"errors"
 
 	"github.com/vikebot/vbcore"
-	"github.com/vikebot/vbdb"
 	"go.uber.org/zap"
 )
 
-// UserUpdate updates an old user profile in vbdb with a new user
-// profile
-func UserUpdate(userID int, newUser *vbcore.User, msg string, ctx *zap.Logger) error {
-	oldUser, success := vbdb.UserFromID(userID)
-	if !success {
+// UserUpdate updates an old user profile in the store with a new user
+// profile. Fields users can't change (e.g. id or permission) are ignored.
+func (s *Service) UserUpdate(userID int, newUser *vbcore.User, msg string, ctx *zap.Logger) error {
+	oldUser, success := s.store.UserFromID(userID, ctx)
+	if !success || oldUser == nil {
 		return errors.New("Internal server error")
 	}
 
-	success = vbdb.UpdateUserCtx(newUser, oldUser, msg, ctx)
+	success = s.store.UpdateUser(newUser, oldUser, msg, ctx)
 	if !success {
 		return errors.New("Internal server error")
 	}
 
 	return nil
 }
+
+// UserUpdateValidated is the same as `UserUpdate`, but rejects the update if
+// the updated profile isn't valid or `newUser` contains fields users can't
+// change.
+func (s *Service) UserUpdateValidated(userID int, newUser *vbcore.User, msg string, ctx *zap.Logger) error {
+	oldUser, success := s.store.UserFromID(userID, ctx)
+	if !success {
+		return errInternalServerError
+	}
+	if oldUser == nil {
+		return errUserIDDoesnotExist
+	}
+
+	// Only the fields set in `newUser` change, so the result of the update
+	// is validated
+	updated := oldUser.User()
+	updated.ID = newUser.ID
+	updated.Permission = newUser.Permission
+	updated.PermissionString = newUser.PermissionString
+	updated.OAuth = newUser.OAuth
+	if newUser.Username != nil {
+		updated.Username = newUser.Username
+	}
+	if newUser.Name != nil {
+		updated.Name = newUser.Name
+	}
+	if newUser.Emails != nil {
+		updated.Emails = newUser.Emails
+	}
+	if newUser.Bio != nil {
+		updated.Bio = newUser.Bio
+	}
+	if newUser.Location != nil {
+		updated.Location = newUser.Location
+	}
+	if newUser.Web != nil {
+		updated.Web = newUser.Web
+	}
+	if newUser.Company != nil {
+		updated.Company = newUser.Company
+	}
+	if _, fields := validateUser(updated); fields != nil {
+		return NewValidationError(errBadUserState, fields)
+	}
+
+	success = s.store.UpdateUser(newUser, oldUser, msg, ctx)
+	if !success {
+		return errInternalServerError
+	}
+	return nil
+}
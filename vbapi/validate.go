@@ -0,0 +1,141 @@
+package vbapi
+
+import (
+	"strconv"
+
+	"github.com/vikebot/vbcore"
+	"github.com/vikebot/vbnet"
+)
+
+// Validation rules reported in `FieldError.Rule`
+const (
+	RuleRequired = "required"
+	RuleReadOnly = "readonly"
+	RuleMax      = "max"
+	RuleOneOf    = "oneof"
+	RuleUnknown  = "unknown"
+	RuleType     = "type"
+)
+
+// Limits of the user fields enforced by `vbcore.User.Validate`
+const (
+	maxUsernameLength = 32
+	maxNameLength     = 32
+	maxEmailLength    = 64
+	maxBioLength      = 1024
+	maxLocationLength = 64
+	maxCompanyLength  = 64
+	maxWebLength      = 128
+)
+
+// FieldError describes why a single field of a request is invalid. `Param`
+// contains the rule's parameter, e.g. the expected type or the maximum
+// length.
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// ValidationDetails are the details of a `ValidationError`
+type ValidationDetails struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// ValidationError is returned if one or more fields of a request are
+// invalid.
+type ValidationError struct {
+	vbnet.HTTPError
+	Fields []FieldError
+}
+
+var _ DetailedError = (*ValidationError)(nil)
+
+// NewValidationError attaches the invalid fields to the registered error
+// `err`.
+func NewValidationError(err vbnet.HTTPError, fields []FieldError) *ValidationError {
+	return &ValidationError{
+		HTTPError: err,
+		Fields:    fields,
+	}
+}
+
+// Details implements `DetailedError`
+func (e *ValidationError) Details() interface{} {
+	return &ValidationDetails{Fields: e.Fields}
+}
+
+// emailStatuses are the valid values of `vbcore.Email.Status`
+var emailStatuses = strconv.Itoa(vbcore.EmailLinked) + " " +
+	strconv.Itoa(vbcore.EmailVerified) + " " +
+	strconv.Itoa(vbcore.EmailPrimary)
+
+// userProbe is a user which passes `vbcore.User.Validate`. `validateUser`
+// replaces single fields of it to find out which fields of an invalid user
+// are wrong.
+func userProbe() vbcore.User {
+	empty := ""
+	return vbcore.User{
+		Username: &empty,
+		Name:     &empty,
+		Emails:   []vbcore.Email{{Status: vbcore.EmailLinked}},
+		Bio:      &empty,
+		Location: &empty,
+		Company:  &empty,
+	}
+}
+
+// validateUser checks a user object passed from clients with
+// `vbcore.User.Validate`. If it's invalid, every field is validated on it's
+// own to report all offending ones together with the violated rule.
+func validateUser(u *vbcore.User) (*vbcore.SafeUser, []FieldError) {
+	safeUser, valid := u.Validate()
+	if valid {
+		return safeUser, nil
+	}
+
+	var errs []FieldError
+	probe := func(err FieldError, set func(p *vbcore.User)) {
+		p := userProbe()
+		set(&p)
+		if _, valid := p.Validate(); !valid {
+			errs = append(errs, err)
+		}
+	}
+	// length is the error of a string field which is required and limited
+	// to `max` bytes
+	length := func(field string, v *string, max int) FieldError {
+		if v == nil {
+			return FieldError{Field: field, Rule: RuleRequired}
+		}
+		return FieldError{Field: field, Rule: RuleMax, Param: strconv.Itoa(max)}
+	}
+
+	probe(FieldError{Field: "id", Rule: RuleReadOnly}, func(p *vbcore.User) { p.ID = u.ID })
+	probe(FieldError{Field: "permission", Rule: RuleReadOnly}, func(p *vbcore.User) { p.Permission = u.Permission })
+	probe(FieldError{Field: "permissionstring", Rule: RuleReadOnly}, func(p *vbcore.User) { p.PermissionString = u.PermissionString })
+	probe(FieldError{Field: "oauth", Rule: RuleReadOnly}, func(p *vbcore.User) { p.OAuth = u.OAuth })
+	probe(length("username", u.Username, maxUsernameLength), func(p *vbcore.User) { p.Username = u.Username })
+	probe(length("name", u.Name, maxNameLength), func(p *vbcore.User) { p.Name = u.Name })
+	if len(u.Emails) == 0 {
+		errs = append(errs, FieldError{Field: "emails", Rule: RuleRequired})
+	}
+	for i, e := range u.Emails {
+		field := "emails[" + strconv.Itoa(i) + "]"
+		probe(FieldError{Field: field + ".Email", Rule: RuleMax, Param: strconv.Itoa(maxEmailLength)}, func(p *vbcore.User) {
+			p.Emails = []vbcore.Email{{Email: e.Email, Status: vbcore.EmailLinked}}
+		})
+		probe(FieldError{Field: field + ".Status", Rule: RuleOneOf, Param: emailStatuses}, func(p *vbcore.User) {
+			p.Emails = []vbcore.Email{{Status: e.Status}}
+		})
+	}
+	probe(length("bio", u.Bio, maxBioLength), func(p *vbcore.User) { p.Bio = u.Bio })
+	probe(length("location", u.Location, maxLocationLength), func(p *vbcore.User) { p.Location = u.Location })
+	probe(length("company", u.Company, maxCompanyLength), func(p *vbcore.User) { p.Company = u.Company })
+	for i, w := range u.Web {
+		probe(FieldError{Field: "web[" + strconv.Itoa(i) + "]", Rule: RuleMax, Param: strconv.Itoa(maxWebLength)}, func(p *vbcore.User) {
+			p.Web = []string{w}
+		})
+	}
+	return nil, errs
+}
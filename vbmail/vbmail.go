@@ -6,11 +6,18 @@ import (
 )
 
 var (
-	client *sendgrid.Client
+	client     *sendgrid.Client
+	configured bool
 )
 
 func Init(sendgridSecret string) {
 	client = sendgrid.NewSendClient(sendgridSecret)
+	configured = len(sendgridSecret) > 0
+}
+
+// Configured reports whether `Init` was called with a sendgrid secret
+func Configured() bool {
+	return configured
 }
 
 // SendTo sends a new email using sendgrid service
@@ -0,0 +1,365 @@
+package vbtrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// ExporterStderr writes spans to stderr (or `Config.Writer`). It isn't
+	// written to stdout, because that's where the JSON logs go by default.
+	ExporterStderr = "stderr"
+	// ExporterOTLP sends spans to an OTLP/HTTP collector
+	ExporterOTLP = "otlp"
+
+	defaultBatchSize     = 512
+	defaultQueueSize     = 2048
+	defaultFlushInterval = 5 * time.Second
+	defaultOTLPTimeout   = 10 * time.Second
+)
+
+// Config configures the export of spans
+type Config struct {
+	// ServiceName is reported as `service.name` resource attribute
+	ServiceName string
+	// ServiceVersion is reported as `service.version` resource attribute
+	ServiceVersion string
+	// Exporter is `ExporterStderr`, `ExporterOTLP` or empty to discard all
+	// spans. Trace ids are still propagated if spans are discarded.
+	Exporter string
+	// Writer replaces stderr as destination of `ExporterStderr`
+	Writer io.Writer
+	// Endpoint is the URL spans are posted to by the OTLP exporter, e.g.
+	// `http://localhost:4318/v1/traces`
+	Endpoint string
+	// Headers are sent with every OTLP export, e.g. for authentication
+	Headers map[string]string
+	// SampleRatio is the fraction of new traces which are recorded. Traces
+	// started by a caller keep the caller's decision.
+	SampleRatio float64
+	// FlushInterval is the maximum time spans are buffered before they are
+	// exported. Defaults to 5s.
+	FlushInterval time.Duration
+}
+
+// exporter sends a batch of finished spans to it's backend
+type exporter interface {
+	export(spans []*Span) error
+}
+
+var (
+	sampleRatio float64
+	queue       chan *Span
+	flushed     chan struct{}
+
+	// closeMu guards `queue` against being used after `Close`
+	closeMu sync.RWMutex
+)
+
+// Init starts exporting spans as configured. If it isn't called spans are
+// only used to propagate trace ids.
+func Init(config Config) error {
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return fmt.Errorf("vbtrace: sample ratio must be between 0 and 1 but is %v", config.SampleRatio)
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+
+	res := newOTLPResource(config.ServiceName, config.ServiceVersion)
+	var e exporter
+	switch config.Exporter {
+	case "":
+		return nil
+	case ExporterStderr:
+		w := config.Writer
+		if w == nil {
+			w = os.Stderr
+		}
+		e = &writerExporter{w: w, resource: res}
+	case ExporterOTLP:
+		if len(config.Endpoint) == 0 {
+			return fmt.Errorf("vbtrace: otlp exporter needs an endpoint")
+		}
+		e = &otlpExporter{
+			endpoint: config.Endpoint,
+			headers:  config.Headers,
+			client:   &http.Client{Timeout: defaultOTLPTimeout},
+			resource: res,
+		}
+	default:
+		return fmt.Errorf("vbtrace: unknown exporter '%s'", config.Exporter)
+	}
+
+	closeMu.Lock()
+	sampleRatio = config.SampleRatio
+	queue = make(chan *Span, defaultQueueSize)
+	flushed = make(chan struct{})
+	closeMu.Unlock()
+
+	go batch(e, queue, flushed, config.FlushInterval)
+	return nil
+}
+
+// Close exports all buffered spans and stops the export. Spans ended
+// afterwards are dropped.
+func Close() {
+	closeMu.Lock()
+	if queue == nil {
+		closeMu.Unlock()
+		return
+	}
+	close(queue)
+	queue = nil
+	sampleRatio = 0
+	done := flushed
+	closeMu.Unlock()
+
+	<-done
+}
+
+// sample decides whether a new trace is recorded
+func sample() bool {
+	closeMu.RLock()
+	defer closeMu.RUnlock()
+	if queue == nil {
+		return false
+	}
+	return sampleRatio >= 1 || rand.Float64() < sampleRatio
+}
+
+// export queues a finished span. If the queue is full the span is dropped
+// instead of blocking the request.
+func export(s *Span) {
+	closeMu.RLock()
+	defer closeMu.RUnlock()
+	if queue == nil {
+		return
+	}
+	select {
+	case queue <- s:
+	default:
+	}
+}
+
+// batch exports the spans of `q` whenever `defaultBatchSize` are buffered
+// or `interval` passed. It exports the rest and closes `done` once `q` is
+// closed.
+func batch(e exporter, q <-chan *Span, done chan<- struct{}, interval time.Duration) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	buf := make([]*Span, 0, defaultBatchSize)
+	flush := func() {
+		if len(buf) == 0 {
+			return
+		}
+		err := e.export(buf)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "vbtrace: unable to export %d spans: %v\n", len(buf), err)
+		}
+		buf = make([]*Span, 0, defaultBatchSize)
+	}
+
+	for {
+		select {
+		case s, ok := <-q:
+			if !ok {
+				flush()
+				return
+			}
+			buf = append(buf, s)
+			if len(buf) >= defaultBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// writerExporter writes every batch as one OTLP/JSON line, the format read
+// by the collector's `otlpjsonfile` receiver
+type writerExporter struct {
+	w        io.Writer
+	resource otlpResource
+}
+
+func (e *writerExporter) export(spans []*Span) error {
+	buf, err := json.Marshal(newOTLPRequest(e.resource, spans))
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(buf, '\n'))
+	return err
+}
+
+// otlpExporter posts batches to an OTLP/HTTP collector using the JSON
+// encoding
+type otlpExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+	resource otlpResource
+}
+
+func (e *otlpExporter) export(spans []*Span) error {
+	buf, err := json.Marshal(newOTLPRequest(e.resource, spans))
+	if err != nil {
+		return err
+	}
+
+	r, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		r.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// The types below are the JSON encoding of OTLP's
+// `ExportTraceServiceRequest`. Ids are hex and 64 bit integers are strings.
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              Kind            `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+// otlpStatus codes: 0 unset, 2 error
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func newOTLPValue(v interface{}) otlpValue {
+	switch v := v.(type) {
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	default:
+		s := fmt.Sprint(v)
+		return otlpValue{StringValue: &s}
+	}
+}
+
+func newOTLPResource(serviceName, serviceVersion string) otlpResource {
+	var r otlpResource
+	if len(serviceName) > 0 {
+		r.Attributes = append(r.Attributes, otlpAttribute{Key: "service.name", Value: newOTLPValue(serviceName)})
+	}
+	if len(serviceVersion) > 0 {
+		r.Attributes = append(r.Attributes, otlpAttribute{Key: "service.version", Value: newOTLPValue(serviceVersion)})
+	}
+	return r
+}
+
+func newOTLPRequest(res otlpResource, spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		out = append(out, s.otlp())
+	}
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: res,
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/vikebot/vbrest/vbtrace"},
+				Spans: out,
+			}},
+		}},
+	}
+}
+
+func (s *Span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o := otlpSpan{
+		TraceID:           s.ctx.TraceID.String(),
+		SpanID:            s.ctx.SpanID.String(),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	if s.parent != (SpanID{}) {
+		o.ParentSpanID = s.parent.String()
+	}
+	for _, a := range s.attrs {
+		o.Attributes = append(o.Attributes, otlpAttribute{Key: a.Key, Value: newOTLPValue(a.Value)})
+	}
+	if s.failed {
+		o.Status = otlpStatus{Code: 2, Message: s.errorMsg}
+	}
+	return o
+}
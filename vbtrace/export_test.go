@@ -0,0 +1,144 @@
+package vbtrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// collectorStub is an OTLP/HTTP collector recording all received requests
+type collectorStub struct {
+	mu       sync.Mutex
+	requests []otlpRequest
+	headers  []http.Header
+}
+
+func (c *collectorStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	c.requests = append(c.requests, req)
+	c.headers = append(c.headers, r.Header)
+	c.mu.Unlock()
+}
+
+// spans returns all received spans by name
+func (c *collectorStub) spans() map[string]otlpSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	spans := make(map[string]otlpSpan)
+	for _, req := range c.requests {
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					spans[s.Name] = s
+				}
+			}
+		}
+	}
+	return spans
+}
+
+// recordSpans records a server span with a failed child
+func recordSpans() (server, child *Span) {
+	server = StartSpan("GET /v1/test", KindServer, nil)
+	server.SetAttribute("http.status_code", int64(200))
+	child = server.Child("db.query", KindClient)
+	child.SetError(errors.New("connection refused"))
+	child.End()
+	server.End()
+	return server, child
+}
+
+func TestOTLPExportToCollector(t *testing.T) {
+	collector := &collectorStub{}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	err := Init(Config{
+		ServiceName:    "vbrest",
+		ServiceVersion: "1.2.3",
+		Exporter:       ExporterOTLP,
+		Endpoint:       srv.URL + "/v1/traces",
+		Headers:        map[string]string{"Authorization": "Bearer collector"},
+		SampleRatio:    1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, child := recordSpans()
+	Close()
+
+	if len(collector.requests) != 1 {
+		t.Fatalf("want 1 export but got %d", len(collector.requests))
+	}
+	if auth := collector.headers[0].Get("Authorization"); auth != "Bearer collector" {
+		t.Fatalf("want configured header but got %q", auth)
+	}
+	if ct := collector.headers[0].Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("want JSON but got %q", ct)
+	}
+	res := collector.requests[0].ResourceSpans[0].Resource.Attributes
+	if len(res) != 2 || *res[0].Value.StringValue != "vbrest" || *res[1].Value.StringValue != "1.2.3" {
+		t.Fatalf("want service name and version but got %+v", res)
+	}
+
+	spans := collector.spans()
+	s, ok := spans["GET /v1/test"]
+	if !ok || s.TraceID != server.Context().TraceID.String() || s.Kind != KindServer || len(s.ParentSpanID) > 0 {
+		t.Fatalf("want root server span but got %+v", s)
+	}
+	c, ok := spans["db.query"]
+	if !ok || c.ParentSpanID != s.SpanID || c.TraceID != s.TraceID || c.SpanID != child.Context().SpanID.String() {
+		t.Fatalf("want child of the server span but got %+v", c)
+	}
+	if c.Status.Code != 2 || c.Status.Message != "connection refused" {
+		t.Fatalf("want failed child but got %+v", c.Status)
+	}
+
+	// Spans ended after `Close` are dropped
+	recordSpans()
+	if len(collector.requests) != 1 {
+		t.Fatalf("want no export after close but got %d", len(collector.requests))
+	}
+}
+
+func TestWriterExport(t *testing.T) {
+	var buf bytes.Buffer
+	err := Init(Config{Exporter: ExporterStderr, Writer: &buf, SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordSpans()
+	Close()
+
+	lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != 1 {
+		t.Fatalf("want one line per batch but got %d", len(lines))
+	}
+	var req otlpRequest
+	err = json.Unmarshal(lines[0], &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(req.ResourceSpans[0].ScopeSpans[0].Spans); n != 2 {
+		t.Fatalf("want 2 spans but got %d", n)
+	}
+}
+
+func TestInitRejectsStdout(t *testing.T) {
+	err := Init(Config{Exporter: "stdout", SampleRatio: 1})
+	if err == nil {
+		Close()
+		t.Fatal("want the stdout exporter to be rejected")
+	}
+}
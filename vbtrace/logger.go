@@ -0,0 +1,51 @@
+package vbtrace
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// spanCore carries the current span of a request inside the request's
+// logger. It doesn't change what is logged.
+type spanCore struct {
+	zapcore.Core
+	span *Span
+}
+
+func (c *spanCore) With(fields []zapcore.Field) zapcore.Core {
+	return &spanCore{
+		Core: c.Core.With(fields),
+		span: c.span,
+	}
+}
+
+// WithSpan returns a logger carrying `span`. Spans started with `Start` on
+// the returned logger (or loggers derived from it) are children of `span`.
+func WithSpan(ctx *zap.Logger, span *Span) *zap.Logger {
+	return ctx.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if sc, ok := core.(*spanCore); ok {
+			core = sc.Core
+		}
+		return &spanCore{
+			Core: core,
+			span: span,
+		}
+	}))
+}
+
+// FromLogger returns the span carried by `ctx`. Nil if there is none.
+func FromLogger(ctx *zap.Logger) *Span {
+	if ctx == nil {
+		return nil
+	}
+	if sc, ok := ctx.Core().(*spanCore); ok {
+		return sc.span
+	}
+	return nil
+}
+
+// Start starts a child of the span carried by `ctx`. Returns nil (which is
+// a valid no-op span) if `ctx` carries no span.
+func Start(ctx *zap.Logger, name string, kind Kind) *Span {
+	return FromLogger(ctx).Child(name, kind)
+}
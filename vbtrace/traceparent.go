@@ -0,0 +1,79 @@
+package vbtrace
+
+import (
+	"encoding/hex"
+)
+
+// traceparent is `<version>-<trace-id>-<parent-id>-<flags>` with all parts
+// as lowercase hex. Later versions may append more fields.
+// https://www.w3.org/TR/trace-context/#traceparent-header
+const traceparentLen = 55
+
+const flagSampled = 0x01
+
+// ParseTraceparent parses a W3C `traceparent` header. `ok` is false if the
+// header is missing or malformed, in which case a new trace should be
+// started.
+func ParseTraceparent(header string) (sc SpanContext, ok bool) {
+	if len(header) < traceparentLen {
+		return SpanContext{}, false
+	}
+	if header[2] != '-' || header[35] != '-' || header[52] != '-' {
+		return SpanContext{}, false
+	}
+
+	version, ok := parseHex(header[0:2])
+	if !ok || len(version) != 1 || version[0] == 0xff {
+		return SpanContext{}, false
+	}
+	// Version 00 has exactly four fields. Later versions are parsed as 00 as
+	// long as the known fields are intact.
+	if version[0] == 0 && len(header) != traceparentLen {
+		return SpanContext{}, false
+	}
+	if len(header) > traceparentLen && header[traceparentLen] != '-' {
+		return SpanContext{}, false
+	}
+
+	traceID, ok := parseHex(header[3:35])
+	if !ok {
+		return SpanContext{}, false
+	}
+	spanID, ok := parseHex(header[36:52])
+	if !ok {
+		return SpanContext{}, false
+	}
+	flags, ok := parseHex(header[53:55])
+	if !ok {
+		return SpanContext{}, false
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	if sc.TraceID == (TraceID{}) || sc.SpanID == (SpanID{}) {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&flagSampled != 0
+	return sc, true
+}
+
+// parseHex only accepts lowercase hex as required by the spec
+func parseHex(s string) ([]byte, bool) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return nil, false
+		}
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// Traceparent formats `sc` as version 00 `traceparent` header
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
@@ -0,0 +1,64 @@
+package vbtrace
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		ok      bool
+		sampled bool
+	}{
+		{"sampled", "00-" + testTraceID + "-" + testSpanID + "-01", true, true},
+		{"not sampled", "00-" + testTraceID + "-" + testSpanID + "-00", true, false},
+		{"future version", "01-" + testTraceID + "-" + testSpanID + "-01-extra", true, true},
+		{"missing", "", false, false},
+		{"too short", "00-" + testTraceID + "-" + testSpanID + "-1", false, false},
+		{"version 00 with extra field", "00-" + testTraceID + "-" + testSpanID + "-01-extra", false, false},
+		{"invalid version", "ff-" + testTraceID + "-" + testSpanID + "-01", false, false},
+		{"upper case", "00-" + strings.ToUpper(testTraceID) + "-" + testSpanID + "-01", false, false},
+		{"not hex", "00-" + strings.Repeat("z", 32) + "-" + testSpanID + "-01", false, false},
+		{"wrong separator", "00_" + testTraceID + "-" + testSpanID + "-01", false, false},
+		{"zero trace id", "00-" + strings.Repeat("0", 32) + "-" + testSpanID + "-01", false, false},
+		{"zero parent id", "00-" + testTraceID + "-" + strings.Repeat("0", 16) + "-01", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.header)
+			if ok != tt.ok {
+				t.Fatalf("want ok %v but got %v", tt.ok, ok)
+			}
+			if !ok {
+				if sc != (SpanContext{}) {
+					t.Fatalf("want empty span context but got %+v", sc)
+				}
+				return
+			}
+			if sc.TraceID.String() != testTraceID || sc.SpanID.String() != testSpanID {
+				t.Fatalf("want %s/%s but got %s/%s", testTraceID, testSpanID, sc.TraceID, sc.SpanID)
+			}
+			if sc.Sampled != tt.sampled {
+				t.Fatalf("want sampled %v but got %v", tt.sampled, sc.Sampled)
+			}
+		})
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	header := "00-" + testTraceID + "-" + testSpanID + "-01"
+	sc, ok := ParseTraceparent(header)
+	if !ok {
+		t.Fatal("valid traceparent rejected")
+	}
+	if sc.Traceparent() != header {
+		t.Fatalf("want %s but got %s", header, sc.Traceparent())
+	}
+}
@@ -0,0 +1,199 @@
+// Package vbtrace records spans of vbrest requests and exports them in the
+// OpenTelemetry (OTLP) format. Traces are propagated with the W3C
+// `traceparent` header, so requests can be correlated with the services
+// calling vbrest.
+//
+// Spans are threaded through the code with the request's `*zap.Logger` (see
+// `WithSpan`), because it's the logging context all vbapi functions already
+// receive. All methods of `*Span` are safe to call on a nil span.
+package vbtrace
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Kind describes the relationship of a span to it's remote counterpart.
+// The values match OTLP's `SpanKind`.
+type Kind int
+
+const (
+	// KindInternal is an operation within vbrest
+	KindInternal Kind = 1
+	// KindServer is an incoming request
+	KindServer Kind = 2
+	// KindClient is an outgoing call, e.g. to the DB or sendgrid
+	KindClient Kind = 3
+)
+
+// TraceID identifies a trace across all services
+type TraceID [16]byte
+
+// String returns the lowercase hex representation
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the lowercase hex representation
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext is the part of a span that is propagated to other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// Sampled is true if the trace is recorded
+	Sampled bool
+}
+
+func randomIDs() (t TraceID, s SpanID) {
+	for t == (TraceID{}) {
+		rand.Read(t[:])
+	}
+	return t, randomSpanID()
+}
+
+func randomSpanID() (s SpanID) {
+	for s == (SpanID{}) {
+		rand.Read(s[:])
+	}
+	return s
+}
+
+// attribute is a key-value pair describing a span. Values are strings,
+// int64, float64 or bool.
+type attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a single timed operation of a trace
+type Span struct {
+	ctx    SpanContext
+	parent SpanID
+	kind   Kind
+
+	mu       sync.Mutex
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    []attribute
+	failed   bool
+	errorMsg string
+	ended    bool
+}
+
+// StartSpan starts a new span. If `parent` is nil a new trace is started,
+// which is sampled according to the configured sample ratio. Otherwise the
+// span joins the parent's trace and inherits it's sampling decision.
+func StartSpan(name string, kind Kind, parent *SpanContext) *Span {
+	s := &Span{
+		kind:  kind,
+		name:  name,
+		start: time.Now(),
+	}
+	if parent == nil {
+		s.ctx.TraceID, s.ctx.SpanID = randomIDs()
+		s.ctx.Sampled = sample()
+	} else {
+		s.ctx = SpanContext{
+			TraceID: parent.TraceID,
+			SpanID:  randomSpanID(),
+			Sampled: parent.Sampled,
+		}
+		s.parent = parent.SpanID
+	}
+	return s
+}
+
+// Child starts a new span within the trace of `s`. Returns nil if `s` is
+// nil.
+func (s *Span) Child(name string, kind Kind) *Span {
+	if s == nil {
+		return nil
+	}
+	return StartSpan(name, kind, &s.ctx)
+}
+
+// Context returns the propagated part of the span
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.ctx
+}
+
+// SetName replaces the name the span was started with. Server spans use it
+// once the route of the request is known.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttribute adds a key-value pair to the span. `value` must be a string,
+// bool, int, int64 or float64. Other types are converted with `fmt`.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil || !s.ctx.Sampled {
+		return
+	}
+	switch v := value.(type) {
+	case int:
+		value = int64(v)
+	case string, bool, int64, float64:
+	default:
+		value = fmt.Sprint(v)
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attribute{Key: key, Value: value})
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed if `err` isn't nil
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Fail(err.Error())
+}
+
+// Fail marks the span as failed with the description `msg`
+func (s *Span) Fail(msg string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.failed = true
+	s.errorMsg = msg
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export if it's sampled. Calls
+// after the first one are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.ctx.Sampled {
+		export(s)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiVersion groups the endpoints of one API version. All endpoint names are
+// relative to the version's prefix `/<Name>`. Versions can share handlers,
+// so a new version only needs new handlers for routes whose behaviour or
+// response shape changes.
+type apiVersion struct {
+	Name string
+	// Deprecation is the time the version was deprecated. Zero if it isn't
+	// deprecated.
+	Deprecation time.Time
+	// Sunset is the time the version will be removed. Zero if unknown.
+	Sunset    time.Time
+	Endpoints []endpoint
+}
+
+// deprecated reports whether clients should migrate away from this version
+func (v *apiVersion) deprecated() bool {
+	return !v.Deprecation.IsZero()
+}
+
+// versionHeaders announces the deprecation of `v` on every response of the
+// endpoint `name` (RFC 9745 and RFC 8594) and counts its usage, so we can
+// measure how many clients still have to migrate.
+func (v *apiVersion) versionHeaders(name string) middleware {
+	deprecation := "@" + strconv.FormatInt(v.Deprecation.Unix(), 10)
+	var sunset string
+	if !v.Sunset.IsZero() {
+		sunset = v.Sunset.UTC().Format(time.RFC1123)
+		sunset = strings.Replace(sunset, "UTC", "GMT", 1)
+	}
+
+	return func(next handler) handler {
+		return func(req *request) (interface{}, error) {
+			req.Response.Header.Set("Deprecation", deprecation)
+			if len(sunset) > 0 {
+				req.Response.Header.Set("Sunset", sunset)
+			}
+			metric.Deprecated(v.Name, name)
+			return next(req)
+		}
+	}
+}
+
+// statName converts a route pattern into a statsd compatible name, e.g.
+// `/v1/user/get/id/:id` becomes `v1.user.get.id.id`
+func statName(pattern string) string {
+	pattern = strings.Trim(pattern, "/")
+	pattern = strings.Replace(pattern, ":", "", -1)
+	return strings.Replace(pattern, "/", ".", -1)
+}
+
+// parseVersionTime parses an optional RFC 3339 time from the config
+func parseVersionTime(s string) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// allVersions returns all served API versions with their deprecation
+// schedule taken from the config
+func allVersions(config *conf) ([]*apiVersion, error) {
+	versions := []*apiVersion{
+		{Name: "v1", Endpoints: v1Endpoints()},
+		{Name: "v2", Endpoints: v2Endpoints()},
+	}
+
+	for _, v := range versions {
+		c, ok := config.Versions[v.Name]
+		if !ok {
+			continue
+		}
+
+		var err error
+		v.Deprecation, err = parseVersionTime(c.Deprecation)
+		if err != nil {
+			return nil, fmt.Errorf("version '%s' has an invalid deprecation: %v", v.Name, err)
+		}
+		v.Sunset, err = parseVersionTime(c.Sunset)
+		if err != nil {
+			return nil, fmt.Errorf("version '%s' has an invalid sunset: %v", v.Name, err)
+		}
+		if !v.Sunset.IsZero() && !v.deprecated() {
+			return nil, fmt.Errorf("version '%s' has a sunset but isn't deprecated", v.Name)
+		}
+		if !v.Sunset.IsZero() && v.Sunset.Before(v.Deprecation) {
+			return nil, fmt.Errorf("version '%s' has it's sunset before it's deprecation", v.Name)
+		}
+	}
+	return versions, nil
+}
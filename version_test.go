@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/vikebot/vbcore"
+)
+
+func TestShippedConfigDeprecatesNothing(t *testing.T) {
+	buf, err := ioutil.ReadFile("config/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &conf{}
+	err = json.Unmarshal(buf, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := allVersions(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range versions {
+		if v.deprecated() {
+			t.Errorf("%s is deprecated by the shipped config", v.Name)
+		}
+	}
+}
+
+func TestDeprecatedVersionSendsHeaders(t *testing.T) {
+	config := testConfig(t)
+	config.Versions = map[string]versionConf{
+		"v1": {Deprecation: "2026-01-01T00:00:00Z", Sunset: "2027-06-30T12:00:00Z"},
+	}
+	server := newTestServer(t, config)
+
+	resp := do(server, testRequest{method: "GET", path: "/v1/meta/version"})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+	deprecation := "@" + strconv.FormatInt(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), 10)
+	if h := string(resp.Header.Peek("Deprecation")); h != deprecation {
+		t.Errorf("want Deprecation %q but got %q", deprecation, h)
+	}
+	if h := string(resp.Header.Peek("Sunset")); h != "Wed, 30 Jun 2027 12:00:00 GMT" {
+		t.Errorf("want Sunset of 2027-06-30 but got %q", h)
+	}
+
+	resp = do(server, testRequest{method: "GET", path: "/v2/meta/version"})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+	if h := resp.Header.Peek("Deprecation"); h != nil {
+		t.Errorf("v2 isn't deprecated but sent Deprecation %q", h)
+	}
+	if h := resp.Header.Peek("Sunset"); h != nil {
+		t.Errorf("v2 isn't deprecated but sent Sunset %q", h)
+	}
+}
+
+func TestV2RoutesAnswer(t *testing.T) {
+	server := newTestServer(t, nil)
+	tokens := login(t, server, "alice")
+
+	resp := do(server, testRequest{method: "GET", path: "/v2/users/me", header: bearer(tokens.Token)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+	var me vbcore.SafeUser
+	decodeBody(t, resp, &me)
+	if me.Username != "alice" {
+		t.Fatalf("want alice but got %s", me.Username)
+	}
+
+	resp = do(server, testRequest{method: "GET", path: "/v2/users/" + strconv.Itoa(tokens.UserID)})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+
+	resp = do(server, testRequest{method: "GET", path: "/v2/rounds/active"})
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("want 200 but got %d: %s", resp.StatusCode(), resp.Body())
+	}
+
+	resp = do(server, testRequest{method: "GET", path: "/v2/user/get", header: bearer(tokens.Token)})
+	if code := errorCode(t, resp); code != 9001 {
+		t.Fatalf("want v1 only route to be unknown (9001) but got %d", code)
+	}
+}